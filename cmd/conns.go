@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github/shawn/ip-tool/internal/cli"
+	"github/shawn/ip-tool/internal/conns"
+	"github/shawn/ip-tool/internal/output"
+	"github/shawn/ip-tool/internal/tui"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+)
+
+// conns 过滤和显示选项
+var (
+	connsStates   []string
+	connsPort     int
+	connsType     string
+	connsProto    string
+	connsNumeric  bool
+	connsInterval time.Duration
+)
+
+var connsCmd = &cobra.Command{
+	Use:   "conns",
+	Short: "Show active connections and listeners with remote peer details",
+	Long: `Show TCP and UDP sockets: established connections and listeners.
+
+Each remote address is classified (Public/Private/...) and enriched with
+reverse DNS and geolocation/ASN. The owning process is shown when it
+can be read (run as root to see every process).
+
+In a terminal the table refreshes live. Piped or with -o it prints once.
+
+EXAMPLES:
+  ipq conns                          Live table
+  ipq conns --state established      Only established connections
+  ipq conns --state listen           Only listeners
+  ipq conns --type public            Only connections to public IPs
+  ipq conns --port 443               Local or remote port 443
+  ipq conns -n -o json               JSON, no DNS or geolocation lookups`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if connsPort < 0 || connsPort > 65535 {
+			return output.NewError("Invalid port", fmt.Sprintf("Port: %d", connsPort), "ipq conns --port 443").WithCode(cli.ExitInvalidArgs)
+		}
+		if connsInterval < time.Second {
+			return output.NewError("Interval too short", fmt.Sprintf("Interval: %s", connsInterval), "Use at least 1s: ipq conns --interval 2s")
+		}
+
+		filter := conns.Filter{
+			States: connsStates,
+			Port:   connsPort,
+			Type:   connsType,
+			Proto:  connsProto,
+		}
+
+		format := getFormat()
+		if format == output.FormatTUI {
+			p := tea.NewProgram(tui.NewConns(cmd.Context(), filter, connsInterval, !connsNumeric), tea.WithAltScreen())
+			_, err := p.Run()
+			return err
+		}
+
+		list, err := conns.List()
+		if err != nil {
+			return output.NewError("Cannot read connections", err.Error(), connsHint(err))
+		}
+		list = filter.Apply(list)
+		if !connsNumeric && format != output.FormatQuiet {
+			conns.NewEnricher().Enrich(cmd.Context(), list)
+		}
+
+		switch {
+		case format.IsMachine():
+			return output.Encode(list, format)
+		case format == output.FormatQuiet:
+			printRemoteIPs(list)
+			return nil
+		}
+
+		printConns(list)
+		return nil
+	},
+}
+
+// printConns 输出文本格式的连接表
+func printConns(list []conns.Conn) {
+	if len(list) == 0 {
+		fmt.Println(output.StyleHint.Render("No matching connections"))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROTO\tLOCAL\tREMOTE\tSTATE\tTYPE\tHOST\tCOUNTRY\tAS\tPROCESS")
+	for _, c := range list {
+		remoteType, host, country, as, process := "", "", "", "", ""
+		if !c.IsListener() {
+			remoteType = c.RemoteType
+		}
+		if c.Peer != nil {
+			host, country, as = c.Peer.Hostname, c.Peer.Country, c.Peer.AS
+		}
+		if c.Process != "" {
+			process = c.Process + "/" + strconv.Itoa(c.PID)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			c.Proto, c.Local(), c.Remote(), c.State,
+			orDash(remoteType), orDash(host), orDash(country), orDash(as), orDash(process))
+	}
+	w.Flush()
+}
+
+// printRemoteIPs 安静模式: 每个远端 IP 一行 (去重)
+func printRemoteIPs(list []conns.Conn) {
+	seen := make(map[string]bool)
+	for _, c := range list {
+		if c.IsListener() || seen[c.RemoteIP] {
+			continue
+		}
+		seen[c.RemoteIP] = true
+		fmt.Println(c.RemoteIP)
+	}
+}
+
+// connsHint 按读取套接字表失败的原因给出建议
+func connsHint(err error) string {
+	switch {
+	case errors.Is(err, conns.ErrUnavailable):
+		return "Connection listing reads /proc/net and is only supported on Linux"
+	case errors.Is(err, fs.ErrPermission):
+		return "Run with sudo, or check that /proc is not mounted with hidepid"
+	default:
+		return "Check that /proc is mounted and /proc/net/tcp is readable"
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(connsCmd)
+
+	connsCmd.Flags().StringSliceVar(&connsStates, "state", nil, "Only these states, e.g. established,listen,time_wait")
+	connsCmd.Flags().IntVar(&connsPort, "port", 0, "Only connections with this local or remote port")
+	connsCmd.Flags().StringVar(&connsType, "type", "", "Only remote addresses of this type: public, private, loopback ...")
+	connsCmd.Flags().StringVar(&connsProto, "proto", "", "Only this protocol: tcp or udp")
+	connsCmd.Flags().BoolVarP(&connsNumeric, "numeric", "n", false, "Skip reverse DNS and geolocation lookups")
+	connsCmd.Flags().DurationVar(&connsInterval, "interval", 2*time.Second, "Refresh interval of the live table")
+	connsCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format: json, yaml, text, quiet")
+	connsCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Only output remote IPs")
+}
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github/shawn/ip-tool/internal/cli"
+	"github/shawn/ip-tool/internal/ddns"
+	"github/shawn/ip-tool/internal/network"
+	"github/shawn/ip-tool/internal/output"
+
+	"github.com/spf13/cobra"
+)
+
+// ddns 子命令标志
+var (
+	ddnsZone     string        // --zone: 区域
+	ddnsName     string        // --name: 记录名
+	ddnsServer   string        // --server: 权威服务器
+	ddnsKey      string        // --tsig-key: TSIG 密钥
+	ddnsTTL      uint32        // --ttl: 记录 TTL
+	ddnsFamily   string        // --family: 4, 6 或 both
+	ddnsDryRun   bool          // --dry-run: 只比较
+	ddnsWatch    bool          // --watch: 持续运行
+	ddnsInterval time.Duration // --interval: 持续运行的间隔
+)
+
+var ddnsCmd = &cobra.Command{
+	Use:   "ddns",
+	Short: "Publish your public IP to DNS",
+}
+
+var ddnsUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update A/AAAA records with RFC 2136 DNS UPDATE",
+	Long: `Detect your public IP and publish it with an RFC 2136 DNS UPDATE.
+
+The published A/AAAA records are queried directly from --server and
+only replaced when they differ from the detected address.
+
+The TSIG key uses the nsupdate -y format: [algorithm:]name:base64-secret
+(default algorithm hmac-sha256). It can also be set with the IPQ_TSIG_KEY
+environment variable or the ddns_tsig_key config key, which keeps the
+secret out of your shell history.
+
+EXAMPLES:
+  ipq ddns update --zone example.com --name home --server ns1.example.com \
+    --tsig-key hmac-sha256:home-key:c2VjcmV0...
+  ipq ddns update ... --dry-run          Only compare
+  ipq ddns update ... --watch -i 5m      Keep records up to date`,
+	Args: cobra.NoArgs,
+	RunE: runDDNSUpdate,
+}
+
+// runDDNSUpdate 执行一次或持续更新
+func runDDNSUpdate(cmd *cobra.Command, args []string) error {
+	opts, err := ddnsOptions()
+	if err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+
+	if !ddnsWatch {
+		records := ddnsRound(ctx, opts)
+		if err := printDDNS(records); err != nil {
+			return err
+		}
+		for _, r := range records {
+			if r.Error != "" {
+				return output.NewError("DNS update failed", r.Error, "ipq ddns update --dry-run ...").WithCode(cli.ExitNetworkError)
+			}
+		}
+		return nil
+	}
+
+	if ddnsInterval < minWatchInterval {
+		return output.NewError(
+			"Interval too short",
+			fmt.Sprintf("Minimum is %s to avoid abusing public IP services", minWatchInterval),
+			"ipq ddns update --watch --interval 5m ...",
+		).WithCode(cli.ExitInvalidArgs)
+	}
+
+	// 持续运行: 错误只报告，不退出
+	for {
+		printDDNS(ddnsRound(ctx, opts))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(ddnsInterval):
+		}
+	}
+}
+
+// ddnsOptions 校验标志并构建更新参数
+func ddnsOptions() (ddns.Options, error) {
+	opts := ddns.Options{
+		Server: ddnsServer,
+		Zone:   ddnsZone,
+		Name:   ddnsName,
+		TTL:    ddnsTTL,
+		DryRun: ddnsDryRun,
+	}
+
+	if opts.Zone == "" || opts.Server == "" {
+		return opts, output.NewError(
+			"Missing --zone or --server",
+			"",
+			"ipq ddns update --zone example.com --name home --server ns1.example.com --tsig-key ...",
+		).WithCode(cli.ExitInvalidArgs)
+	}
+
+	switch ddnsFamily {
+	case "4", "6", "both":
+	default:
+		return opts, output.NewError(
+			"Invalid --family value",
+			fmt.Sprintf("Value: %s", ddnsFamily),
+			"Use 4, 6 or both",
+		).WithCode(cli.ExitInvalidArgs)
+	}
+
+	// 密钥来源优先级: 标志 > 环境变量 > 配置文件
+	keyText := ddnsKey
+	if keyText == "" {
+		keyText = os.Getenv("IPQ_TSIG_KEY")
+	}
+	if keyText == "" {
+		keyText = config.DDNSTSIGKey
+	}
+	if keyText != "" {
+		key, err := ddns.ParseKey(keyText)
+		if err != nil {
+			return opts, output.NewError(
+				"Invalid TSIG key",
+				err.Error(),
+				"--tsig-key hmac-sha256:key-name:base64-secret",
+			).WithCode(cli.ExitInvalidArgs)
+		}
+		opts.Key = key
+	} else if !opts.DryRun && !quiet {
+		fmt.Fprintln(os.Stderr, output.StyleWarning.Render("Warning: no TSIG key, most servers will refuse the update"))
+	}
+
+	return opts, nil
+}
+
+// ddnsRound 检测公网 IP 并同步每个地址族
+func ddnsRound(ctx context.Context, opts ddns.Options) []ddns.Record {
+	var records []ddns.Record
+
+	families := []struct {
+		name   string
+		rtype  string
+		detect func(context.Context) (string, error)
+	}{
+		{"4", "A", network.FetchPublicIPv4},
+		{"6", "AAAA", network.FetchPublicIPv6},
+	}
+
+	for _, f := range families {
+		if ddnsFamily != "both" && ddnsFamily != f.name {
+			continue
+		}
+
+		detected, err := f.detect(ctx)
+		if err != nil {
+			// 未指定地址族时，没有 IPv6 很常见，静默跳过
+			if ddnsFamily == "both" {
+				continue
+			}
+			records = append(records, ddns.Record{
+				Type:      f.rtype,
+				Name:      ddns.FQDN(opts.Name, opts.Zone),
+				Published: []string{},
+				Error:     fmt.Sprintf("public IPv%s not detected: %v", f.name, err),
+			})
+			continue
+		}
+		records = append(records, ddns.Sync(ctx, opts, detected))
+	}
+
+	return records
+}
+
+// printDDNS 输出同步结果
+func printDDNS(records []ddns.Record) error {
+	if format := getFormat(); format.IsMachine() {
+		if records == nil {
+			records = []ddns.Record{}
+		}
+		return output.Encode(records, format)
+	}
+
+	if len(records) == 0 {
+		fmt.Fprintln(os.Stderr, output.StyleWarning.Render("No public IP detected"))
+		return nil
+	}
+
+	for _, r := range records {
+		published := orDash(strings.Join(r.Published, ", "))
+		status := ""
+		switch {
+		case r.Error != "":
+			status = output.StyleError.Render("✗ " + r.Error)
+		case r.Updated:
+			status = output.StyleSuccess.Render("updated")
+		case r.InSync():
+			status = "unchanged"
+		default:
+			status = output.StyleWarning.Render("out of date (dry run)")
+		}
+
+		if quiet && !r.Updated && r.Error == "" {
+			continue
+		}
+		fmt.Printf("%s  %-4s %s  %s → %s  %s\n",
+			time.Now().Format(time.DateTime), r.Type, r.Name, published, orDash(r.Detected), status)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(ddnsCmd)
+	ddnsCmd.AddCommand(ddnsUpdateCmd)
+
+	f := ddnsUpdateCmd.Flags()
+	f.StringVar(&ddnsZone, "zone", "", "DNS zone to update (e.g. example.com)")
+	f.StringVar(&ddnsName, "name", "@", "Record name, relative to the zone")
+	f.StringVar(&ddnsServer, "server", "", "Authoritative server host[:port]")
+	f.StringVar(&ddnsKey, "tsig-key", "", "TSIG key: [algorithm:]name:base64-secret")
+	f.Uint32Var(&ddnsTTL, "ttl", 300, "TTL of the published records")
+	f.StringVar(&ddnsFamily, "family", "both", "Address family to publish: 4, 6 or both")
+	f.BoolVar(&ddnsDryRun, "dry-run", false, "Only compare, do not update")
+	f.BoolVar(&ddnsWatch, "watch", false, "Keep running and update on change")
+	f.DurationVarP(&ddnsInterval, "interval", "i", 5*time.Minute, "Interval for --watch")
+	f.StringVarP(&outputFormat, "output", "o", "", "Output format: json, yaml, text")
+	f.BoolVarP(&quiet, "quiet", "q", false, "Only print updates and errors")
+}
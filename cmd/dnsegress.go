@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github/shawn/ip-tool/internal/cli"
+	"github/shawn/ip-tool/internal/dnsleak"
+	"github/shawn/ip-tool/internal/output"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	dnsEgressProbes    []string // --probe: 探测域名
+	dnsEgressResolvers []string // --resolver: 额外查询的服务器
+	dnsEgressVPN       bool     // --vpn: 声明 VPN 已启用
+)
+
+var dnsEgressCmd = &cobra.Command{
+	Use:   "dns-egress",
+	Short: "Show where DNS queries egress from (DNS leak check)",
+	Long: `Find out which address your DNS queries actually leave from.
+
+"whoami" names are answered by their authoritative servers with the
+address of whoever asked, which is the recursive resolver's egress,
+not this host:
+
+  whoami.akamai.net               A
+  o-o.myaddr.l.google.com         TXT
+
+They are queried through the system resolver and through every
+nameserver in /etc/resolv.conf, and each egress IP is shown with its
+ASN and location. When a VPN is active (the default route goes through
+a tun/wg/ppp interface, or --vpn is given) but DNS egresses from a
+network other than your public IP's, queries bypass the VPN: a DNS
+leak. The command exits non-zero when a leak is found.
+
+Probe names can be changed with --probe or the dns_egress_probes config
+key, e.g. to use an internal whoami service.
+
+EXAMPLES:
+  ipq dns-egress
+  ipq dns-egress --resolver 1.1.1.1 --resolver 9.9.9.9
+  ipq dns-egress --vpn                 VPN not detected from the routes
+  ipq dns-egress --probe "whoami.corp.example TXT"
+  ipq dns-egress -o json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names := dnsleak.DefaultProbes
+		switch {
+		case cmd.Flags().Changed("probe"):
+			names = dnsEgressProbes
+		case len(config.DNSEgressProbes) > 0:
+			names = config.DNSEgressProbes
+		}
+		probes, err := dnsleak.ParseProbes(names)
+		if err != nil {
+			return output.NewError("Invalid probe", err.Error(), `ipq dns-egress --probe "o-o.myaddr.l.google.com TXT"`).WithCode(cli.ExitInvalidArgs)
+		}
+
+		checker := dnsleak.New(probes)
+		checker.Resolvers = dnsEgressResolvers
+		checker.AssumeVPN = dnsEgressVPN
+		report := checker.Run(cmd.Context())
+
+		switch format := getFormat(); {
+		case format.IsMachine():
+			if err := output.Encode(report, format); err != nil {
+				return err
+			}
+		case format == output.FormatQuiet:
+			for _, e := range report.Egresses {
+				fmt.Println(e.IP)
+			}
+		default:
+			printDNSEgress(report)
+		}
+
+		if report.Leak {
+			return output.NewError(
+				"DNS leak detected",
+				report.Verdict,
+				"Make the VPN push its own DNS servers, or enable its DNS leak protection",
+			)
+		}
+		return nil
+	},
+}
+
+// printDNSEgress 输出文本格式的检测结果
+func printDNSEgress(r *dnsleak.Report) {
+	public := orDash(r.Public)
+	if r.PublicAS != "" {
+		public += "  " + r.PublicAS
+	}
+	fmt.Printf("Public IP: %s\n", public)
+
+	vpn := "not detected"
+	switch {
+	case r.VPNInterface != "":
+		vpn = "yes (default route via " + r.VPNInterface + ")"
+	case r.VPN:
+		vpn = "yes (--vpn)"
+	}
+	fmt.Printf("VPN:       %s\n", vpn)
+	if r.GeoError != "" {
+		fmt.Printf("AS lookup: %s\n", output.StyleWarning.Render("failed: "+r.GeoError))
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RESOLVER\tPROBE\tEGRESS\tTIME")
+	for _, q := range r.Queries {
+		egress := strings.Join(q.Egress, ", ")
+		if q.Error != "" {
+			egress = output.StyleHint.Render(q.Error)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%dms\n", q.Resolver, q.Probe, egress, q.Duration)
+	}
+	w.Flush()
+
+	if len(r.Egresses) > 0 {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "EGRESS\tAS\tLOCATION\tRESOLVERS")
+		for _, e := range r.Egresses {
+			as := orDash(e.AS)
+			if e.Operator != "" {
+				as += " (public DNS)"
+			}
+			location := strings.Trim(e.City+", "+e.Country, ", ")
+			line := fmt.Sprintf("%s\t%s\t%s\t%s", e.IP, as, orDash(location), strings.Join(e.Resolvers, ", "))
+			if e.Leak {
+				line += "\t" + output.StyleError.Render("leak")
+			}
+			fmt.Fprintln(w, line)
+		}
+		w.Flush()
+	}
+
+	// 泄漏时由调用方以错误输出结论
+	if !r.Leak {
+		fmt.Println()
+		fmt.Println(output.StyleHint.Render(r.Verdict))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(dnsEgressCmd)
+
+	dnsEgressCmd.Flags().StringArrayVar(&dnsEgressProbes, "probe", nil, `Probe name, "name" or "name TXT" (repeatable)`)
+	dnsEgressCmd.Flags().StringArrayVar(&dnsEgressResolvers, "resolver", nil, "Also query this DNS server (repeatable)")
+	dnsEgressCmd.Flags().BoolVar(&dnsEgressVPN, "vpn", false, "Treat a VPN as active even if the routes don't show one")
+	dnsEgressCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format: json, yaml, text, quiet")
+	dnsEgressCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Only output the egress IPs")
+}
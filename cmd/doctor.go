@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github/shawn/ip-tool/internal/cli"
+	"github/shawn/ip-tool/internal/doctor"
+	"github/shawn/ip-tool/internal/ip"
+	"github/shawn/ip-tool/internal/output"
+
+	"github.com/spf13/cobra"
+)
+
+// newDoctor 创建使用真实系统的 Doctor (测试时替换为本地替身)
+var newDoctor = doctor.New
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor [domain]",
+	Short: "Diagnose local connectivity and resolver setup",
+	Long: `Run a series of checks on this host's network setup:
+
+  - /etc/resolv.conf: nameservers, search domains, options
+  - /etc/hosts overrides for the domain (when given)
+  - IPv4 and IPv6 default routes
+  - Proxy environment variables
+  - Whether each DNS server answers
+  - Public IPv4/IPv6 detection compared with the local source address
+
+Each check reports pass, warn or fail with a suggestion. The command
+exits non-zero when any check fails. JSON output includes the hostname
+and time so reports from many hosts can be collected.
+
+EXAMPLES:
+  ipq doctor
+  ipq doctor intranet.example.com     Also check hosts/DNS for this name
+  ipq doctor -o json                  For fleet collection`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target := ""
+		if len(args) == 1 {
+			target = ip.ExtractFromURL(args[0])
+			if !ip.IsValidTarget(target) {
+				return output.NewError(
+					"Invalid target",
+					fmt.Sprintf("Input: %s", args[0]),
+					"ipq doctor example.com",
+				).WithCode(cli.ExitInvalidArgs)
+			}
+		}
+
+		report := newDoctor(target).Run(cmd.Context())
+
+		format := getFormat()
+		switch {
+		case format.IsMachine():
+			if err := output.Encode(report, format); err != nil {
+				return err
+			}
+		case format == output.FormatQuiet:
+			fmt.Println(report.Status)
+		default:
+			printDoctor(report)
+		}
+
+		if report.Summary.Fail > 0 {
+			return output.NewError(
+				fmt.Sprintf("%d check(s) failed", report.Summary.Fail),
+				"",
+				"Fix the failed checks and run ipq doctor again",
+			)
+		}
+		return nil
+	},
+}
+
+// printDoctor 输出文本格式的诊断报告 (每项与 output.NewError 的格式一致)
+func printDoctor(report *doctor.Report) {
+	for _, c := range report.Checks {
+		fmt.Println(statusMark(c.Status) + " " + c.Name + ": " + c.Message)
+		for _, d := range c.Details {
+			fmt.Println(output.StyleHint.Render("  " + d))
+		}
+		if c.Suggestion != "" && (c.Status == doctor.StatusWarn || c.Status == doctor.StatusFail) {
+			fmt.Println(output.StyleSuggestion.Render("  → " + c.Suggestion))
+		}
+	}
+
+	s := report.Summary
+	fmt.Printf("\n%d passed, %d warnings, %d failed, %d skipped\n", s.Pass, s.Warn, s.Fail, s.Skip)
+}
+
+// statusMark 检查结果的符号
+func statusMark(s doctor.Status) string {
+	switch s {
+	case doctor.StatusPass:
+		return output.StyleSuccess.Render("✓")
+	case doctor.StatusWarn:
+		return output.StyleWarning.Render("!")
+	case doctor.StatusFail:
+		return output.StyleError.Render("✗")
+	}
+	return output.StyleHint.Render("-")
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format: json, yaml, text, quiet")
+	doctorCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Only output the overall status")
+}
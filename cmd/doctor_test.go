@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github/shawn/ip-tool/internal/cli"
+	"github/shawn/ip-tool/internal/doctor"
+	"github/shawn/ip-tool/internal/route"
+)
+
+// fakeDoctor 替换 newDoctor: 本地替身，dnsOK 控制 DNS 是否应答
+func fakeDoctor(t *testing.T, dnsOK bool) {
+	t.Helper()
+	resolv := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := os.WriteFile(resolv, []byte("nameserver 192.0.2.53\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	orig := newDoctor
+	t.Cleanup(func() { newDoctor = orig })
+	newDoctor = func(target string) *doctor.Doctor {
+		return &doctor.Doctor{
+			Target:         target,
+			ResolvConfPath: resolv,
+			HostsPath:      filepath.Join(t.TempDir(), "hosts"),
+			Routes: func() ([]route.Route, error) {
+				return []route.Route{
+					{Family: "ipv4", Destination: "0.0.0.0/0", Interface: "eth0"},
+					{Family: "ipv6", Destination: "::/0", Interface: "eth0"},
+				}, nil
+			},
+			Egress: func(string) string { return "" },
+			Getenv: func(string) string { return "" },
+			QueryDNS: func(ctx context.Context, server, name string) error {
+				if !dnsOK {
+					return errors.New("timeout")
+				}
+				return nil
+			},
+			FetchIPv4: func(context.Context) (string, error) { return "203.0.113.7", nil },
+			FetchIPv6: func(context.Context) (string, error) { return "2001:db8::7", nil },
+		}
+	}
+}
+
+func TestDoctorExitCode(t *testing.T) {
+	tests := []struct {
+		name   string
+		dnsOK  bool
+		args   []string
+		status doctor.Status
+		code   int
+	}{
+		{"all pass", true, []string{"doctor", "-o", "json"}, doctor.StatusPass, cli.ExitSuccess},
+		{"dns fails", false, []string{"doctor", "-o", "json"}, doctor.StatusFail, cli.ExitGeneralError},
+		{"quiet", false, []string{"doctor", "-o", "quiet"}, doctor.StatusFail, cli.ExitGeneralError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeDoctor(t, tt.dnsOK)
+			out, code := execute(t, tt.args...)
+			if code != tt.code {
+				t.Errorf("exit code = %d, want %d", code, tt.code)
+			}
+
+			status := doctor.Status(strings.TrimSpace(out))
+			if tt.args[2] == "json" {
+				var report doctor.Report
+				if err := json.Unmarshal([]byte(out), &report); err != nil {
+					t.Fatalf("invalid JSON: %v\n%s", err, out)
+				}
+				status = report.Status
+			}
+			if status != tt.status {
+				t.Errorf("status = %s, want %s", status, tt.status)
+			}
+		})
+	}
+}
+
+func TestDoctorInvalidTarget(t *testing.T) {
+	fakeDoctor(t, true)
+	if _, code := execute(t, "doctor", "-o", "json", "not a host!"); code != cli.ExitInvalidArgs {
+		t.Errorf("exit code = %d, want %d", code, cli.ExitInvalidArgs)
+	}
+}
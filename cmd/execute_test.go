@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github/shawn/ip-tool/internal/cli"
+	"github/shawn/ip-tool/internal/output"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// execute 运行 ipq 命令，返回标准输出和退出码
+func execute(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	stdout, _, code := runCommand(t, args...)
+	return stdout, code
+}
+
+// runCommand 像 Execute 一样运行 ipq 命令，返回标准输出、标准错误和退出码
+//
+// 每次运行前把所有标志恢复为默认值: 标志绑定在包级变量上，上一次的值会保留
+func runCommand(t *testing.T, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	if config == nil {
+		config = cli.DefaultConfig()
+	}
+	resetFlags(rootCmd)
+
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	origOut, origErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outW, errW
+	outC, errC := readAll(outR), readAll(errR)
+
+	rootCmd.SetArgs(args)
+	err = rootCmd.ExecuteContext(context.Background())
+	code = cli.ExitCode(err)
+	if err != nil {
+		output.PrintError(err, code, getFormat())
+	}
+
+	outW.Close()
+	errW.Close()
+	os.Stdout, os.Stderr = origOut, origErr
+	return string(<-outC), string(<-errC), code
+}
+
+// readAll 在后台读完 r (避免管道写满阻塞命令)
+func readAll(r io.Reader) <-chan []byte {
+	c := make(chan []byte, 1)
+	go func() {
+		data, _ := io.ReadAll(r)
+		c <- data
+	}()
+	return c
+}
+
+// resetFlags 把 cmd 及其子命令的标志恢复为默认值
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if s, ok := f.Value.(pflag.SliceValue); ok {
+			s.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, c := range cmd.Commands() {
+		resetFlags(c)
+	}
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github/shawn/ip-tool/internal/gateway"
+	"github/shawn/ip-tool/internal/output"
+
+	"github.com/spf13/cobra"
+)
+
+var gatewayCmd = &cobra.Command{
+	Use:   "gateway",
+	Short: "Ask the router for its WAN address (UPnP IGD, NAT-PMP, PCP)",
+	Long: `Ask the home router for its external (WAN) address and compare it with
+your public IP.
+
+The router is queried with UPnP IGD (SSDP discovery + GetExternalIPAddress),
+NAT-PMP and PCP at the same time; any one answering is enough.
+
+The WAN address is then compared with your public IP:
+  100.64.0.0/10      Carrier-grade NAT (CGNAT) between the router and the Internet
+  private (RFC 1918) Another router upstream (double NAT)
+  differs            Traffic leaves through something else (VPN or proxy?)
+
+Behind CGNAT or double NAT, port forwarding on the router cannot make
+services reachable from outside.
+
+EXAMPLES:
+  ipq gateway
+  ipq gateway -o json
+  ipq gateway -q          Only the router's WAN address`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		report := gateway.New().Run(cmd.Context())
+
+		format := getFormat()
+		switch {
+		case format.IsMachine():
+			if err := output.Encode(report, format); err != nil {
+				return err
+			}
+		case format == output.FormatQuiet:
+			if report.WAN != "" {
+				fmt.Println(report.WAN)
+			}
+		default:
+			printGateway(report)
+		}
+
+		if report.WAN == "" {
+			return output.NewError(
+				"No router answered",
+				"UPnP IGD, NAT-PMP and PCP all failed",
+				"Enable UPnP or NAT-PMP on the router, or read the WAN address from its web interface",
+			)
+		}
+		return nil
+	},
+}
+
+// printGateway 输出文本格式的路由器查询结果
+func printGateway(r *gateway.Report) {
+	fmt.Printf("Gateway:   %s\n", orDash(r.Gateway))
+	for _, a := range r.Answers {
+		label := fmt.Sprintf("%-10s ", a.Protocol+":")
+		switch {
+		case a.Address != "" && a.Device != "":
+			fmt.Println(label + a.Address + " (" + a.Device + ")")
+		case a.Address != "":
+			fmt.Println(label + a.Address)
+		default:
+			fmt.Println(label + output.StyleHint.Render(a.Error))
+		}
+	}
+	fmt.Printf("Public IP: %s\n", orDash(r.Public))
+
+	if r.WAN == "" {
+		return // 由调用方输出错误
+	}
+
+	fmt.Println()
+	switch {
+	case r.CGNAT:
+		fmt.Println(output.StyleWarning.Render("! " + r.Verdict))
+		fmt.Println(output.StyleSuggestion.Render("  → Inbound port forwarding won't work. Ask the ISP for a public IPv4, or use IPv6"))
+	case r.DoubleNAT:
+		fmt.Println(output.StyleWarning.Render("! " + r.Verdict))
+		fmt.Println(output.StyleSuggestion.Render("  → Put the upstream router in bridge mode, or forward the port on both routers"))
+	case r.EgressDiffers:
+		fmt.Println(output.StyleWarning.Render("! " + r.Verdict))
+		fmt.Println(output.StyleSuggestion.Render("  → Disconnect the VPN or proxy to check the router's own connection"))
+	case r.Public != "":
+		fmt.Println(output.StyleSuccess.Render("✓ " + r.Verdict))
+	default:
+		fmt.Println(output.StyleHint.Render(r.Verdict))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(gatewayCmd)
+
+	gatewayCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format: json, yaml, text, quiet")
+	gatewayCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Only output the router's WAN address")
+}
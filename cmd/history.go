@@ -0,0 +1,309 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github/shawn/ip-tool/internal/cli"
+	"github/shawn/ip-tool/internal/history"
+	"github/shawn/ip-tool/internal/output"
+
+	"github.com/spf13/cobra"
+)
+
+// history 子命令标志
+var (
+	historySince   string // --since: 起始时间
+	historyUntil   string // --until: 结束时间
+	historyCountry string // --country: 国家
+	historyType    string // --type: IP 类型
+	historyLimit   int    // -n: 最多显示条数
+	historyForce   bool   // --force: 清空时不确认
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show and search past lookups",
+	Long: `Show, search and re-run past lookups.
+
+History is stored in $XDG_STATE_HOME/ipq/history.jsonl
+(default ~/.local/state/ipq/history.jsonl).
+Disable it with "history: false" in the config file.
+
+EXAMPLES:
+  ipq history                         Last 20 lookups
+  ipq history list --since 24h        Lookups from the last day
+  ipq history list --country Germany  Filter by country
+  ipq history search 8.8              Search targets, IPs, ISP ...
+  ipq history show 42                 Show one entry
+  ipq history rerun 42 -d             Query the same target again
+  ipq history clear                   Delete all history`,
+	Args: cobra.NoArgs,
+	RunE: runHistoryList,
+}
+
+var historyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List past lookups",
+	Args:  cobra.NoArgs,
+	RunE:  runHistoryList,
+}
+
+var historySearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search past lookups",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter, err := historyFilter()
+		if err != nil {
+			return err
+		}
+		filter.Query = args[0]
+		return printHistory(filter)
+	},
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show one history entry",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		e, err := historyEntry(args[0])
+		if err != nil {
+			return err
+		}
+
+		if format := getFormat(); format.IsMachine() {
+			return output.Encode(e, format)
+		}
+
+		fmt.Printf("ID: %d\n", e.ID)
+		fmt.Printf("Time: %s\n", e.Time.Local().Format(time.RFC3339))
+		fmt.Printf("Target: %s\n", e.Target)
+		if e.Input != "" && e.Input != e.Target {
+			fmt.Printf("Input: %s\n", e.Input)
+		}
+		fmt.Printf("Source: %s\n", e.Source)
+		fmt.Printf("IPv4: %s\n", orDash(e.IPv4))
+		fmt.Printf("IPv6: %s\n", orDash(e.IPv6))
+		fmt.Printf("Type: %s\n", orDash(e.Type))
+		if e.Country != "" || e.ISP != "" {
+			fmt.Println("---")
+			fmt.Printf("ISP: %s\n", orDash(e.ISP))
+			fmt.Printf("Location: %s\n", orDash(strings.Trim(e.City+", "+e.Country, ", ")))
+		}
+		return nil
+	},
+}
+
+var historyRerunCmd = &cobra.Command{
+	Use:   "rerun <id>",
+	Short: "Query a past target again",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		e, err := historyEntry(args[0])
+		if err != nil {
+			return err
+		}
+
+		q := query{target: e.Target, input: e.Input, source: history.SourceHistory}
+		if e.Source == history.SourceDefault || e.Target == "(localhost)" {
+			q.target = ""
+		}
+		if err := configureDeadline(cmd); err != nil {
+			return err
+		}
+		return lookup(cmd.Context(), q, getFormat())
+	},
+}
+
+var historyClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete all history",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// CLI Guidelines: 破坏性操作需要确认，非交互式环境必须显式 --force
+		if !historyForce {
+			if !cli.IsInteractive() {
+				return output.NewError(
+					"Refusing to clear history without confirmation",
+					"",
+					"ipq history clear --force",
+				)
+			}
+			fmt.Printf("Delete all history in %s? [y/N] ", history.Default().Path())
+			answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+				return nil
+			}
+		}
+
+		n, err := history.Default().Clear()
+		if err != nil {
+			return output.NewError("Failed to clear history", err.Error(), "")
+		}
+		fmt.Printf("Deleted %d entries\n", n)
+		return nil
+	},
+}
+
+// runHistoryList 列出历史 (ipq history / ipq history list)
+func runHistoryList(cmd *cobra.Command, args []string) error {
+	filter, err := historyFilter()
+	if err != nil {
+		return err
+	}
+	return printHistory(filter)
+}
+
+// printHistory 按条件输出历史记录 (最新的在最后)
+func printHistory(filter history.Filter) error {
+	store := history.Default()
+	entries, err := store.List()
+	if err != nil {
+		return output.NewError("Failed to read history", err.Error(), "")
+	}
+
+	entries = filter.Apply(entries)
+	if historyLimit > 0 && len(entries) > historyLimit {
+		entries = entries[len(entries)-historyLimit:]
+	}
+
+	if format := getFormat(); format.IsMachine() {
+		if entries == nil {
+			entries = []history.Entry{}
+		}
+		return output.Encode(entries, format)
+	}
+
+	if len(entries) == 0 {
+		if !store.Enabled() {
+			fmt.Fprintln(os.Stderr, "History is disabled (history: false in config)")
+		} else {
+			fmt.Fprintln(os.Stderr, "No matching history")
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tTARGET\tIPV4\tIPV6\tTYPE\tCOUNTRY")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.ID,
+			e.Time.Local().Format("2006-01-02 15:04"),
+			e.Target,
+			orDash(e.IPv4),
+			orDash(e.IPv6),
+			orDash(e.Type),
+			orDash(e.Country),
+		)
+	}
+	return w.Flush()
+}
+
+// historyFilter 根据标志构建过滤条件
+func historyFilter() (history.Filter, error) {
+	filter := history.Filter{Country: historyCountry, Type: historyType}
+
+	var err error
+	if filter.Since, err = parseTime(historySince); err != nil {
+		return filter, output.NewError("Invalid --since value", err.Error(), "ipq history list --since 24h").WithCode(cli.ExitInvalidArgs)
+	}
+	if filter.Until, err = parseTime(historyUntil); err != nil {
+		return filter, output.NewError("Invalid --until value", err.Error(), "ipq history list --until 2026-01-31").WithCode(cli.ExitInvalidArgs)
+	}
+	return filter, nil
+}
+
+// historyEntry 按 ID 读取记录
+func historyEntry(arg string) (history.Entry, error) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return history.Entry{}, output.NewError(
+			"Invalid history ID",
+			fmt.Sprintf("ID: %s", arg),
+			"ipq history list",
+		).WithCode(cli.ExitInvalidArgs)
+	}
+
+	e, err := history.Default().Get(id)
+	if errors.Is(err, history.ErrNotFound) {
+		return e, output.NewError(
+			"History entry not found",
+			fmt.Sprintf("ID: %d", id),
+			"ipq history list",
+		)
+	}
+	if err != nil {
+		return e, output.NewError("Failed to read history", err.Error(), "")
+	}
+	return e, nil
+}
+
+// parseTime 解析时间条件
+//
+// 支持格式:
+//   - 相对时间: "90m", "24h", "7d" (表示距今多久)
+//   - 日期: "2026-01-31" (本地时间 00:00)
+//   - RFC 3339: "2026-01-31T08:00:00Z"
+func parseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("expected a duration (24h, 7d), a date (2026-01-31) or RFC 3339 time")
+}
+
+// orDash 空值显示为 "-"
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyListCmd, historySearchCmd, historyShowCmd, historyRerunCmd, historyClearCmd)
+
+	// 过滤选项 (history、list、search 共用)
+	for _, c := range []*cobra.Command{historyCmd, historyListCmd, historySearchCmd} {
+		c.Flags().StringVar(&historySince, "since", "", "Only entries after this time (24h, 7d, 2026-01-31)")
+		c.Flags().StringVar(&historyUntil, "until", "", "Only entries before this time")
+		c.Flags().StringVar(&historyCountry, "country", "", "Only entries from this country")
+		c.Flags().StringVar(&historyType, "type", "", "Only entries of this IP type (Public, Private ...)")
+		c.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Maximum entries to show (0 for all)")
+	}
+
+	// 输出选项
+	for _, c := range []*cobra.Command{historyCmd, historyListCmd, historySearchCmd, historyShowCmd, historyRerunCmd} {
+		c.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format: json, yaml, text")
+	}
+
+	// rerun 与主命令相同的查询选项
+	historyRerunCmd.Flags().BoolVarP(&showDetail, "detail", "d", false, "Show detailed info")
+	historyRerunCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Only output IP addresses")
+
+	historyClearCmd.Flags().BoolVar(&historyForce, "force", false, "Do not ask for confirmation")
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github/shawn/ip-tool/internal/local"
+	"github/shawn/ip-tool/internal/output"
+
+	"github.com/spf13/cobra"
+)
+
+// localUpOnly --up: 只显示启用的接口
+var localUpOnly bool
+
+var localCmd = &cobra.Command{
+	Use:   "local",
+	Short: "List local interfaces and addresses",
+	Long: `List every network interface with its addresses, prefix lengths,
+MTU, flags and MAC address.
+
+Each address is classified (Public/Private/Link-Local ...). IPv6
+temporary (privacy) addresses are marked, and so is the egress source
+address the kernel picks for Internet traffic, together with the
+interface holding the default route.
+
+EXAMPLES:
+  ipq local              All interfaces
+  ipq local --up         Only interfaces that are up
+  ipq local -q           Only the egress source addresses
+  ipq local -o json      JSON output`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inv, err := local.Collect()
+		if err != nil {
+			return output.NewError("Failed to list interfaces", err.Error(), "")
+		}
+
+		if localUpOnly {
+			var up []local.Interface
+			for _, iface := range inv.Interfaces {
+				if iface.IsUp() {
+					up = append(up, iface)
+				}
+			}
+			inv.Interfaces = up
+		}
+
+		switch format := getFormat(); {
+		case format.IsMachine():
+			return output.Encode(inv, format)
+		case format == output.FormatQuiet:
+			for _, s := range []string{inv.EgressIPv4, inv.EgressIPv6} {
+				if s != "" {
+					fmt.Println(s)
+				}
+			}
+			return nil
+		}
+
+		printLocal(inv)
+		return nil
+	},
+}
+
+// printLocal 输出文本格式的接口清单
+func printLocal(inv *local.Inventory) {
+	for i, iface := range inv.Interfaces {
+		if i > 0 {
+			fmt.Println()
+		}
+
+		line := fmt.Sprintf("%s (%s)  mtu %d", iface.Name, strings.Join(iface.Flags, ", "), iface.MTU)
+		if iface.MAC != "" {
+			line += "  mac " + iface.MAC
+		}
+		if iface.DefaultRoute {
+			line += "  " + output.StyleSuccess.Render("[default route]")
+		}
+		fmt.Println(line)
+
+		if len(iface.Addresses) == 0 {
+			fmt.Println(output.StyleHint.Render("  (no addresses)"))
+			continue
+		}
+		for _, a := range iface.Addresses {
+			line := fmt.Sprintf("  %-42s %-12s%s", a.CIDR(), a.Type, addressMarks(a))
+			fmt.Println(strings.TrimRight(line, " "))
+		}
+	}
+}
+
+// addressMarks 地址的附加标记
+func addressMarks(a local.Address) string {
+	var marks []string
+	if a.Egress {
+		marks = append(marks, output.StyleSuccess.Render("egress"))
+	}
+	if a.Temporary {
+		marks = append(marks, "temporary")
+	}
+	if a.Deprecated {
+		marks = append(marks, output.StyleHint.Render("deprecated"))
+	}
+	return strings.Join(marks, ", ")
+}
+
+func init() {
+	rootCmd.AddCommand(localCmd)
+
+	localCmd.Flags().BoolVar(&localUpOnly, "up", false, "Only show interfaces that are up")
+	localCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format: json, yaml, text, quiet")
+	localCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Only output egress source addresses")
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+
+	"github/shawn/ip-tool/internal/cli"
+	"github/shawn/ip-tool/internal/mac"
+	"github/shawn/ip-tool/internal/neigh"
+	"github/shawn/ip-tool/internal/network"
+	"github/shawn/ip-tool/internal/output"
+
+	"github.com/spf13/cobra"
+)
+
+// macUpdate --update: 下载完整的 IEEE OUI 注册表
+var macUpdate bool
+
+var macCmd = &cobra.Command{
+	Use:   "mac <mac|oui|ip>",
+	Short: "Look up the vendor of a MAC address",
+	Long: `Look up the vendor of a MAC address and decode its flag bits.
+
+Accepts aa:bb:cc:dd:ee:ff, aa-bb-cc-dd-ee-ff, aabb.ccdd.eeff,
+aabbccddeeff or just the OUI prefix (aa:bb:cc). An IP address is
+looked up in the neighbor table first.
+
+A small vendor list is built in. --update downloads the full IEEE
+registry to $XDG_DATA_HOME/ipq/oui.txt, which is used from then on.
+
+EXAMPLES:
+  ipq mac 00:1a:11:00:00:01
+  ipq mac b8:27:eb            OUI prefix only
+  ipq mac 192.168.1.1         MAC of a neighbor
+  ipq mac --update            Download the full IEEE registry`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if macUpdate {
+			n, err := mac.Update(cmd.Context(), network.HTTPClient())
+			if err != nil {
+				return output.NewError("Failed to update OUI database", err.Error(), "Check network connectivity and retry").WithCause(err)
+			}
+			if !quiet {
+				fmt.Printf("%s %d vendors saved to %s\n", output.StyleSuccess.Render("✓"), n, mac.DefaultPath())
+			}
+			if len(args) == 0 {
+				return nil
+			}
+		}
+
+		if len(args) == 0 {
+			return output.NewError("Missing MAC address", "", "ipq mac 00:1a:11:00:00:01").WithCode(cli.ExitInvalidArgs)
+		}
+
+		addr := args[0]
+		if net.ParseIP(addr) != nil {
+			hw, err := neighborMAC(addr)
+			if err != nil {
+				return err
+			}
+			addr = hw
+		}
+
+		info, err := mac.Parse(addr)
+		if err != nil {
+			return output.NewError(
+				"Invalid MAC address",
+				fmt.Sprintf("Input: %s", args[0]),
+				"ipq mac 00:1a:11:00:00:01",
+			).WithCode(cli.ExitInvalidArgs)
+		}
+
+		switch format := getFormat(); {
+		case format.IsMachine():
+			return output.Encode(info, format)
+		case format == output.FormatQuiet:
+			fmt.Println(info.Vendor)
+			return nil
+		}
+
+		printMAC(info)
+		return nil
+	},
+}
+
+// neighborMAC 在邻居表中查找 IP 对应的 MAC
+func neighborMAC(addr string) (string, error) {
+	list, err := neigh.List()
+	if err != nil {
+		return "", output.NewError("Cannot read neighbor table", err.Error(), "Pass the MAC address directly")
+	}
+	target := net.ParseIP(addr)
+	for _, n := range list {
+		if net.ParseIP(n.IP).Equal(target) && n.MAC != "" {
+			return n.MAC, nil
+		}
+	}
+	return "", output.NewError(
+		"IP not in neighbor table",
+		fmt.Sprintf("%s has not been seen on the local network recently", addr),
+		"Ping it first, then retry; or run: ipq neigh",
+	)
+}
+
+// printMAC 输出文本格式的 MAC 解码结果
+func printMAC(info *mac.Info) {
+	fmt.Printf("Address: %s\n", info.Address)
+	fmt.Printf("OUI: %s\n", info.OUI)
+
+	switch {
+	case info.Vendor != "":
+		fmt.Printf("Vendor: %s\n", info.Vendor)
+	case info.Multicast:
+		fmt.Printf("Vendor: %s\n", output.StyleHint.Render("none (group address)"))
+	case info.LocallyAdministered:
+		fmt.Printf("Vendor: %s\n", output.StyleHint.Render("none (not IEEE assigned)"))
+	default:
+		source, _ := mac.Source()
+		hint := "unknown"
+		if source == "embedded" {
+			hint += " (try: ipq mac --update)"
+		}
+		fmt.Printf("Vendor: %s\n", output.StyleHint.Render(hint))
+	}
+
+	cast := "unicast"
+	if info.Broadcast {
+		cast = "broadcast"
+	} else if info.Multicast {
+		cast = "multicast"
+	}
+	fmt.Printf("Cast: %s (I/G bit %d)\n", cast, boolBit(info.Multicast))
+
+	admin := "universal (IEEE assigned)"
+	if info.LocallyAdministered {
+		admin = "local"
+	}
+	fmt.Printf("Administration: %s (U/L bit %d)\n", admin, boolBit(info.LocallyAdministered))
+
+	if info.Note != "" {
+		fmt.Printf("Note: %s\n", info.Note)
+	}
+}
+
+// boolBit 布尔值转为位值
+func boolBit(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func init() {
+	rootCmd.AddCommand(macCmd)
+
+	macCmd.Flags().BoolVar(&macUpdate, "update", false, "Download the full IEEE OUI registry")
+	macCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format: json, yaml, text, quiet")
+	macCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Only output the vendor")
+}
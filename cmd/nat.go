@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github/shawn/ip-tool/internal/cli"
+	"github/shawn/ip-tool/internal/output"
+	"github/shawn/ip-tool/internal/stun"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultSTUNServer 默认的 STUN 服务器 (支持 RFC 5780 行为探测)
+const defaultSTUNServer = "stun.stunprotocol.org:3478"
+
+// natFamily --family: 4 或 6
+var natFamily string
+
+var natCmd = &cobra.Command{
+	Use:   "nat [server[:port]]",
+	Short: "Discover public UDP address and NAT behavior with STUN",
+	Long: `Discover the public address and port of a UDP socket and classify the
+NAT in front of this host (RFC 5780):
+
+  Mapping    Does the public port change with the destination?
+             endpoint-independent, address-dependent or
+             address-and-port-dependent (symmetric NAT)
+  Filtering  Which outside hosts may send back through the mapping?
+  Hairpin    Can hosts behind the NAT reach each other via the
+             public address?
+
+Symmetric mapping defeats UDP hole punching: VoIP and WebRTC peers
+behind it need a TURN relay.
+
+The server must support RFC 5780 (two IPs and two ports) to classify
+the NAT; other servers only report the mapped address. The default
+server is ` + defaultSTUNServer + `.
+
+EXAMPLES:
+  ipq nat
+  ipq nat stun.example.com:3478
+  ipq nat --family 6
+  ipq nat -q                  Only the mapped address`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server := defaultSTUNServer
+		if len(args) == 1 {
+			server = args[0]
+		}
+		server, err := withDefaultPort(server, "3478")
+		if err != nil {
+			return output.NewError("Invalid STUN server", err.Error(), "ipq nat stun.example.com:3478").WithCode(cli.ExitInvalidArgs)
+		}
+
+		network := "udp4"
+		switch natFamily {
+		case "4", "":
+		case "6":
+			network = "udp6"
+		default:
+			return output.NewError("Invalid family", fmt.Sprintf("Family: %s", natFamily), "Use 4 or 6: ipq nat --family 6").WithCode(cli.ExitInvalidArgs)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), 30*time.Second)
+		defer cancel()
+
+		report, err := stun.Discover(ctx, server, network)
+		if err != nil {
+			return output.NewError(
+				"STUN request failed",
+				fmt.Sprintf("%s: %v", server, err),
+				"Check that outbound UDP is allowed, or try another server: ipq nat stun.l.google.com:19302",
+			).WithCode(cli.ExitNetworkError).WithCause(err)
+		}
+
+		switch format := getFormat(); {
+		case format.IsMachine():
+			return output.Encode(report, format)
+		case format == output.FormatQuiet:
+			fmt.Println(report.Mapped)
+			return nil
+		}
+
+		printNAT(report)
+		return nil
+	},
+}
+
+// withDefaultPort 没有端口时补上 port，返回 host:port
+func withDefaultPort(s, port string) (string, error) {
+	if _, _, err := net.SplitHostPort(s); err == nil {
+		return s, nil
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	if host == "" || (strings.Contains(host, ":") && net.ParseIP(host) == nil) {
+		return "", fmt.Errorf("Input: %s", s)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// printNAT 输出文本格式的 NAT 探测结果
+func printNAT(r *stun.NATReport) {
+	fmt.Printf("Server:      %s\n", r.Server)
+	fmt.Printf("Local:       %s\n", r.Local)
+	fmt.Printf("Mapped:      %s\n", r.Mapped)
+
+	nat := "yes"
+	if !r.NAT {
+		nat = "no (mapped address is the local address)"
+	}
+	fmt.Printf("NAT:         %s\n", nat)
+	fmt.Printf("Mapping:     %s\n", r.Mapping)
+	fmt.Printf("Filtering:   %s\n", r.Filtering)
+
+	hairpin := "not tested"
+	if r.Hairpinning != nil {
+		hairpin = "no"
+		if *r.Hairpinning {
+			hairpin = "yes"
+		}
+	}
+	fmt.Printf("Hairpinning: %s\n", hairpin)
+	fmt.Printf("Type:        %s\n", r.Type)
+
+	for _, n := range r.Notes {
+		fmt.Println(output.StyleHint.Render("  " + n))
+	}
+
+	switch r.Type {
+	case "symmetric":
+		fmt.Println(output.StyleWarning.Render("Peer-to-peer UDP needs a TURN relay behind this NAT"))
+	case "full cone", "restricted cone", "port-restricted cone":
+		fmt.Println(output.StyleSuccess.Render("UDP hole punching should work"))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(natCmd)
+
+	natCmd.Flags().StringVar(&natFamily, "family", "4", "Address family: 4 or 6")
+	natCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format: json, yaml, text, quiet")
+	natCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Only output the mapped address")
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github/shawn/ip-tool/internal/neigh"
+	"github/shawn/ip-tool/internal/output"
+
+	"github.com/spf13/cobra"
+)
+
+// neigh 过滤条件
+var (
+	neighIface  string
+	neighFamily string
+)
+
+var neighCmd = &cobra.Command{
+	Use:   "neigh",
+	Short: "Show the ARP / IPv6 neighbor table with MAC vendors",
+	Long: `Show hosts in the kernel neighbor cache (ARP for IPv4, NDP for IPv6).
+
+Each MAC address is annotated with its vendor from the OUI database.
+Locally administered MACs (virtual machines, containers, phones with
+randomized MACs) have no vendor and are marked instead.
+
+The table only contains hosts this machine has talked to recently.
+It is not a network scan.
+
+EXAMPLES:
+  ipq neigh                  All neighbors
+  ipq neigh --iface eth0     Only neighbors on eth0
+  ipq neigh --family 4       Only ARP entries
+  ipq neigh -o json          JSON output`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		list, err := neigh.List()
+		if err != nil {
+			return output.NewError("Cannot read neighbor table", err.Error(), "ipq local")
+		}
+
+		filtered := []neigh.Neighbor{}
+		for _, n := range list {
+			if neighIface != "" && n.Interface != neighIface {
+				continue
+			}
+			if neighFamily != "" && n.Family != "ipv"+neighFamily {
+				continue
+			}
+			filtered = append(filtered, n)
+		}
+
+		switch format := getFormat(); {
+		case format.IsMachine():
+			return output.Encode(filtered, format)
+		case format == output.FormatQuiet:
+			for _, n := range filtered {
+				fmt.Println(n.IP)
+			}
+			return nil
+		}
+
+		if len(filtered) == 0 {
+			fmt.Println(output.StyleHint.Render("No neighbors"))
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "IP\tTYPE\tMAC\tVENDOR\tIFACE\tSTATE")
+		for _, n := range filtered {
+			state := n.State
+			if n.Router {
+				state += ",router"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				n.IP, n.Type, orDash(n.MAC), orDash(vendorOrNote(n.Vendor, n.Note)), n.Interface, state)
+		}
+		return w.Flush()
+	},
+}
+
+// vendorOrNote 厂商名，未知时用 MAC 说明代替
+func vendorOrNote(vendor, note string) string {
+	if vendor != "" {
+		return vendor
+	}
+	return note
+}
+
+func init() {
+	rootCmd.AddCommand(neighCmd)
+
+	neighCmd.Flags().StringVar(&neighIface, "iface", "", "Only show neighbors on this interface")
+	neighCmd.Flags().StringVar(&neighFamily, "family", "", "Only show neighbors of this family: 4 or 6")
+	neighCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format: json, yaml, text, quiet")
+	neighCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Only output neighbor IPs")
+}
@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github/shawn/ip-tool/internal/history"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/*.golden from the current output")
+
+// 输出契约: 每条 -o json 输出路径的 golden 文件都要符合 ipq schema 生成的 schema
+//
+// 字段改名或删除时 golden 比较失败；golden 与 schema 不一致时校验失败。
+// 有意的变更用 go test ./cmd -update 重新生成 golden，破坏性变更同时增加 schema_version
+
+// goldenCase 一条输出路径
+type goldenCase struct {
+	name   string   // testdata/NAME.golden
+	args   []string // 不需要网络的查询: IP 字面量，或本地即可判定无效的域名
+	stderr bool     // 比较标准错误 (错误输出)
+	schema string   // ipq schema NAME
+	ndjson bool     // 每行一个值，逐行校验
+}
+
+var goldenCases = []goldenCase{
+	{name: "result", args: []string{"192.168.1.1", "-o", "json"}, schema: "result"},
+	{name: "result-failed", args: []string{"bad host!", "-o", "json"}, schema: "result"},
+	{name: "batch", args: []string{"-f", "testdata/batch.txt", "-o", "json"}, schema: "batch"},
+	{name: "batch-ndjson", args: []string{"-f", "testdata/batch.txt", "-o", "ndjson"}, schema: "result", ndjson: true},
+	{name: "error", args: []string{"bad host!", "-o", "json"}, stderr: true, schema: "error"},
+	{name: "error-usage", args: []string{"--retries", "99", "8.8.8.8", "-o", "json"}, stderr: true, schema: "error"},
+}
+
+func TestGoldenOutput(t *testing.T) {
+	history.Configure(false, 0)
+	t.Cleanup(func() { history.Configure(true, 0) })
+
+	for _, tt := range goldenCases {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, _ := runCommand(t, tt.args...)
+			got := stdout
+			if tt.stderr {
+				got = stderr
+			}
+			got = normalize(got)
+
+			path := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run go test ./cmd -update to create it)", err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %s (run go test ./cmd -update if intended)\ngot:\n%s\nwant:\n%s", path, got, want)
+			}
+		})
+	}
+}
+
+// 每个 golden 文件都要符合生成的 schema
+func TestGoldenMatchesSchema(t *testing.T) {
+	for _, tt := range goldenCases {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.name+".golden"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc := loadSchema(t, tt.schema)
+
+			values := [][]byte{data}
+			if tt.ndjson {
+				values = bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+			}
+			for i, raw := range values {
+				var v any
+				if err := json.Unmarshal(raw, &v); err != nil {
+					t.Fatalf("value %d: %v", i, err)
+				}
+				for _, e := range (&validator{root: doc}).validate(doc, v, "$") {
+					t.Errorf("value %d: %s", i, e)
+				}
+			}
+		})
+	}
+}
+
+// 每个 schema 都能生成，未知名称是用法错误
+func TestSchemaCommand(t *testing.T) {
+	for _, s := range outputSchemas {
+		doc := loadSchema(t, s.name)
+		if doc["$schema"] == nil || doc["title"] != "ipq "+s.name {
+			t.Errorf("%s: unexpected header %v %v", s.name, doc["$schema"], doc["title"])
+		}
+	}
+	if _, code := execute(t, "schema", "nope"); code != 2 {
+		t.Errorf("unknown schema: exit code %d, want 2", code)
+	}
+}
+
+// loadSchema 运行 ipq schema NAME 并解析输出
+func loadSchema(t *testing.T, name string) map[string]any {
+	t.Helper()
+	out, code := execute(t, "schema", name)
+	if code != 0 {
+		t.Fatalf("ipq schema %s: exit code %d", name, code)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("ipq schema %s: %v", name, err)
+	}
+	return doc
+}
+
+// 随运行变化的字段: 各阶段耗时 (duration_ms 及其中的数字) 和开始时间
+var (
+	durationRE = regexp.MustCompile(`("(?:duration_ms|ipv4|ipv6|geo|total)":\s*)\d+`)
+	startedRE  = regexp.MustCompile(`("started_at":\s*)"[^"]*"`)
+)
+
+// normalize 把随运行变化的字段替换为固定值，其余内容 (字段顺序、缩进) 保持原样
+func normalize(out string) string {
+	out = durationRE.ReplaceAllString(out, "${1}0")
+	return startedRE.ReplaceAllString(out, `${1}"2006-01-02T15:04:05Z"`)
+}
+
+// validator 按 ipq schema 用到的 JSON Schema 子集校验 (见 internal/schema)
+//
+// 未声明的属性也算错误: schema 由类型生成，输出中出现未声明的字段说明两者已不一致
+type validator struct {
+	root map[string]any
+}
+
+func (v *validator) validate(s map[string]any, x any, path string) []string {
+	if ref, ok := s["$ref"].(string); ok {
+		return v.validate(v.resolve(ref), x, path)
+	}
+	if anyOf, ok := s["anyOf"].([]any); ok {
+		var errs []string
+		for _, alt := range anyOf {
+			e := v.validate(alt.(map[string]any), x, path)
+			if len(e) == 0 {
+				return nil
+			}
+			errs = append(errs, e...)
+		}
+		return errs
+	}
+
+	if typ, ok := s["type"]; ok && !typeMatches(typ, x) {
+		return []string{fmt.Sprintf("%s: want type %v, got %T", path, typ, x)}
+	}
+	var errs []string
+	if c, ok := s["const"]; ok && !reflect.DeepEqual(c, x) {
+		errs = append(errs, fmt.Sprintf("%s: want %v, got %v", path, c, x))
+	}
+
+	switch x := x.(type) {
+	case map[string]any:
+		required, _ := s["required"].([]any)
+		for _, r := range required {
+			if _, ok := x[r.(string)]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: missing required %q", path, r))
+			}
+		}
+		props, hasProps := s["properties"].(map[string]any)
+		additional, hasAdditional := s["additionalProperties"].(map[string]any)
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			switch {
+			case props[k] != nil:
+				errs = append(errs, v.validate(props[k].(map[string]any), x[k], path+"."+k)...)
+			case hasAdditional:
+				errs = append(errs, v.validate(additional, x[k], path+"."+k)...)
+			case hasProps:
+				errs = append(errs, fmt.Sprintf("%s: property %q is not in the schema", path, k))
+			}
+		}
+	case []any:
+		if items, ok := s["items"].(map[string]any); ok {
+			for i, item := range x {
+				errs = append(errs, v.validate(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return errs
+}
+
+// resolve 解析 "#" 和 "#/$defs/NAME"
+func (v *validator) resolve(ref string) map[string]any {
+	if ref == "#" {
+		return v.root
+	}
+	defs, _ := v.root["$defs"].(map[string]any)
+	s, _ := defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any)
+	if s == nil {
+		return map[string]any{"not": "unresolved " + ref, "type": "unresolved"}
+	}
+	return s
+}
+
+// typeMatches x 是否为 typ (字符串或字符串数组) 之一
+func typeMatches(typ, x any) bool {
+	types, ok := typ.([]any)
+	if !ok {
+		types = []any{typ}
+	}
+	for _, t := range types {
+		switch t {
+		case "string":
+			_, ok = x.(string)
+		case "boolean":
+			_, ok = x.(bool)
+		case "object":
+			_, ok = x.(map[string]any)
+		case "array":
+			_, ok = x.([]any)
+		case "null":
+			ok = x == nil
+		case "number":
+			_, ok = x.(float64)
+		case "integer":
+			f, isNum := x.(float64)
+			ok = isNum && f == float64(int64(f))
+		default:
+			ok = false
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// 子命令没有表格输出，-o csv/tsv 是用法错误，不能悄悄输出文本
+func TestSubcommandRejectsTableFormat(t *testing.T) {
+	for _, args := range [][]string{
+		{"neigh", "-o", "csv"},
+		{"route", "list", "-o", "tsv"},
+		{"history", "list", "-o", "csv"},
+		{"doctor", "-o", "tsv"},
+	} {
+		stdout, stderr, code := runCommand(t, args...)
+		if code != 2 || stdout != "" {
+			t.Errorf("ipq %s: exit code %d, stdout %q; want 2 and no output", strings.Join(args, " "), code, stdout)
+		}
+		if !strings.Contains(stderr, "Unsupported output format") {
+			t.Errorf("ipq %s: stderr = %q", strings.Join(args, " "), stderr)
+		}
+	}
+}
@@ -22,12 +22,16 @@ CLI Guidelines 原则:
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github/shawn/ip-tool/internal/cli"
+	"github/shawn/ip-tool/internal/history"
 	"github/shawn/ip-tool/internal/ip"
+	"github/shawn/ip-tool/internal/network"
 	"github/shawn/ip-tool/internal/output"
 	"github/shawn/ip-tool/internal/tui"
 
@@ -44,8 +48,22 @@ var (
 	batch         bool   // --batch: 批量处理
 	inputFile     string // -f: 输入文件
 	outputFormat  string // -o: 输出格式
+
+	lookupDeadline time.Duration // --deadline: 每次查询的总期限
+	failFast       bool          // --fail-fast: 批量查询第一个失败后停止
+	maxFailures    int           // --max-failures: 批量查询失败数上限
+	unordered      bool          // --unordered: 批量查询并发进行，按完成顺序输出
+	concurrency    int           // --concurrency: --unordered 时同时进行的查询数
+	fields         string        // --fields: CSV/TSV 输出的列
+
+	proxyURL   string // --proxy: 出站代理 (全局)
+	proxyDNS   string // --proxy-dns: 经 SOCKS5 代理查询 DNS (全局)
+	retryCount int    // --retries: 可重试的网络错误的重试次数 (全局)
 )
 
+// config 配置文件 (在 Execute 中加载)
+var config = cli.DefaultConfig()
+
 var rootCmd = &cobra.Command{
 	Use:   "ipq [target]",
 	Short: "Query IP addresses and domains",
@@ -59,11 +77,18 @@ EXAMPLES:
   echo "8.8.8.8" | ipq   Read from stdin
   ipq -f ips.txt         Batch from file
   ipq 8.8.8.8 -o json    JSON output
+  ipq -f ips.txt -o ndjson --unordered   Stream results, 8 lookups at a time
+  ipq -f ips.txt -d -o csv --fields target,ipv4,country,isp   Spreadsheet
+  ipq -f ips.txt --deadline 3s   At most 3s per target
 
 ENVIRONMENT:
   NO_COLOR               Disable colors
   CI                     Force non-interactive mode
-  IPQ_CONFIG             Config file path`,
+  IPQ_CONFIG             Config file path
+  XDG_STATE_HOME         Query history location
+  XDG_DATA_HOME          Downloaded OUI database location
+  IPQ_TSIG_KEY           TSIG key for ipq ddns update
+  HTTPS_PROXY, NO_PROXY  Used when --proxy and the proxy config key are unset`,
 
 	SilenceUsage:  true, // 错误时不打印用法
 	SilenceErrors: true, // 错误由我们处理
@@ -76,7 +101,7 @@ ENVIRONMENT:
 				"Cannot use both clipboard and target argument",
 				"",
 				"ipq -c",
-			)
+			).WithCode(cli.ExitInvalidArgs)
 		}
 		// 最多一个参数
 		if len(args) > 1 {
@@ -84,7 +109,35 @@ ENVIRONMENT:
 				"Too many arguments",
 				"",
 				"ipq 8.8.8.8",
-			)
+			).WithCode(cli.ExitInvalidArgs)
+		}
+		if failFast && cmd.Flags().Changed("max-failures") {
+			return output.NewError(
+				"Cannot use both --fail-fast and --max-failures",
+				"--fail-fast is --max-failures 1",
+				"ipq -f ips.txt --max-failures 5",
+			).WithCode(cli.ExitInvalidArgs)
+		}
+		if maxFailures < 0 {
+			return output.NewError(
+				"Invalid --max-failures",
+				fmt.Sprintf("Value: %d", maxFailures),
+				"Use 0 (no limit) or a positive number",
+			).WithCode(cli.ExitInvalidArgs)
+		}
+		if concurrency < 1 || concurrency > 64 {
+			return output.NewError(
+				"Invalid --concurrency",
+				fmt.Sprintf("Value: %d", concurrency),
+				"Use a number between 1 and 64",
+			).WithCode(cli.ExitInvalidArgs)
+		}
+		if cmd.Flags().Changed("concurrency") && !unordered {
+			return output.NewError(
+				"--concurrency needs --unordered",
+				"Ordered batches look up one target at a time",
+				"ipq -f ips.txt -o ndjson --unordered --concurrency 16",
+			).WithCode(cli.ExitInvalidArgs)
 		}
 		return nil
 	},
@@ -95,43 +148,158 @@ ENVIRONMENT:
 // run 主逻辑
 func run(cmd *cobra.Command, args []string) error {
 	format := getFormat()
+	if err := configureDeadline(cmd); err != nil {
+		return err
+	}
+	if err := configureFields(cmd, format); err != nil {
+		return err
+	}
+	ctx := cmd.Context()
 
 	// 批量处理
-	if inputFile != "" {
-		return cli.ProcessBatchFile(inputFile, showDetail, format, quiet)
-	}
-	if batch && cli.HasStdin() {
-		return cli.ProcessBatchStdin(showDetail, format, quiet)
+	if inputFile != "" || (batch && cli.HasStdin()) {
+		if useListView() {
+			return runList(ctx)
+		}
+		opts := cli.BatchOptions{
+			Detail:      showDetail,
+			Format:      format,
+			Quiet:       quiet,
+			MaxFailures: maxFailures,
+			Unordered:   unordered,
+			Concurrency: concurrency,
+		}
+		if failFast {
+			opts.MaxFailures = 1
+		}
+		if inputFile != "" {
+			return cli.ProcessBatchFile(ctx, inputFile, opts)
+		}
+		return cli.ProcessBatchStdin(ctx, opts)
 	}
 
 	// 获取目标
-	target, err := getTarget(args)
+	q, err := getTarget(args)
 	if err != nil {
 		return err
 	}
 
-	// 输出
+	return lookup(ctx, q, format)
+}
+
+// configureDeadline 应用每次查询的总期限
+//
+// 优先级: --deadline > 配置文件 deadline > 不限制
+func configureDeadline(cmd *cobra.Command) error {
+	d := lookupDeadline
+	if !cmd.Flags().Changed("deadline") && config.Deadline != "" {
+		var err error
+		if d, err = time.ParseDuration(config.Deadline); err != nil {
+			return output.NewError(
+				"Invalid deadline in config",
+				fmt.Sprintf("deadline: %s", config.Deadline),
+				"Use a duration such as 3s or 1500ms",
+			).WithCode(cli.ExitInvalidArgs)
+		}
+	}
+	if d < 0 {
+		return output.NewError("Invalid deadline", fmt.Sprintf("Deadline: %s", d), "ipq --deadline 3s 8.8.8.8").WithCode(cli.ExitInvalidArgs)
+	}
+	output.SetDeadline(d)
+	return nil
+}
+
+// configureFields 应用 --fields 选择的 CSV/TSV 列
+func configureFields(cmd *cobra.Command, format output.Format) error {
+	if !cmd.Flags().Changed("fields") {
+		return nil
+	}
+	if !format.IsTable() {
+		return output.NewError(
+			"--fields needs -o csv or -o tsv",
+			"",
+			"ipq -f ips.txt -d -o csv --fields "+fields,
+		).WithCode(cli.ExitInvalidArgs)
+	}
+	if err := output.SetFields(fields); err != nil {
+		return output.NewError(
+			"Invalid --fields",
+			err.Error(),
+			"Available: "+strings.Join(output.Fields(), ","),
+		).WithCode(cli.ExitInvalidArgs)
+	}
+	return nil
+}
+
+// query 一次查询的目标及其来源 (用于查询历史)
+type query struct {
+	target string // 提取后的目标
+	input  string // 原始输入
+	source string // 输入来源
+}
+
+// lookup 查询单个目标并输出
+func lookup(ctx context.Context, q query, format output.Format) error {
 	if format == output.FormatTUI && cli.IsInteractive() {
-		p := tea.NewProgram(tui.NewApp(target, showDetail))
+		app := tui.NewApp(ctx, q.target, showDetail)
+		app.SetSource(q.source, q.input)
+		p := tea.NewProgram(app)
 		if _, err := p.Run(); err != nil {
 			return output.NewError("Application error", err.Error(), "")
 		}
+		return app.Err()
+	}
+
+	result := output.FetchResult(ctx, q.target, showDetail)
+	if cli.Interrupted(ctx) {
+		return cli.ErrInterrupted
+	}
+	output.Record(result, q.input, q.source)
+	if err := output.PrintResult(result, showDetail, format); err != nil {
+		return err
+	}
+	return result.Err()
+}
+
+// useListView 批量模式是否使用 TUI 列表视图
+//
+// stdin 可以是管道 (cat ips.txt | ipq --batch)，只要 stdout 是终端
+// 且未指定其他输出格式即可
+func useListView() bool {
+	return outputFormat == "" && !quiet && cli.IsTerminalOutput()
+}
+
+// runList 读取全部目标并启动列表视图
+func runList(ctx context.Context) error {
+	var targets []string
+	var err error
+	if inputFile != "" {
+		targets, err = cli.ReadTargetsFile(inputFile)
 	} else {
-		return output.Print(target, showDetail, format)
+		targets, err = cli.ReadTargetsStdin(quiet)
+	}
+	if err != nil {
+		return err
 	}
 
-	return nil
+	// stdin 已被读尽，Bubble Tea 会自动改用 /dev/tty 读取按键
+	list := tui.NewList(ctx, targets)
+	p := tea.NewProgram(list, tea.WithAltScreen())
+	if _, err := p.Run(); err != nil {
+		return output.NewError("Application error", err.Error(), "")
+	}
+	return list.Err()
 }
 
 // getTarget 获取查询目标
 //
 // 优先级: 剪贴板 > 参数 > stdin > 空 (本机)
-func getTarget(args []string) (string, error) {
+func getTarget(args []string) (query, error) {
 	// 从剪贴板
 	if fromClipboard {
 		content, err := clipboard.ReadAll()
 		if err != nil {
-			return "", output.NewError(
+			return query{}, output.NewError(
 				"Failed to read clipboard",
 				"",
 				"Copy an IP or domain, then run: ipq -c",
@@ -140,7 +308,7 @@ func getTarget(args []string) (string, error) {
 
 		content = strings.TrimSpace(content)
 		if content == "" {
-			return "", output.NewError(
+			return query{}, output.NewError(
 				"Clipboard is empty",
 				"",
 				"Copy an IP address or domain name first",
@@ -149,35 +317,35 @@ func getTarget(args []string) (string, error) {
 
 		target := ip.ExtractFromURL(content)
 		if !ip.IsValidTarget(target) {
-			return "", output.NewError(
+			return query{}, output.NewError(
 				"Invalid clipboard content",
 				fmt.Sprintf("Content: %s", content),
 				"Copy a valid IP or domain",
-			)
+			).WithCode(cli.ExitInvalidArgs)
 		}
-		return target, nil
+		return query{target, content, history.SourceClipboard}, nil
 	}
 
 	// 从参数
 	if len(args) > 0 {
-		return args[0], nil
+		return query{args[0], args[0], history.SourceArg}, nil
 	}
 
 	// 从 stdin
 	if cli.HasStdin() {
 		target, err := cli.ReadStdin()
 		if err != nil {
-			return "", output.NewError(
+			return query{}, output.NewError(
 				"Failed to read stdin",
 				err.Error(),
 				"echo '8.8.8.8' | ipq",
 			)
 		}
-		return target, nil
+		return query{target, "", history.SourceStdin}, nil
 	}
 
 	// 空目标 = 查询本机
-	return "", nil
+	return query{source: history.SourceDefault}, nil
 }
 
 // getFormat 确定输出格式
@@ -187,6 +355,12 @@ func getFormat() output.Format {
 		return output.FormatJSON
 	case "yaml":
 		return output.FormatYAML
+	case "ndjson", "jsonl":
+		return output.FormatNDJSON
+	case "csv":
+		return output.FormatCSV
+	case "tsv":
+		return output.FormatTSV
 	case "text":
 		return output.FormatText
 	case "quiet":
@@ -205,12 +379,96 @@ func getFormat() output.Format {
 	return output.FormatTUI
 }
 
+// persistentPreRun 处理全局标志，对所有子命令生效
+func persistentPreRun(cmd *cobra.Command, args []string) error {
+	// -V/--version: 直接打印版本并以 0 退出（避免进入主逻辑）
+	if v, _ := cmd.Flags().GetBool("version"); v {
+		fmt.Printf("ipq %s\n", Version)
+		os.Exit(0)
+	}
+	if err := checkTableFormat(cmd); err != nil {
+		return err
+	}
+	if err := configureRetries(cmd); err != nil {
+		return err
+	}
+	return configureProxy(cmd)
+}
+
+// checkTableFormat -o csv/tsv 只有查询结果支持
+//
+// 子命令没有表格输出，不能悄悄改为文本: 下游按 CSV 解析会得到错误的数据
+func checkTableFormat(cmd *cobra.Command) error {
+	if cmd == rootCmd || !getFormat().IsTable() {
+		return nil
+	}
+	return output.NewError(
+		"Unsupported output format",
+		fmt.Sprintf("%s has no %s output", cmd.CommandPath(), outputFormat),
+		fmt.Sprintf("Use -o json, e.g. %s -o json; csv and tsv are for lookups (ipq -f ips.txt -o csv)", cmd.CommandPath()),
+	).WithCode(cli.ExitInvalidArgs)
+}
+
+// configureRetries 应用重试次数
+//
+// 优先级: --retries > 配置文件 retries > 默认 (network.DefaultRetries)
+func configureRetries(cmd *cobra.Command) error {
+	n := retryCount
+	if !cmd.Flags().Changed("retries") {
+		n = config.Retries
+	}
+	if err := network.SetRetries(n); err != nil {
+		return output.NewError("Invalid retries", err.Error(), "ipq --retries 0 8.8.8.8").WithCode(cli.ExitInvalidArgs)
+	}
+	return nil
+}
+
+// configureProxy 应用代理设置
+//
+// 优先级: --proxy/--proxy-dns > 配置文件 proxy/proxy_dns > HTTPS_PROXY 等环境变量
+func configureProxy(cmd *cobra.Command) error {
+	proxy := flagOrConfig(cmd, "proxy", proxyURL, config.Proxy)
+	if err := network.SetProxy(proxy); err != nil {
+		return output.NewError(
+			"Invalid proxy",
+			err.Error(),
+			"ipq --proxy socks5://jump.example.com:1080 8.8.8.8",
+		).WithCode(cli.ExitInvalidArgs)
+	}
+
+	dns := flagOrConfig(cmd, "proxy-dns", proxyDNS, config.ProxyDNS)
+	if dns == "" {
+		return nil
+	}
+	if err := network.SetProxyDNS(dns); err != nil {
+		return output.NewError(
+			"Cannot send DNS through the proxy",
+			err.Error(),
+			"ipq --proxy socks5://jump.example.com:1080 --proxy-dns 8.8.8.8 example.com",
+		).WithCode(cli.ExitInvalidArgs)
+	}
+	return nil
+}
+
 // Execute CLI 入口点
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		// CLI Guidelines: 错误输出到 stderr
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(cli.ExitGeneralError)
+	// 配置文件中的历史设置
+	config = cli.LoadConfig()
+	history.Configure(config.History, config.HistoryLimit)
+	if err := network.ConfigureSources(config.IPSources); err != nil {
+		fmt.Fprintln(os.Stderr, output.StyleWarning.Render("Warning: ip_sources: "+err.Error()))
+	}
+
+	// Ctrl+C/SIGTERM 取消进行中的请求，命令输出已有结果后退出
+	ctx, stop := cli.SignalContext()
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
+		// CLI Guidelines: 错误输出到 stderr，退出码区分失败原因
+		// -o json/yaml 时错误也是 JSON/YAML
+		code := cli.ExitCode(err)
+		output.PrintError(err, code, getFormat())
+		os.Exit(code)
 	}
 }
 
@@ -223,8 +481,25 @@ func init() {
 	// 输入选项
 	rootCmd.Flags().StringVarP(&inputFile, "file", "f", "", "Read targets from file")
 	rootCmd.Flags().BoolVar(&batch, "batch", false, "Batch process from stdin")
+	rootCmd.Flags().DurationVar(&lookupDeadline, "deadline", 0, "Total time per lookup, shared by DNS, public IP and geo stages (0 = no limit)")
+	rootCmd.Flags().BoolVar(&failFast, "fail-fast", false, "Stop a batch at the first failed lookup")
+	rootCmd.Flags().IntVar(&maxFailures, "max-failures", 0, "Stop a batch after this many failed lookups (0 = no limit)")
+	rootCmd.Flags().BoolVar(&unordered, "unordered", false, "Look up batch targets concurrently and print results as they finish")
+	rootCmd.Flags().IntVar(&concurrency, "concurrency", cli.DefaultConcurrency, "Lookups in flight with --unordered")
 
 	// 输出选项
-	rootCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format: json, yaml, text, quiet")
+	rootCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format: json, ndjson, yaml, csv, tsv, text, quiet")
+	rootCmd.Flags().StringVar(&fields, "fields", "", "Comma-separated columns for csv/tsv, in order (default all)")
 	rootCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Only output IP addresses")
+
+	// 全局网络选项
+	rootCmd.PersistentFlags().StringVar(&proxyURL, "proxy", "", "Send requests through a proxy: http://, https://, socks5://, socks5h://")
+	rootCmd.PersistentFlags().StringVar(&proxyDNS, "proxy-dns", "", "Resolve DNS through the SOCKS5 proxy using this server (socks5h:// uses "+network.DefaultProxyDNS+")")
+	rootCmd.PersistentFlags().IntVar(&retryCount, "retries", network.DefaultRetries, "Retries for timeouts, unreachable services and rate limits (0 = no retries)")
+	rootCmd.PersistentPreRunE = persistentPreRun
+
+	// 未知标志、标志值无效: 退出码 2
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return cli.WithCode(cli.ExitInvalidArgs, err)
+	})
 }
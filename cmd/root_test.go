@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github/shawn/ip-tool/internal/cli"
+)
+
+// 配置文件中无效的期限与无效的 --deadline 一样是用法错误
+func TestInvalidDeadline(t *testing.T) {
+	orig := config
+	t.Cleanup(func() { config = orig })
+	config = cli.DefaultConfig()
+	config.Deadline = "soon"
+
+	for _, args := range [][]string{
+		{"192.168.1.1"},
+		{"--deadline", "-1s", "192.168.1.1"},
+	} {
+		_, stderr, code := runCommand(t, args...)
+		if code != 2 || !strings.Contains(stderr, "Invalid deadline") {
+			t.Errorf("ipq %s: exit code %d, stderr %q; want 2", strings.Join(args, " "), code, stderr)
+		}
+	}
+}
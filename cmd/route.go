@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github/shawn/ip-tool/internal/cli"
+	"github/shawn/ip-tool/internal/ip"
+	"github/shawn/ip-tool/internal/network"
+	"github/shawn/ip-tool/internal/output"
+	"github/shawn/ip-tool/internal/route"
+
+	"github.com/spf13/cobra"
+)
+
+// routeFamily --family: 只显示 IPv4 或 IPv6 路由
+var routeFamily string
+
+var routeCmd = &cobra.Command{
+	Use:   "route",
+	Short: "Show routes and which interface reaches a destination",
+}
+
+var routeGetCmd = &cobra.Command{
+	Use:   "get <ip|domain>",
+	Short: "Show the route, interface and source address used to reach a destination",
+	Long: `Show the outgoing interface, gateway, metric and source address used
+to reach a destination.
+
+The route comes from a longest-prefix match on /proc/net/route and
+/proc/net/ipv6_route. The source address comes from the kernel via a
+connected UDP socket, which sends no packets. When the two disagree,
+policy routing (for example a VPN client) is usually involved.
+
+EXAMPLES:
+  ipq route get 8.8.8.8
+  ipq route get 2001:4860:4860::8888
+  ipq route get google.com -o json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dest, err := resolveDestination(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		res := route.Get(dest)
+		format := getFormat()
+		if format.IsMachine() {
+			return output.Encode(res, format)
+		}
+		if format == output.FormatQuiet {
+			if res.Source != "" {
+				fmt.Println(res.Source)
+			}
+			return nil
+		}
+
+		fmt.Printf("Destination: %s [%s]\n", res.Destination, ip.Classify(res.Destination))
+		if res.Route != nil {
+			fmt.Printf("Route: %s\n", res.Route)
+			fmt.Printf("Interface: %s\n", res.Route.Interface)
+			fmt.Printf("Gateway: %s\n", orDash(res.Route.Gateway))
+			fmt.Printf("Metric: %d\n", res.Route.Metric)
+		} else if res.TableError != "" {
+			fmt.Printf("Route: %s\n", output.StyleHint.Render(res.TableError))
+		} else {
+			fmt.Printf("Route: %s\n", output.StyleError.Render("no matching route"))
+		}
+
+		if res.Source == "" {
+			fmt.Printf("Source: %s\n", output.StyleError.Render("unreachable (kernel has no route)"))
+			return nil
+		}
+		fmt.Printf("Source: %s [%s] on %s\n", res.Source, res.SourceType, orDash(res.SourceInterface))
+
+		if res.Route != nil {
+			if res.Consistent {
+				fmt.Println("Check: " + output.StyleSuccess.Render("✓ kernel source address matches the route"))
+			} else {
+				fmt.Println("Check: " + output.StyleWarning.Render(fmt.Sprintf(
+					"kernel picked %s, route table says %s (policy routing?)",
+					orDash(res.SourceInterface), res.Route.Interface)))
+			}
+		}
+		return nil
+	},
+}
+
+var routeListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the routing table with classified prefixes",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		routes, err := route.Table()
+		if err != nil {
+			return output.NewError("Cannot read routing table", err.Error(), "ipq route get 8.8.8.8")
+		}
+
+		filtered := []route.Route{}
+		for _, r := range routes {
+			if routeFamily == "" || r.Family == "ipv"+routeFamily {
+				filtered = append(filtered, r)
+			}
+		}
+
+		if format := getFormat(); format.IsMachine() {
+			return output.Encode(filtered, format)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DESTINATION\tTYPE\tGATEWAY\tIFACE\tMETRIC\tFLAGS")
+		for _, r := range filtered {
+			dest := r.Destination
+			if r.IsDefault() {
+				dest = "default"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n",
+				dest, r.Type, orDash(r.Gateway), r.Interface, r.Metric, strings.Join(r.Flags, ","))
+		}
+		return w.Flush()
+	},
+}
+
+// resolveDestination 解析目的地址，域名取第一个 IPv4 (没有则 IPv6)
+func resolveDestination(ctx context.Context, arg string) (net.IP, error) {
+	target := ip.ExtractFromURL(arg)
+	if dest := net.ParseIP(target); dest != nil {
+		return dest, nil
+	}
+
+	if !ip.IsValidTarget(target) {
+		return nil, output.NewError(
+			"Invalid destination",
+			fmt.Sprintf("Input: %s", arg),
+			"ipq route get 8.8.8.8",
+		).WithCode(cli.ExitInvalidArgs)
+	}
+
+	addr, err := network.LookupIPv4(ctx, target)
+	if err != nil {
+		addr, err = network.LookupIPv6(ctx, target)
+	}
+	if err != nil {
+		return nil, output.NewError(
+			"Cannot resolve destination",
+			err.Error(),
+			"Use an IP address: ipq route get 8.8.8.8",
+		).WithCause(err)
+	}
+	return net.ParseIP(addr), nil
+}
+
+func init() {
+	rootCmd.AddCommand(routeCmd)
+	routeCmd.AddCommand(routeGetCmd, routeListCmd)
+
+	routeGetCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format: json, yaml, text, quiet")
+	routeGetCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Only output the source address")
+
+	routeListCmd.Flags().StringVar(&routeFamily, "family", "", "Only show routes of this family: 4 or 6")
+	routeListCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format: json, yaml, text")
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github/shawn/ip-tool/internal/cli"
+	"github/shawn/ip-tool/internal/conns"
+	"github/shawn/ip-tool/internal/ddns"
+	"github/shawn/ip-tool/internal/dnsleak"
+	"github/shawn/ip-tool/internal/doctor"
+	"github/shawn/ip-tool/internal/gateway"
+	"github/shawn/ip-tool/internal/history"
+	"github/shawn/ip-tool/internal/local"
+	"github/shawn/ip-tool/internal/mac"
+	"github/shawn/ip-tool/internal/neigh"
+	"github/shawn/ip-tool/internal/network"
+	"github/shawn/ip-tool/internal/output"
+	"github/shawn/ip-tool/internal/route"
+	"github/shawn/ip-tool/internal/schema"
+	"github/shawn/ip-tool/internal/stun"
+	"github/shawn/ip-tool/internal/watch"
+
+	"github.com/spf13/cobra"
+)
+
+// outputSchema 一种 JSON 输出的 schema
+type outputSchema struct {
+	name  string
+	desc  string // 哪个命令输出
+	value any    // 输出使用的类型的零值
+}
+
+// outputSchemas 所有 -o json 输出，新增命令的输出要加在这里
+var outputSchemas = []outputSchema{
+	{"result", "ipq TARGET (single lookup)", (*output.Result)(nil)},
+	{"batch", "ipq -f / stdin (batch lookup)", []*output.Result(nil)},
+	{"error", "errors on stderr with -o json", output.ErrorOutput{}},
+	{"history", "ipq history list / search", []history.Entry(nil)},
+	{"history-entry", "ipq history show", history.Entry{}},
+	{"sources", "ipq sources", []*network.Consensus(nil)},
+	{"local", "ipq local", (*local.Inventory)(nil)},
+	{"route", "ipq route get", (*route.Result)(nil)},
+	{"routes", "ipq route list", []route.Route(nil)},
+	{"neigh", "ipq neigh", []neigh.Neighbor(nil)},
+	{"mac", "ipq mac", (*mac.Info)(nil)},
+	{"conns", "ipq conns", []conns.Conn(nil)},
+	{"nat", "ipq nat", (*stun.NATReport)(nil)},
+	{"gateway", "ipq gateway", (*gateway.Report)(nil)},
+	{"dns-egress", "ipq dns-egress", (*dnsleak.Report)(nil)},
+	{"doctor", "ipq doctor", (*doctor.Report)(nil)},
+	{"ddns", "ipq ddns update", []ddns.Record(nil)},
+	{"watch-change", "ipq watch webhooks and change log", watch.Change{}},
+}
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema [name]",
+	Short: "Print the JSON Schema of an output format",
+	Long: `Print the JSON Schema (draft 2020-12) of a command's -o json output.
+
+The schemas are generated from the same Go types that produce the output,
+so they always match this build. Lookup results (result, batch) carry a
+schema_version field; it is bumped when a field is removed or changes meaning.
+Adding a field is not a breaking change, so consumers should ignore unknown fields.
+
+Without a name, lists the available schemas.
+
+EXAMPLES:
+  ipq schema
+  ipq schema result > result.schema.json
+  ipq schema batch -o yaml`,
+	Args: cobra.MaximumNArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		names := make([]string, len(outputSchemas))
+		for i, s := range outputSchemas {
+			names[i] = s.name + "\t" + s.desc
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			if format := getFormat(); format.IsMachine() {
+				return output.Encode(schemaNames(), format)
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, s := range outputSchemas {
+				fmt.Fprintf(w, "%s\t%s\n", s.name, s.desc)
+			}
+			return w.Flush()
+		}
+
+		s, ok := findSchema(args[0])
+		if !ok {
+			return output.NewError(
+				"Unknown schema",
+				fmt.Sprintf("Schema: %s", args[0]),
+				"Available: "+strings.Join(schemaNames(), ", "),
+			).WithCode(cli.ExitInvalidArgs)
+		}
+
+		doc := schema.Generate(s.value)
+		doc.Title = "ipq " + s.name
+		doc.Description = "Output of " + s.desc
+		if s.name == "result" || s.name == "batch" {
+			doc.Description += fmt.Sprintf(", schema_version %d", output.SchemaVersion)
+			pinVersion(doc)
+		}
+
+		// schema 本身默认输出 JSON
+		format := getFormat()
+		if format != output.FormatYAML {
+			format = output.FormatJSON
+		}
+		return output.Encode(doc, format)
+	},
+}
+
+// findSchema 按名称查找
+func findSchema(name string) (outputSchema, bool) {
+	for _, s := range outputSchemas {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return outputSchema{}, false
+}
+
+// schemaNames 所有 schema 的名称
+func schemaNames() []string {
+	names := make([]string, len(outputSchemas))
+	for i, s := range outputSchemas {
+		names[i] = s.name
+	}
+	return names
+}
+
+// pinVersion 把 Result 的 schema_version 固定为当前版本
+//
+// 校验旧版本的输出时会失败，提醒使用者字段含义可能已变化
+func pinVersion(doc *schema.Schema) {
+	result := doc
+	if r, ok := doc.Defs["Result"]; ok {
+		result = r // batch: Result 在 $defs 中
+	}
+	if p, ok := result.Properties["schema_version"]; ok {
+		p.Const = output.SchemaVersion
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github/shawn/ip-tool/internal/cli"
+	"github/shawn/ip-tool/internal/network"
+	"github/shawn/ip-tool/internal/output"
+
+	"github.com/spf13/cobra"
+)
+
+// sourcesFamily --family: 只检测 IPv4 或 IPv6
+var sourcesFamily string
+
+var sourcesCmd = &cobra.Command{
+	Use:   "sources",
+	Short: "Compare public IP answers from every detection source",
+	Long: `Ask every configured source for this host's public IP and compare the answers.
+
+Your public IP is decided by majority vote across HTTP echo services,
+DNS (OpenDNS myip.opendns.com, Google o-o.myaddr TXT) and STUN. This
+command waits for every source and shows what each one answered.
+
+Disagreement usually means:
+  - HTTP differs from DNS/STUN: a transparent HTTP proxy
+  - HTTP services differ from each other: a split-tunnel VPN
+
+Sources are set with the ip_sources config key. Available:
+  ` + strings.Join(network.SourceNames(), ", ") + `
+
+EXAMPLES:
+  ipq sources
+  ipq sources --family 4
+  ipq sources -o json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		families := []string{network.FamilyIPv4, network.FamilyIPv6}
+		switch sourcesFamily {
+		case "":
+		case "4":
+			families = families[:1]
+		case "6":
+			families = families[1:]
+		default:
+			return output.NewError("Invalid family", fmt.Sprintf("Family: %s", sourcesFamily), "Use 4 or 6: ipq sources --family 4").WithCode(cli.ExitInvalidArgs)
+		}
+
+		// 两个地址族并发检测
+		results := make([]*network.Consensus, len(families))
+		errs := make([]error, len(families))
+		done := make(chan struct{})
+		for i, family := range families {
+			go func(i int, family string) {
+				results[i], errs[i] = network.DetectPublicIP(cmd.Context(), family, true)
+				done <- struct{}{}
+			}(i, family)
+		}
+		for range families {
+			<-done
+		}
+
+		switch format := getFormat(); {
+		case format.IsMachine():
+			return output.Encode(results, format)
+		case format == output.FormatQuiet:
+			for _, c := range results {
+				if c.IP != "" {
+					fmt.Println(c.IP)
+				}
+			}
+			return nil
+		}
+
+		for i, c := range results {
+			if i > 0 {
+				fmt.Println()
+			}
+			printConsensus(c, errs[i])
+		}
+		return nil
+	},
+}
+
+// printConsensus 输出一个地址族的共识结果
+//
+// 没有查询任何来源时 (如本机没有 IPv6) 输出 err 说明原因
+func printConsensus(c *network.Consensus, err error) {
+	label := "IPv4"
+	if c.Family == network.FamilyIPv6 {
+		label = "IPv6"
+	}
+
+	queried := 0
+	for _, a := range c.Answers {
+		if !a.Skipped {
+			queried++
+		}
+	}
+
+	switch {
+	case queried == 0 && err != nil:
+		fmt.Printf("%s: %s\n", label, output.StyleError.Render("not detected: "+err.Error()))
+	case c.IP == "":
+		fmt.Printf("%s: %s\n", label, output.StyleError.Render("not detected"))
+	default:
+		fmt.Printf("%s: %s (%d/%d sources agree)\n", label, c.IP, c.Votes, queried)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, a := range c.Answers {
+		mark, answer := "✓", a.IP
+		switch {
+		case a.Skipped:
+			mark, answer = "-", a.Error
+		case a.IP == "":
+			mark, answer = "✗", a.Error
+		case a.IP != c.IP:
+			mark, answer = "!", a.IP+" (disagrees)"
+		}
+		fmt.Fprintf(w, "  %s %s\t%s\t%dms\t%s\n", mark, a.Source, a.Kind, a.Duration, answer)
+	}
+	w.Flush()
+
+	if c.Disagree {
+		fmt.Println(output.StyleWarning.Render("  Warning: " + disagreementHint(c)))
+	}
+}
+
+// disagreementHint 根据分歧的来源类型推测原因
+func disagreementHint(c *network.Consensus) string {
+	httpOnly := true
+	for _, a := range c.Dissenters() {
+		if a.Kind != "http" {
+			httpOnly = false
+		}
+	}
+	majorityHTTP := true
+	for _, a := range c.Answers {
+		if a.IP == c.IP && a.Kind != "http" {
+			majorityHTTP = false
+		}
+	}
+
+	switch {
+	case httpOnly && !majorityHTTP:
+		return "HTTP sources see a different address than DNS/STUN, likely a transparent HTTP proxy"
+	case httpOnly:
+		return "HTTP services see different addresses, likely a split-tunnel VPN"
+	default:
+		return "sources see different addresses, traffic leaves through more than one path (VPN or multi-WAN)"
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(sourcesCmd)
+
+	sourcesCmd.Flags().StringVar(&sourcesFamily, "family", "", "Only detect this family: 4 or 6")
+	sourcesCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format: json, yaml, text, quiet")
+	sourcesCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Only output the detected IPs")
+}
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 	"runtime"
 
 	"github.com/spf13/cobra"
@@ -38,11 +37,6 @@ func init() {
 
 	// -V/--version: 直接打印版本并以 0 退出（避免进入主逻辑）
 	// CLI Guidelines: 每个 CLI 都应该支持 --version
+	// 在 rootCmd.PersistentPreRunE 中处理 (与其他全局标志一起)
 	rootCmd.PersistentFlags().BoolP("version", "V", false, "Print version")
-	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
-		if v, _ := cmd.Flags().GetBool("version"); v {
-			fmt.Printf("ipq %s\n", Version)
-			os.Exit(0)
-		}
-	}
 }
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github/shawn/ip-tool/internal/cli"
+	"github/shawn/ip-tool/internal/output"
+	"github/shawn/ip-tool/internal/watch"
+
+	"github.com/spf13/cobra"
+)
+
+// minWatchInterval 最小轮询间隔，避免滥用公共回显服务
+const minWatchInterval = 10 * time.Second
+
+// watch 子命令标志
+var (
+	watchInterval time.Duration // --interval: 轮询间隔
+	watchJitter   time.Duration // --jitter: 随机抖动上限
+	watchHook     string        // --hook: shell 钩子
+	watchWebhook  string        // --webhook: webhook URL
+	watchLog      string        // --log: 变更日志
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Watch your public IP and act on changes",
+	Long: `Poll your public IPv4/IPv6 and act when they change.
+
+On every change ipq can:
+  - run a shell hook with IPQ_OLD_IPV4, IPQ_NEW_IPV4, IPQ_OLD_IPV6,
+    IPQ_NEW_IPV6, IPQ_CHANGED and IPQ_CHANGED_AT in its environment
+  - POST a JSON payload to a webhook URL
+  - append the change to a log (JSON Lines)
+
+Flags override the watch_* keys in the config file.
+
+EXAMPLES:
+  ipq watch                                   Print changes every 5m
+  ipq watch --interval 1m --jitter 10s
+  ipq watch --hook './update-allowlist.sh'
+  ipq watch --webhook https://hooks.example.com/ipq`,
+	Args: cobra.NoArgs,
+	RunE: runWatch,
+}
+
+// runWatch 启动监视，直到 Ctrl+C 或 SIGTERM
+func runWatch(cmd *cobra.Command, args []string) error {
+	w, err := newWatcher(cmd)
+	if err != nil {
+		return err
+	}
+
+	w.OnPoll = func(s watch.Snapshot) {
+		// 仅在建立基线时打印当前 IP
+		if w.Current() == (watch.Snapshot{}) && !quiet {
+			fmt.Printf("%s  IPv4: %s  IPv6: %s\n",
+				time.Now().Format(time.DateTime), orDash(s.IPv4), orDash(s.IPv6))
+		}
+	}
+	w.OnChange = func(c watch.Change) {
+		for _, family := range c.Changed {
+			from, to := c.Old.IPv4, c.New.IPv4
+			if family == "ipv6" {
+				from, to = c.Old.IPv6, c.New.IPv6
+			}
+			fmt.Printf("%s  %s changed: %s → %s\n",
+				c.Time.Format(time.DateTime), strings.ToUpper(family[:2])+family[2:], from, to)
+		}
+	}
+	w.OnError = func(err error) {
+		fmt.Fprintln(os.Stderr, output.StyleWarning.Render("Warning: "+err.Error()))
+	}
+
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "Watching public IP every %s (Ctrl+C to stop)\n", w.Interval)
+	}
+
+	return w.Run(cmd.Context())
+}
+
+// newWatcher 合并标志和配置文件，创建监视器
+func newWatcher(cmd *cobra.Command) (*watch.Watcher, error) {
+	interval := watchInterval
+	if !cmd.Flags().Changed("interval") {
+		if d, err := time.ParseDuration(config.WatchInterval); err == nil {
+			interval = d
+		}
+	}
+	if interval < minWatchInterval {
+		return nil, output.NewError(
+			"Interval too short",
+			fmt.Sprintf("Minimum is %s to avoid abusing public IP services", minWatchInterval),
+			"ipq watch --interval 1m",
+		).WithCode(cli.ExitInvalidArgs)
+	}
+
+	jitter := watchJitter
+	if !cmd.Flags().Changed("jitter") {
+		if d, err := time.ParseDuration(config.WatchJitter); err == nil {
+			jitter = d
+		}
+	}
+
+	w := watch.New(interval, jitter)
+	w.Hook = flagOrConfig(cmd, "hook", watchHook, config.WatchHook)
+	w.Webhook = flagOrConfig(cmd, "webhook", watchWebhook, config.WatchWebhook)
+	w.LogPath = flagOrConfig(cmd, "log", watchLog, config.WatchLog)
+	if w.LogPath == "" {
+		w.LogPath = watch.DefaultLogPath()
+	}
+
+	if w.Webhook != "" {
+		u, err := url.Parse(w.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, output.NewError(
+				"Invalid webhook URL",
+				fmt.Sprintf("URL: %s", w.Webhook),
+				"ipq watch --webhook https://hooks.example.com/ipq",
+			).WithCode(cli.ExitInvalidArgs)
+		}
+	}
+
+	return w, nil
+}
+
+// flagOrConfig 标志优先，未指定时使用配置文件的值
+func flagOrConfig(cmd *cobra.Command, name, flagValue, configValue string) string {
+	if cmd.Flags().Changed(name) {
+		return flagValue
+	}
+	return configValue
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", 5*time.Minute, "Polling interval")
+	watchCmd.Flags().DurationVar(&watchJitter, "jitter", 30*time.Second, "Maximum random delay added to each interval")
+	watchCmd.Flags().StringVar(&watchHook, "hook", "", "Shell command to run on change")
+	watchCmd.Flags().StringVar(&watchWebhook, "webhook", "", "URL to POST a JSON payload to on change")
+	watchCmd.Flags().StringVar(&watchLog, "log", "", "Change log path (default $XDG_STATE_HOME/ipq/changes.jsonl)")
+	watchCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Only print changes")
+}
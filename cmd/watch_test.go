@@ -0,0 +1,17 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+// 过短的间隔是用法错误，在任何查询之前拒绝
+func TestWatchIntervalTooShort(t *testing.T) {
+	stdout, stderr, code := runCommand(t, "watch", "--interval", "1s")
+	if code != 2 || stdout != "" {
+		t.Errorf("exit code %d, stdout %q; want 2 and no output", code, stdout)
+	}
+	if !strings.Contains(stderr, "Interval too short") {
+		t.Errorf("stderr = %q", stderr)
+	}
+}
@@ -15,36 +15,119 @@ CLI Guidelines 原则 - Unix 哲学:
 
 	# 与其他工具组合
 	grep "8.8" ips.txt | ipq --batch -o json
+
+	# 第一个失败就停止，退出码表示失败原因 (见 exit.go)
+	ipq -f ips.txt --fail-fast -o json
+
+	# 并发查询，每完成一个输出一行
+	ipq -f huge.txt -o ndjson --unordered | jq .ipv4
 */
 package cli
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 
+	"github/shawn/ip-tool/internal/history"
 	"github/shawn/ip-tool/internal/ip"
 	"github/shawn/ip-tool/internal/output"
 
 	"gopkg.in/yaml.v3"
 )
 
+// BatchOptions 批量查询的选项
+type BatchOptions struct {
+	Detail      bool          // 查询地理位置
+	Format      output.Format // 输出格式
+	Quiet       bool          // 不提示跳过的无效输入
+	MaxFailures int           // 失败数达到上限后停止，0 表示全部处理
+	Unordered   bool          // 并发查询，按完成顺序输出
+	Concurrency int           // Unordered 时同时进行的查询数
+}
+
+// DefaultConcurrency --unordered 默认同时进行的查询数
+const DefaultConcurrency = 8
+
 // ProcessBatchFile 从文件批量处理
-func ProcessBatchFile(filename string, detail bool, format output.Format, quiet bool) error {
+func ProcessBatchFile(ctx context.Context, filename string, opts BatchOptions) error {
 	file, err := os.Open(filename)
 	if err != nil {
 		return fmt.Errorf("cannot open file: %w", err)
 	}
 	defer file.Close()
 
-	return processBatch(bufio.NewScanner(file), detail, format, quiet)
+	return processBatch(ctx, bufio.NewScanner(file), opts, history.SourceFile)
 }
 
 // ProcessBatchStdin 从 stdin 批量处理
-func ProcessBatchStdin(detail bool, format output.Format, quiet bool) error {
-	return processBatch(bufio.NewScanner(os.Stdin), detail, format, quiet)
+func ProcessBatchStdin(ctx context.Context, opts BatchOptions) error {
+	return processBatch(ctx, bufio.NewScanner(os.Stdin), opts, history.SourceStdin)
+}
+
+// ReadTargetsFile 从文件读取所有有效目标 (供 TUI 列表视图使用)
+func ReadTargetsFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open file: %w", err)
+	}
+	defer file.Close()
+
+	return readTargets(bufio.NewScanner(file), false)
+}
+
+// ReadTargetsStdin 从 stdin 读取所有有效目标 (供 TUI 列表视图使用)
+func ReadTargetsStdin(quiet bool) ([]string, error) {
+	return readTargets(bufio.NewScanner(os.Stdin), quiet)
+}
+
+// readTargets 读取并验证全部目标，规则与 processBatch 一致
+func readTargets(scanner *bufio.Scanner, quiet bool) ([]string, error) {
+	var targets []string
+
+	for scanner.Scan() {
+		target, ok := parseLine(scanner.Text(), quiet)
+		if ok {
+			targets = append(targets, target)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read error: %w", err)
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no valid targets found")
+	}
+
+	return targets, nil
+}
+
+// parseLine 解析单行输入
+//
+// 返回 false 表示该行应跳过 (空行、注释或无效目标)
+func parseLine(raw string, quiet bool) (string, bool) {
+	line := strings.TrimSpace(raw)
+
+	// 跳过空行和注释
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", false
+	}
+
+	// 智能提取目标
+	target := ip.ExtractFromURL(line)
+	if !ip.IsValidTarget(target) {
+		// CLI Guidelines: 警告输出到 stderr
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "Skipping invalid: %s\n", line)
+		}
+		return "", false
+	}
+
+	return target, true
 }
 
 // processBatch 批量处理核心逻辑
@@ -52,63 +135,166 @@ func ProcessBatchStdin(detail bool, format output.Format, quiet bool) error {
 // 设计决策:
 // 1. 跳过空行和注释 (# 开头)
 // 2. 无效输入输出到 stderr，不中断处理
-// 3. JSON 输出时收集所有结果后一次性输出
-// 4. Text 输出时逐条输出
-func processBatch(scanner *bufio.Scanner, detail bool, format output.Format, quiet bool) error {
-	var results []*output.Result
+// 3. JSON/YAML 输出时收集所有结果后一次性输出
+// 4. Text、NDJSON 和 CSV/TSV 输出时逐条输出 (CSV/TSV 只输出一次表头)，不保留结果，输入再多内存也不增长
+// 5. 每条结果写入查询历史，source 标明输入来源
+// 6. Ctrl+C 时丢弃未完成的查询，已有结果照常输出 (JSON/YAML 数组完整闭合)
+// 7. 有目标失败时返回 *output.BatchError，失败数达到 MaxFailures 后不再读取
+// 8. Unordered 时同时进行多个查询，按完成顺序输出；默认逐个查询 (并发数为 1)，顺序与输入一致
+func processBatch(ctx context.Context, scanner *bufio.Scanner, opts BatchOptions, source string) error {
+	results := []*output.Result{}
 	count := 0
+	failed := &output.BatchError{}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	var table *output.Table
+	if opts.Format.IsTable() {
+		table = output.NewTable(os.Stdout, opts.Format)
+	}
+
+	workers := 1
+	if opts.Unordered {
+		workers = max(opts.Concurrency, 1)
+	}
 
-		// 跳过空行和注释
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
+	// 查询完成的结果；缓冲保证中断后进行中的查询不会阻塞
+	type finished struct {
+		result *output.Result
+		line   string
+	}
+	completed := make(chan finished, workers)
+	inflight := 0
+	stopped := false
+
+	// 提前停止 (失败数达到上限、Ctrl+C、写输出出错) 时通知读取的 goroutine 退出
+	done := make(chan struct{})
+	defer close(done)
+	lines, readErr := scanLines(ctx, done, scanner)
+read:
+	for {
+		// 达到并发数或已停止时暂不读取
+		next := lines
+		if stopped || inflight >= workers {
+			next = nil
+		}
+		if next == nil && inflight == 0 {
+			break
 		}
 
-		// 智能提取目标
-		target := ip.ExtractFromURL(line)
-		if !ip.IsValidTarget(target) {
-			// CLI Guidelines: 警告输出到 stderr
-			if !quiet {
-				fmt.Fprintf(os.Stderr, "Skipping invalid: %s\n", line)
+		select {
+		case <-ctx.Done():
+			break read
+		case line, ok := <-next:
+			if !ok {
+				lines = nil // 输入读完，等待进行中的查询
+				continue
 			}
-			continue
-		}
+			target, ok := parseLine(line, opts.Quiet)
+			if !ok {
+				continue
+			}
+			inflight++
+			go func() {
+				completed <- finished{output.FetchResult(ctx, target, opts.Detail), line}
+			}()
+		case f := <-completed:
+			inflight--
+			if ctx.Err() != nil {
+				break read // 被中断的查询结果不完整
+			}
+			output.Record(f.result, strings.TrimSpace(f.line), source)
+
+			// 根据格式决定处理方式
+			switch opts.Format {
+			case output.FormatJSON, output.FormatYAML:
+				results = append(results, f.result)
+			case output.FormatNDJSON:
+				// 每行直接写入 stdout (无缓冲)，下游 jq 立即可见
+				if err := output.PrintResult(f.result, opts.Detail, opts.Format); err != nil {
+					return err
+				}
+			case output.FormatCSV, output.FormatTSV:
+				if err := table.Write(f.result); err != nil {
+					return err
+				}
+			default:
+				if count > 0 {
+					fmt.Println()
+				}
+				if err := output.PrintResult(f.result, opts.Detail, opts.Format); err != nil {
+					return err
+				}
+			}
+			count++
 
-		// 根据格式决定处理方式
-		if format == output.FormatJSON || format == output.FormatYAML {
-			results = append(results, output.FetchResult(target, detail))
-		} else {
-			if count > 0 {
-				fmt.Println()
+			if err := f.result.Err(); err != nil {
+				failed.Errs = append(failed.Errs, err)
+				// 已经开始的查询照常完成并输出
+				if opts.MaxFailures > 0 && len(failed.Errs) >= opts.MaxFailures {
+					failed.Stopped = true
+					stopped = true
+				}
 			}
-			output.Print(target, detail, format)
 		}
-		count++
 	}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("read error: %w", err)
+	interrupted := Interrupted(ctx)
+	if !interrupted {
+		// 只有读完输入 (通道已关闭) 时 *readErr 才有效
+		if lines == nil && *readErr != nil {
+			return fmt.Errorf("read error: %w", *readErr)
+		}
+		if count == 0 {
+			return fmt.Errorf("no valid targets found")
+		}
 	}
 
-	if count == 0 {
-		return fmt.Errorf("no valid targets found")
+	if err := encodeResults(results, opts.Format); err != nil {
+		return err
+	}
+	if interrupted {
+		return fmt.Errorf("%w (%d done)", ErrInterrupted, count)
 	}
+	if len(failed.Errs) > 0 {
+		failed.Failed, failed.Total = len(failed.Errs), count
+		return failed
+	}
+	return nil
+}
 
-	// JSON 批量输出: 数组格式
-	if format == output.FormatJSON {
+// scanLines 在后台逐行读取输入
+//
+// 等待 stdin 时也能响应 ctx 取消；调用方不再读取时关闭 done，goroutine 随即退出
+// (正阻塞在读取上时，读到下一行后退出)。通道关闭后 *err 为读取错误
+func scanLines(ctx context.Context, done <-chan struct{}, scanner *bufio.Scanner) (<-chan string, *error) {
+	lines := make(chan string)
+	err := new(error)
+	go func() {
+		defer close(lines)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+		*err = scanner.Err()
+	}()
+	return lines, err
+}
+
+// encodeResults JSON/YAML 批量输出: 数组格式，其他格式已逐条输出
+func encodeResults(results []*output.Result, format output.Format) error {
+	switch format {
+	case output.FormatJSON:
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetIndent("", "  ")
 		return enc.Encode(results)
-	}
-
-	// YAML 批量输出: 数组格式
-	if format == output.FormatYAML {
+	case output.FormatYAML:
 		enc := yaml.NewEncoder(os.Stdout)
 		enc.SetIndent(2)
 		return enc.Encode(results)
 	}
-
 	return nil
 }
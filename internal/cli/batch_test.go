@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github/shawn/ip-tool/internal/history"
+	"github/shawn/ip-tool/internal/output"
+)
+
+// endless 无限的输入，每行 "8.8.8.8"
+type endless struct{}
+
+func (endless) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = "8.8.8.8\n"[i%8]
+	}
+	return len(p), nil
+}
+
+// drained 等待通道关闭 (读取的 goroutine 已退出)
+func drained(t *testing.T, lines <-chan string) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-lines:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("reader goroutine still running")
+		}
+	}
+}
+
+// 调用方提前停止 (如 --max-failures) 后，读取的 goroutine 不能阻塞在发送上
+func TestScanLinesStopsWhenDone(t *testing.T) {
+	done := make(chan struct{})
+	lines, _ := scanLines(context.Background(), done, bufio.NewScanner(endless{}))
+	if line := <-lines; line != "8.8.8.8" {
+		t.Fatalf("first line = %q", line)
+	}
+	close(done)
+	drained(t, lines)
+}
+
+func TestScanLinesStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	lines, _ := scanLines(ctx, make(chan struct{}), bufio.NewScanner(endless{}))
+	<-lines
+	cancel()
+	drained(t, lines)
+}
+
+func TestScanLinesReadError(t *testing.T) {
+	errRead := errors.New("disk on fire")
+	r := io.MultiReader(strings.NewReader("1.1.1.1\n8.8.8.8\n"), &failingReader{errRead})
+	lines, readErr := scanLines(context.Background(), make(chan struct{}), bufio.NewScanner(r))
+
+	var got []string
+	for line := range lines {
+		got = append(got, line)
+	}
+	if strings.Join(got, ",") != "1.1.1.1,8.8.8.8" {
+		t.Errorf("lines = %q", got)
+	}
+	if !errors.Is(*readErr, errRead) {
+		t.Errorf("read error = %v, want %v", *readErr, errRead)
+	}
+}
+
+type failingReader struct{ err error }
+
+func (r *failingReader) Read([]byte) (int, error) { return 0, r.err }
+
+// 文本输出写入失败时与 NDJSON 一样停止并返回错误
+func TestProcessBatchTextWriteError(t *testing.T) {
+	history.Configure(false, 0)
+	t.Cleanup(func() { history.Configure(true, 0) })
+
+	for _, format := range []output.Format{output.FormatText, output.FormatQuiet, output.FormatNDJSON} {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Close()
+		w.Close()
+		orig := os.Stdout
+		os.Stdout = w
+
+		input := bufio.NewScanner(strings.NewReader("192.168.1.1\n192.168.1.2\n"))
+		err = processBatch(context.Background(), input, BatchOptions{Format: format}, history.SourceFile)
+		os.Stdout = orig
+		if !errors.Is(err, os.ErrClosed) {
+			t.Errorf("%s: err = %v, want the write error", format, err)
+		}
+	}
+}
@@ -16,6 +16,18 @@ CLI Guidelines 原则 - Configuration:
 	show_detail: true
 	timeout: 10s
 	api_source: ip-api
+	history: true        # 记录查询历史
+	history_limit: 1000  # 历史保留条数
+	watch_interval: 5m   # ipq watch 轮询间隔
+	watch_hook: /usr/local/bin/update-allowlist.sh
+	watch_webhook: https://hooks.example.com/ipq
+	ddns_tsig_key: hmac-sha256:home-key:c2VjcmV0  # ipq ddns update 的 TSIG 密钥
+	ip_sources: [icanhazip, ipify, opendns, stun]  # 公网 IP 检测来源
+	proxy: socks5h://jump.example.com:1080  # 所有出站请求经代理
+	proxy_dns: 1.1.1.1                      # 经 SOCKS5 代理查询 DNS 的服务器
+	deadline: 3s                            # 每次查询的总期限 (DNS、公网 IP、地理位置共用)
+	retries: 2                              # 超时、连不上、限流时的重试次数 (0 不重试)
+	dns_egress_probes: [whoami.akamai.net, "o-o.myaddr.l.google.com TXT"]  # ipq dns-egress 探测域名
 */
 package cli
 
@@ -23,22 +35,52 @@ import (
 	"os"
 	"path/filepath"
 
+	"github/shawn/ip-tool/internal/history"
+	"github/shawn/ip-tool/internal/network"
+
 	"gopkg.in/yaml.v3"
 )
 
 // Config 应用配置
 type Config struct {
-	ShowDetail bool   `yaml:"show_detail"` // 默认显示详情
-	Timeout    string `yaml:"timeout"`     // 请求超时
-	APISource  string `yaml:"api_source"`  // API 数据源
+	ShowDetail   bool   `yaml:"show_detail"`   // 默认显示详情
+	Timeout      string `yaml:"timeout"`       // 请求超时
+	APISource    string `yaml:"api_source"`    // API 数据源
+	History      bool   `yaml:"history"`       // 是否记录查询历史
+	HistoryLimit int    `yaml:"history_limit"` // 历史保留条数
+
+	WatchInterval string `yaml:"watch_interval"` // ipq watch 轮询间隔
+	WatchJitter   string `yaml:"watch_jitter"`   // ipq watch 随机抖动上限
+	WatchHook     string `yaml:"watch_hook"`     // IP 变化时执行的 shell 命令
+	WatchWebhook  string `yaml:"watch_webhook"`  // IP 变化时 POST 的 URL
+	WatchLog      string `yaml:"watch_log"`      // 变更日志路径
+
+	DDNSTSIGKey string `yaml:"ddns_tsig_key"` // ipq ddns update 的 TSIG 密钥
+
+	IPSources []string `yaml:"ip_sources"` // 公网 IP 检测来源 (空为默认)
+
+	Proxy    string `yaml:"proxy"`     // 出站代理 (http/https/socks5/socks5h)
+	ProxyDNS string `yaml:"proxy_dns"` // 经 SOCKS5 代理查询 DNS 的服务器
+
+	Deadline string `yaml:"deadline"` // 每次查询的总期限，空为不限制
+	Retries  int    `yaml:"retries"`  // 可重试的网络错误的重试次数
+
+	DNSEgressProbes []string `yaml:"dns_egress_probes"` // ipq dns-egress 探测域名 (空为默认)
 }
 
 // DefaultConfig 返回默认配置
 func DefaultConfig() *Config {
 	return &Config{
-		ShowDetail: false,
-		Timeout:    "5s",
-		APISource:  "ip-api",
+		ShowDetail:   false,
+		Timeout:      "5s",
+		APISource:    "ip-api",
+		History:      true,
+		HistoryLimit: history.DefaultLimit,
+
+		WatchInterval: "5m",
+		WatchJitter:   "30s",
+
+		Retries: network.DefaultRetries,
 	}
 }
 
@@ -1,7 +1,7 @@
 /*
 Package cli 提供 CLI 辅助功能
 
-依赖: internal/ip, internal/output
+依赖: internal/ip, internal/network, internal/output, internal/history
 
 CLI Guidelines 原则 - Exit Codes (退出码):
 - 程序应该返回有意义的退出码
@@ -13,6 +13,12 @@ Unix 退出码惯例:
 - 0: 成功
 - 1: 一般错误
 - 2: 命令行参数错误 (bash 惯例)
+- 130: 被 Ctrl+C 中断 (128 + SIGINT)
+
+ipq 的退出码 (ExitCode 按错误类别映射，批量查询取最严重的一类):
+- 3: 网络错误，DNS 服务器或服务连不上、限流、返回内容不对 (network.ErrUnreachable 等)
+- 4: 查不到，如域名不存在 (network.ErrLookupFailed)
+- 5: 超时，包括 --deadline 到期 (network.ErrTimeout)
 
 使用示例:
 
@@ -24,23 +30,71 @@ Unix 退出码惯例:
 	  case $? in
 	    2) echo "参数错误" ;;
 	    3) echo "网络错误" ;;
+	    4) echo "域名不存在" ;;
+	    5) echo "超时" ;;
 	  esac
 	fi
 */
 package cli
 
-import "os"
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github/shawn/ip-tool/internal/network"
+)
 
 // 退出码常量
 const (
-	ExitSuccess      = 0 // 成功
-	ExitGeneralError = 1 // 一般错误
-	ExitInvalidArgs  = 2 // 参数无效
-	ExitNetworkError = 3 // 网络错误
-	ExitNotFound     = 4 // 资源未找到
-	ExitTimeout      = 5 // 超时
+	ExitSuccess      = 0   // 成功
+	ExitGeneralError = 1   // 一般错误
+	ExitInvalidArgs  = 2   // 参数无效
+	ExitNetworkError = 3   // 网络错误
+	ExitNotFound     = 4   // 资源未找到
+	ExitTimeout      = 5   // 超时
+	ExitInterrupted  = 130 // 被信号中断
 )
 
+// ExitCode 错误对应的退出码，nil 为 ExitSuccess
+//
+// 优先使用错误自带的退出码 (WithCode、output.Error.Code)，其次按错误类别:
+// 中断 > 超时 > 网络错误 > 查不到。一个错误包含多个原因时 (如 IPv4 和 IPv6、批量查询)
+// 取最严重的一类: 部分目标连不上时不能断定其余 "查不到" 是拼写错误
+func ExitCode(err error) int {
+	var coded interface{ ExitCode() int }
+	switch {
+	case err == nil:
+		return ExitSuccess
+	case errors.As(err, &coded) && coded.ExitCode() != 0:
+		return coded.ExitCode()
+	case errors.Is(err, ErrInterrupted), errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	case errors.Is(err, network.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return ExitTimeout
+	case errors.Is(err, network.ErrUnreachable), errors.Is(err, network.ErrRateLimited), errors.Is(err, network.ErrBadResponse):
+		return ExitNetworkError
+	case errors.Is(err, network.ErrLookupFailed):
+		return ExitNotFound
+	}
+	return ExitGeneralError
+}
+
+// WithCode 为错误指定退出码，错误信息不变
+func WithCode(code int, err error) error {
+	return &codeError{code, err}
+}
+
+// codeError 带退出码的错误
+type codeError struct {
+	code int
+	err  error
+}
+
+func (e *codeError) Error() string { return e.err.Error() }
+func (e *codeError) Unwrap() error { return e.err }
+func (e *codeError) ExitCode() int { return e.code }
+
 // Exit 以指定退出码终止程序
 func Exit(code int) {
 	os.Exit(code)
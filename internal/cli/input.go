@@ -34,6 +34,13 @@ func IsInteractive() bool {
 		return false
 	}
 
+	return IsTerminalOutput()
+}
+
+// IsTerminalOutput 检测 stdout 是否为终端 (忽略 stdin)
+//
+// 批量模式从管道读取目标，但结果仍可在终端中交互展示
+func IsTerminalOutput() bool {
 	// stdout 是管道
 	stat, _ := os.Stdout.Stat()
 	if (stat.Mode() & os.ModeCharDevice) == 0 {
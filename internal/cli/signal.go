@@ -0,0 +1,34 @@
+/*
+信号处理
+
+CLI Guidelines 原则 - Ctrl+C:
+- 收到 Ctrl+C 后尽快停止，但先输出已有的结果 (批量 JSON/YAML 数组要完整闭合)
+- 清理期间再按一次 Ctrl+C 立即退出
+*/
+package cli
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ErrInterrupted 被 SIGINT/SIGTERM 中断
+var ErrInterrupted = errors.New("interrupted")
+
+// SignalContext 收到 SIGINT/SIGTERM 时取消的 context
+//
+// 第一次信号取消 ctx，进行中的网络请求随之停止；
+// 之后恢复默认处理，再次收到信号时进程直接退出
+func SignalContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, stop)
+	return ctx, stop
+}
+
+// Interrupted ctx 是否因信号而取消
+func Interrupted(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.Canceled)
+}
@@ -0,0 +1,277 @@
+/*
+Package conns 列出本机的网络连接和监听端口，并标注远端信息
+
+依赖: internal/ip, internal/network
+
+怀疑主机被入侵时，第一个问题是 "它在和谁通信，对方在哪里"。
+本包读取内核的套接字表，找到所属进程，再为每个远端地址补充
+分类、反向解析和地理位置/ASN。
+
+数据来源 (Linux):
+- /proc/net/tcp, tcp6, udp, udp6: 套接字表
+- /proc/<pid>/fd: 套接字 inode 到进程的映射 (需要权限，尽力而为)
+
+其他系统没有这些文件，返回 ErrUnavailable。
+*/
+package conns
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github/shawn/ip-tool/internal/ip"
+)
+
+// procNet 套接字表所在目录 (变量便于测试时换成 testdata 中的样例)
+var procNet = "/proc/net"
+
+// ErrUnavailable 系统不提供可读的套接字表
+var ErrUnavailable = errors.New("socket tables not available on this system")
+
+// 套接字表: 文件名、协议、地址族
+var tables = []struct {
+	file, proto, family string
+}{
+	{"tcp", "tcp", "ipv4"},
+	{"tcp6", "tcp", "ipv6"},
+	{"udp", "udp", "ipv4"},
+	{"udp6", "udp", "ipv6"},
+}
+
+// TCP 状态 (include/net/tcp_states.h)
+var tcpStates = map[uint64]string{
+	0x01: "ESTABLISHED",
+	0x02: "SYN_SENT",
+	0x03: "SYN_RECV",
+	0x04: "FIN_WAIT1",
+	0x05: "FIN_WAIT2",
+	0x06: "TIME_WAIT",
+	0x07: "CLOSE",
+	0x08: "CLOSE_WAIT",
+	0x09: "LAST_ACK",
+	0x0A: "LISTEN",
+	0x0B: "CLOSING",
+}
+
+// Conn 一个套接字
+type Conn struct {
+	Proto      string `json:"proto" yaml:"proto"`   // "tcp" 或 "udp"
+	Family     string `json:"family" yaml:"family"` // "ipv4" 或 "ipv6"
+	LocalIP    string `json:"local_ip" yaml:"local_ip"`
+	LocalPort  int    `json:"local_port" yaml:"local_port"`
+	RemoteIP   string `json:"remote_ip" yaml:"remote_ip"`
+	RemotePort int    `json:"remote_port" yaml:"remote_port"`
+	RemoteType string `json:"remote_type" yaml:"remote_type"` // ip.Classify 分类
+	State      string `json:"state" yaml:"state"`             // TCP 状态；UDP 为 UNCONN 或 ESTABLISHED
+	UID        int    `json:"uid" yaml:"uid"`
+	PID        int    `json:"pid,omitempty" yaml:"pid,omitempty"`
+	Process    string `json:"process,omitempty" yaml:"process,omitempty"`
+	Peer       *Peer  `json:"peer,omitempty" yaml:"peer,omitempty"` // 远端信息 (Enrich 填充)
+
+	inode uint64
+}
+
+// Local 本地端点 "ip:port"
+func (c Conn) Local() string {
+	return net.JoinHostPort(c.LocalIP, strconv.Itoa(c.LocalPort))
+}
+
+// Remote 远端端点 "ip:port"，监听套接字为 "*"
+func (c Conn) Remote() string {
+	if c.IsListener() {
+		return "*"
+	}
+	return net.JoinHostPort(c.RemoteIP, strconv.Itoa(c.RemotePort))
+}
+
+// IsListener 是否为监听套接字 (TCP LISTEN 或未连接的 UDP)
+func (c Conn) IsListener() bool {
+	return c.State == "LISTEN" || c.State == "UNCONN"
+}
+
+// List 读取所有 TCP/UDP 套接字
+//
+// 所有表都不可读时返回 ErrUnavailable
+func List() ([]Conn, error) {
+	var list []Conn
+	var firstErr error
+	read := 0
+	for _, t := range tables {
+		conns, err := readTable(filepath.Join(procNet, t.file), t.proto, t.family)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		read++
+		list = append(list, conns...)
+	}
+	if read == 0 {
+		if os.IsNotExist(firstErr) {
+			return nil, ErrUnavailable
+		}
+		return nil, firstErr
+	}
+
+	attachProcesses(list)
+
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.IsListener() != b.IsListener() {
+			return b.IsListener() // 已建立的连接在前
+		}
+		if a.Proto != b.Proto {
+			return a.Proto < b.Proto
+		}
+		return a.LocalPort < b.LocalPort
+	})
+	return list, nil
+}
+
+// readTable 解析一个套接字表
+//
+//	sl  local_address rem_address   st tx_queue:rx_queue tr:tm->when retrnsmt   uid  timeout inode
+//	0: 0100007F:0035 00000000:0000 0A 00000000:00000000 00:00000000 00000000   0        0 12345
+func readTable(path, proto, family string) ([]Conn, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Conn
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 10 {
+			continue // 表头
+		}
+
+		localIP, localPort, err1 := parseEndpoint(fields[1])
+		remoteIP, remotePort, err2 := parseEndpoint(fields[2])
+		st, err3 := strconv.ParseUint(fields[3], 16, 8)
+		uid, err4 := strconv.Atoi(fields[7])
+		inode, err5 := strconv.ParseUint(fields[9], 10, 64)
+		if err := errors.Join(err1, err2, err3, err4, err5); err != nil {
+			continue
+		}
+
+		list = append(list, Conn{
+			Proto:      proto,
+			Family:     family,
+			LocalIP:    localIP.String(),
+			LocalPort:  localPort,
+			RemoteIP:   remoteIP.String(),
+			RemotePort: remotePort,
+			RemoteType: string(ip.Classify(remoteIP.String())),
+			State:      stateName(proto, st),
+			UID:        uid,
+			inode:      inode,
+		})
+	}
+	return list, nil
+}
+
+// stateName 套接字状态名称
+//
+// UDP 没有状态机，内核复用 TCP 常量: 01 表示已连接，07 表示未连接
+func stateName(proto string, st uint64) string {
+	if proto == "udp" {
+		if st == 0x01 {
+			return "ESTABLISHED"
+		}
+		return "UNCONN"
+	}
+	if name, ok := tcpStates[st]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
+
+// parseEndpoint 解析 "十六进制地址:十六进制端口"
+//
+// 地址按 32 位字存储，每个字为主机字节序；端口为普通十六进制数
+func parseEndpoint(s string) (net.IP, int, error) {
+	addrHex, portHex, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, 0, errors.New("malformed endpoint")
+	}
+
+	raw, err := hex.DecodeString(addrHex)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return nil, 0, errors.New("malformed address")
+	}
+	port, err := strconv.ParseUint(portHex, 16, 16)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	addr := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		binary.BigEndian.PutUint32(addr[i:], binary.NativeEndian.Uint32(raw[i:]))
+	}
+
+	// IPv4 映射的 IPv6 地址按 IPv4 显示
+	if v4 := addr.To4(); v4 != nil {
+		addr = v4
+	}
+	return addr, int(port), nil
+}
+
+// attachProcesses 通过 /proc/<pid>/fd 找到套接字所属进程
+//
+// 没有权限读取的进程会被跳过，对应套接字不显示进程
+func attachProcesses(list []Conn) {
+	byInode := make(map[uint64]*Conn, len(list))
+	for i := range list {
+		if list[i].inode != 0 {
+			byInode[list[i].inode] = &list[i]
+		}
+	}
+	if len(byInode) == 0 {
+		return
+	}
+
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return
+	}
+	for _, p := range procs {
+		pid, err := strconv.Atoi(p.Name())
+		if err != nil {
+			continue
+		}
+		fdDir := filepath.Join("/proc", p.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+
+		var name string
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			inode, err := strconv.ParseUint(strings.TrimSuffix(link[len("socket:["):], "]"), 10, 64)
+			if err != nil {
+				continue
+			}
+			c, ok := byInode[inode]
+			if !ok {
+				continue
+			}
+			if name == "" {
+				comm, _ := os.ReadFile(filepath.Join("/proc", p.Name(), "comm"))
+				name = strings.TrimSpace(string(comm))
+			}
+			c.PID, c.Process = pid, name
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package conns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testdata 中的地址按小端写 (与 x86、arm64 上的内核一致)
+func skipBigEndian(t *testing.T) {
+	t.Helper()
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		t.Skip("testdata is little-endian")
+	}
+}
+
+// useTestdata 让 List 读取 dir 中的套接字表
+func useTestdata(t *testing.T, dir string) {
+	t.Helper()
+	orig := procNet
+	procNet = dir
+	t.Cleanup(func() { procNet = orig })
+}
+
+// describe 每个连接的一行描述
+func describe(list []Conn) []string {
+	var got []string
+	for _, c := range list {
+		got = append(got, fmt.Sprintf("%s/%s %s %s %s %s uid=%d", c.Proto, c.Family, c.Local(), c.Remote(), c.State, c.RemoteType, c.UID))
+	}
+	return got
+}
+
+func TestParseEndpoint(t *testing.T) {
+	skipBigEndian(t)
+	tests := []struct {
+		in   string
+		ip   string
+		port int
+	}{
+		{"0100007F:0035", "127.0.0.1", 53},
+		{"0202A8C0:D431", "192.168.2.2", 54321},
+		{"00000000:0000", "0.0.0.0", 0},
+		{"B80D0120000000000000000001000000:C350", "2001:db8::1", 50000},
+		{"00000000000000000000000000000000:0016", "::", 22},
+		// IPv4 映射的 IPv6 地址按 IPv4 显示
+		{"0000000000000000FFFF00000100007F:1F90", "127.0.0.1", 8080},
+	}
+	for _, tt := range tests {
+		ip, port, err := parseEndpoint(tt.in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if ip.String() != tt.ip || port != tt.port {
+			t.Errorf("%s = %s:%d, want %s:%d", tt.in, ip, port, tt.ip, tt.port)
+		}
+	}
+
+	for _, in := range []string{"0100007F", "0100007F:", "0100007F:10000", "ZZ00007F:0035", "01000000007F:0035"} {
+		if _, _, err := parseEndpoint(in); err == nil {
+			t.Errorf("%s: want error", in)
+		}
+	}
+}
+
+func TestStateName(t *testing.T) {
+	tests := []struct {
+		proto string
+		st    uint64
+		want  string
+	}{
+		{"tcp", 0x01, "ESTABLISHED"},
+		{"tcp", 0x06, "TIME_WAIT"},
+		{"tcp", 0x0A, "LISTEN"},
+		{"tcp", 0x0C, "UNKNOWN"},
+		{"udp", 0x01, "ESTABLISHED"},
+		{"udp", 0x07, "UNCONN"},
+		{"udp", 0x0A, "UNCONN"},
+	}
+	for _, tt := range tests {
+		if got := stateName(tt.proto, tt.st); got != tt.want {
+			t.Errorf("stateName(%s, %#x) = %s, want %s", tt.proto, tt.st, got, tt.want)
+		}
+	}
+}
+
+func TestReadTable(t *testing.T) {
+	skipBigEndian(t)
+	tests := []struct {
+		file, proto, family string
+		want                []string
+	}{
+		// 第 4 行的地址不是十六进制，跳过
+		{"tcp", "tcp", "ipv4", []string{
+			"tcp/ipv4 127.0.0.1:53 * LISTEN Unspecified uid=101",
+			"tcp/ipv4 192.168.2.2:54321 8.8.8.8:443 ESTABLISHED Public uid=1000",
+			"tcp/ipv4 192.168.2.2:54322 192.168.2.1:22 TIME_WAIT Private uid=0",
+		}},
+		{"tcp6", "tcp", "ipv6", []string{
+			"tcp/ipv6 [::]:22 * LISTEN Unspecified uid=0",
+			"tcp/ipv6 [2001:db8::1]:50000 [2001:db8::2]:443 ESTABLISHED Public uid=1000",
+			"tcp/ipv6 127.0.0.1:8080 127.0.0.1:54323 ESTABLISHED Loopback uid=1000",
+		}},
+		{"udp", "udp", "ipv4", []string{
+			"udp/ipv4 0.0.0.0:68 * UNCONN Unspecified uid=101",
+			"udp/ipv4 192.168.2.2:57344 1.1.1.1:53 ESTABLISHED Public uid=1000",
+		}},
+		{"udp6", "udp", "ipv6", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			list, err := readTable(filepath.Join("testdata", tt.file), tt.proto, tt.family)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := describe(list); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	skipBigEndian(t)
+	useTestdata(t, "testdata")
+	list, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 已建立的连接在前，其次按协议和本地端口
+	want := []string{
+		"tcp/ipv6 127.0.0.1:8080 127.0.0.1:54323 ESTABLISHED Loopback uid=1000",
+		"tcp/ipv6 [2001:db8::1]:50000 [2001:db8::2]:443 ESTABLISHED Public uid=1000",
+		"tcp/ipv4 192.168.2.2:54321 8.8.8.8:443 ESTABLISHED Public uid=1000",
+		"tcp/ipv4 192.168.2.2:54322 192.168.2.1:22 TIME_WAIT Private uid=0",
+		"udp/ipv4 192.168.2.2:57344 1.1.1.1:53 ESTABLISHED Public uid=1000",
+		"tcp/ipv6 [::]:22 * LISTEN Unspecified uid=0",
+		"tcp/ipv4 127.0.0.1:53 * LISTEN Unspecified uid=101",
+		"udp/ipv4 0.0.0.0:68 * UNCONN Unspecified uid=101",
+	}
+	if got := describe(list); !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+
+	useTestdata(t, filepath.Join(t.TempDir(), "missing"))
+	if _, err := List(); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("missing tables: err = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	skipBigEndian(t)
+	useTestdata(t, "testdata")
+	list, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{"zero value", Filter{}, 8},
+		{"listen includes unconnected udp", Filter{States: []string{"listen"}}, 3},
+		{"state is case-insensitive", Filter{States: []string{"established", "TIME_WAIT"}}, 5},
+		{"local or remote port", Filter{Port: 53}, 2},
+		{"remote type", Filter{Type: "public"}, 3},
+		{"proto", Filter{Proto: "UDP"}, 2},
+		{"all conditions", Filter{States: []string{"established"}, Type: "public", Proto: "tcp", Port: 443}, 2},
+		{"no match", Filter{Port: 9999}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.filter.Apply(list)
+			if len(got) != tt.want {
+				t.Errorf("%d connections, want %d:\n%q", len(got), tt.want, describe(got))
+			}
+			if got == nil {
+				t.Error("Apply returned nil, want an empty list")
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+/*
+远端信息补充
+
+每个远端 IP 只查询一次，结果缓存在 Enricher 中，
+实时刷新时只有新出现的 IP 会产生网络请求。
+
+- 反向解析: 所有远端地址 (私网地址可能由本地 DNS 解析)
+- 地理位置/ASN: 只查询公网地址，使用批量接口避免频率限制
+*/
+package conns
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github/shawn/ip-tool/internal/ip"
+	"github/shawn/ip-tool/internal/network"
+)
+
+// ptrConcurrency 同时进行的反向解析数
+const ptrConcurrency = 8
+
+// Peer 远端地址信息
+type Peer struct {
+	Hostname string `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	Country  string `json:"country,omitempty" yaml:"country,omitempty"`
+	City     string `json:"city,omitempty" yaml:"city,omitempty"`
+	ISP      string `json:"isp,omitempty" yaml:"isp,omitempty"`
+	AS       string `json:"as,omitempty" yaml:"as,omitempty"`
+}
+
+// Enricher 带缓存的远端信息查询
+type Enricher struct {
+	mu    sync.Mutex
+	cache map[string]*Peer
+}
+
+// NewEnricher 创建 Enricher
+func NewEnricher() *Enricher {
+	return &Enricher{cache: make(map[string]*Peer)}
+}
+
+// Enrich 为每个连接的远端地址填充 Peer
+//
+// 监听套接字和回环、未指定地址不查询
+func (e *Enricher) Enrich(ctx context.Context, list []Conn) {
+	var ptrQueue, geoQueue []string
+	seen := make(map[string]bool)
+
+	e.mu.Lock()
+	for _, c := range list {
+		if !worthLookup(c) || seen[c.RemoteIP] {
+			continue
+		}
+		seen[c.RemoteIP] = true
+		if _, ok := e.cache[c.RemoteIP]; ok {
+			continue
+		}
+		e.cache[c.RemoteIP] = &Peer{}
+		ptrQueue = append(ptrQueue, c.RemoteIP)
+		if c.RemoteType == string(ip.TypePublic) {
+			geoQueue = append(geoQueue, c.RemoteIP)
+		}
+	}
+	e.mu.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		e.lookupGeo(ctx, geoQueue)
+	}()
+	e.lookupPTR(ctx, ptrQueue)
+	wg.Wait()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := range list {
+		if p, ok := e.cache[list[i].RemoteIP]; ok && *p != (Peer{}) && worthLookup(list[i]) {
+			peer := *p
+			list[i].Peer = &peer
+		}
+	}
+}
+
+// worthLookup 远端地址是否需要查询
+func worthLookup(c Conn) bool {
+	if c.IsListener() {
+		return false
+	}
+	addr := net.ParseIP(c.RemoteIP)
+	return addr != nil && !addr.IsLoopback() && !addr.IsUnspecified()
+}
+
+// lookupPTR 并发反向解析
+func (e *Enricher) lookupPTR(ctx context.Context, ips []string) {
+	sem := make(chan struct{}, ptrConcurrency)
+	var wg sync.WaitGroup
+	for _, addr := range ips {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(addr string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			name, err := network.LookupPTR(ctx, addr)
+			if err != nil {
+				return
+			}
+			e.mu.Lock()
+			e.cache[addr].Hostname = name
+			e.mu.Unlock()
+		}(addr)
+	}
+	wg.Wait()
+}
+
+// lookupGeo 批量查询地理位置和 ASN
+//
+// 失败时保留已得到的结果，其余 IP 在下次 Enrich 时不会重试
+func (e *Enricher) lookupGeo(ctx context.Context, ips []string) {
+	if len(ips) == 0 {
+		return
+	}
+	results, _ := network.FetchGeoBatch(ctx, ips)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for addr, info := range results {
+		p, ok := e.cache[addr]
+		if !ok || !info.IsSuccess() {
+			continue
+		}
+		p.Country, p.City, p.ISP, p.AS = info.Country, info.City, info.ISP, info.AS
+	}
+}
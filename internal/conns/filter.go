@@ -0,0 +1,56 @@
+/*
+连接过滤条件
+
+与 history.Filter 一样，零值表示不过滤。
+*/
+package conns
+
+import (
+	"strings"
+)
+
+// Filter 连接过滤条件
+type Filter struct {
+	States []string // 状态 (不区分大小写)；"listen" 同时匹配未连接的 UDP
+	Port   int      // 本地或远端端口
+	Type   string   // 远端地址分类，如 "public" (不区分大小写)
+	Proto  string   // "tcp" 或 "udp"
+}
+
+// Match 连接是否满足所有条件
+func (f Filter) Match(c Conn) bool {
+	if len(f.States) > 0 && !f.matchState(c) {
+		return false
+	}
+	if f.Port != 0 && c.LocalPort != f.Port && c.RemotePort != f.Port {
+		return false
+	}
+	if f.Type != "" && !strings.EqualFold(c.RemoteType, f.Type) {
+		return false
+	}
+	if f.Proto != "" && !strings.EqualFold(c.Proto, f.Proto) {
+		return false
+	}
+	return true
+}
+
+// matchState 状态是否在列表中
+func (f Filter) matchState(c Conn) bool {
+	for _, s := range f.States {
+		if strings.EqualFold(s, c.State) || (strings.EqualFold(s, "listen") && c.IsListener()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply 返回满足条件的连接
+func (f Filter) Apply(list []Conn) []Conn {
+	out := []Conn{}
+	for _, c := range list {
+		if f.Match(c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
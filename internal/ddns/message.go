@@ -0,0 +1,262 @@
+/*
+DNS 报文编解码
+
+只实现 DNS UPDATE 所需的最小子集:
+- 构造 UPDATE 请求 (区域段 + 更新段 + TSIG)
+- 解析响应头和附加段中的 TSIG 记录
+
+参考:
+- RFC 1035: 报文格式
+- RFC 2136: DNS UPDATE
+*/
+package ddns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 记录类型和类
+const (
+	typeA    uint16 = 1
+	typeSOA  uint16 = 6
+	typeAAAA uint16 = 28
+	typeTSIG uint16 = 250
+
+	classIN  uint16 = 1
+	classANY uint16 = 255
+)
+
+// opcodeUpdate DNS UPDATE 操作码 (RFC 2136)
+const opcodeUpdate = 5
+
+// 响应码 (RFC 1035, RFC 2136, RFC 8945)
+var rcodeNames = map[int]string{
+	0:  "NOERROR",
+	1:  "FORMERR",
+	2:  "SERVFAIL",
+	3:  "NXDOMAIN",
+	4:  "NOTIMP",
+	5:  "REFUSED",
+	6:  "YXDOMAIN",
+	7:  "YXRRSET",
+	8:  "NXRRSET",
+	9:  "NOTAUTH",
+	10: "NOTZONE",
+	16: "BADSIG",
+	17: "BADKEY",
+	18: "BADTIME",
+}
+
+// rcodeName 返回响应码名称
+func rcodeName(code int) string {
+	if name, ok := rcodeNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("RCODE%d", code)
+}
+
+// errShort 报文被截断或格式错误
+var errShort = errors.New("malformed DNS message")
+
+// header DNS 报文头
+type header struct {
+	id      uint16
+	flags   uint16
+	qdcount uint16 // UPDATE 中为 ZOCOUNT
+	ancount uint16 // UPDATE 中为 PRCOUNT
+	nscount uint16 // UPDATE 中为 UPCOUNT
+	arcount uint16
+}
+
+// rcode 返回响应码 (低 4 位)
+func (h header) rcode() int {
+	return int(h.flags & 0x000f)
+}
+
+// truncated 响应是否被截断 (需要改用 TCP)
+func (h header) truncated() bool {
+	return h.flags&0x0200 != 0
+}
+
+// pack 编码报文头
+func (h header) pack() []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint16(b[0:], h.id)
+	binary.BigEndian.PutUint16(b[2:], h.flags)
+	binary.BigEndian.PutUint16(b[4:], h.qdcount)
+	binary.BigEndian.PutUint16(b[6:], h.ancount)
+	binary.BigEndian.PutUint16(b[8:], h.nscount)
+	binary.BigEndian.PutUint16(b[10:], h.arcount)
+	return b
+}
+
+// parseHeader 解码报文头
+func parseHeader(msg []byte) (header, error) {
+	if len(msg) < 12 {
+		return header{}, errShort
+	}
+	return header{
+		id:      binary.BigEndian.Uint16(msg[0:]),
+		flags:   binary.BigEndian.Uint16(msg[2:]),
+		qdcount: binary.BigEndian.Uint16(msg[4:]),
+		ancount: binary.BigEndian.Uint16(msg[6:]),
+		nscount: binary.BigEndian.Uint16(msg[8:]),
+		arcount: binary.BigEndian.Uint16(msg[10:]),
+	}, nil
+}
+
+// rr 资源记录
+type rr struct {
+	name  string
+	typ   uint16
+	class uint16
+	ttl   uint32
+	data  []byte
+}
+
+// pack 编码资源记录 (不压缩域名)
+func (r rr) pack() ([]byte, error) {
+	name, err := packName(r.name)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 0, len(name)+10+len(r.data))
+	b = append(b, name...)
+	b = binary.BigEndian.AppendUint16(b, r.typ)
+	b = binary.BigEndian.AppendUint16(b, r.class)
+	b = binary.BigEndian.AppendUint32(b, r.ttl)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(r.data)))
+	return append(b, r.data...), nil
+}
+
+// packName 将域名编码为 DNS 线格式 (标签序列，不压缩)
+//
+// TSIG 要求规范形式，因此统一转为小写
+func packName(name string) ([]byte, error) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if name == "" {
+		return []byte{0}, nil
+	}
+	if len(name) > 253 {
+		return nil, fmt.Errorf("name too long: %s", name)
+	}
+
+	var b []byte
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return nil, fmt.Errorf("invalid name: %s", name)
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0), nil
+}
+
+// readName 从 off 处读取域名 (支持压缩指针)
+//
+// 返回域名和域名之后的偏移
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1 // 遇到第一个指针后，记录原始位置之后的偏移
+	for hops := 0; ; hops++ {
+		if off >= len(msg) || hops > 127 {
+			return "", 0, errShort
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.Join(labels, "."), end, nil
+
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(msg) {
+				return "", 0, errShort
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, errShort
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
+
+// readRR 从 off 处读取资源记录
+//
+// 返回记录、记录起始偏移之后的偏移
+func readRR(msg []byte, off int) (rr, int, error) {
+	name, off, err := readName(msg, off)
+	if err != nil {
+		return rr{}, 0, err
+	}
+	if off+10 > len(msg) {
+		return rr{}, 0, errShort
+	}
+	r := rr{
+		name:  name,
+		typ:   binary.BigEndian.Uint16(msg[off:]),
+		class: binary.BigEndian.Uint16(msg[off+2:]),
+		ttl:   binary.BigEndian.Uint32(msg[off+4:]),
+	}
+	length := int(binary.BigEndian.Uint16(msg[off+8:]))
+	off += 10
+	if off+length > len(msg) {
+		return rr{}, 0, errShort
+	}
+	r.data = msg[off : off+length]
+	return r, off + length, nil
+}
+
+// skipQuestion 跳过问题段 (UPDATE 中为区域段) 的一条记录
+func skipQuestion(msg []byte, off int) (int, error) {
+	_, off, err := readName(msg, off)
+	if err != nil {
+		return 0, err
+	}
+	if off+4 > len(msg) {
+		return 0, errShort
+	}
+	return off + 4, nil
+}
+
+// findTSIG 在响应的附加段末尾查找 TSIG 记录
+//
+// 返回 TSIG 记录和它在报文中的起始偏移 (-1 表示没有)
+func findTSIG(msg []byte) (rr, int, error) {
+	h, err := parseHeader(msg)
+	if err != nil {
+		return rr{}, -1, err
+	}
+
+	off := 12
+	for i := 0; i < int(h.qdcount); i++ {
+		if off, err = skipQuestion(msg, off); err != nil {
+			return rr{}, -1, err
+		}
+	}
+
+	total := int(h.ancount) + int(h.nscount) + int(h.arcount)
+	for i := 0; i < total; i++ {
+		start := off
+		var r rr
+		if r, off, err = readRR(msg, off); err != nil {
+			return rr{}, -1, err
+		}
+		// TSIG 必须是最后一条记录 (RFC 8945 5.1)
+		if i == total-1 && r.typ == typeTSIG {
+			return r, start, nil
+		}
+	}
+	return rr{}, -1, nil
+}
@@ -0,0 +1,256 @@
+/*
+TSIG 事务签名 (RFC 8945)
+
+DNS UPDATE 必须经过认证，否则任何人都能改写记录。
+TSIG 用共享密钥对请求和响应计算 HMAC。
+
+密钥格式 (与 nsupdate -y 相同):
+
+	[algorithm:]name:base64-secret
+
+	ipq ddns update --tsig-key hmac-sha256:home-key:c2VjcmV0...
+
+默认算法 hmac-sha256。
+*/
+package ddns
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+)
+
+// defaultFudge 允许的时钟偏差 (秒)，RFC 8945 推荐 300
+const defaultFudge = 300
+
+// algorithms 支持的 HMAC 算法
+var algorithms = map[string]func() hash.Hash{
+	"hmac-sha1":   sha1.New,
+	"hmac-sha224": sha256.New224,
+	"hmac-sha256": sha256.New,
+	"hmac-sha384": sha512.New384,
+	"hmac-sha512": sha512.New,
+}
+
+// Key TSIG 密钥
+type Key struct {
+	Name      string // 密钥名 (如 "home-key")
+	Algorithm string // 算法名 (如 "hmac-sha256")
+	Secret    []byte // 共享密钥
+}
+
+// ParseKey 解析 "[algorithm:]name:base64-secret" 格式的密钥
+func ParseKey(s string) (*Key, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+
+	key := &Key{Algorithm: "hmac-sha256"}
+	var secret string
+	switch len(parts) {
+	case 2:
+		key.Name, secret = parts[0], parts[1]
+	case 3:
+		key.Algorithm, key.Name, secret = strings.ToLower(parts[0]), parts[1], parts[2]
+	default:
+		return nil, fmt.Errorf("expected [algorithm:]name:secret")
+	}
+
+	if _, ok := algorithms[key.Algorithm]; !ok {
+		return nil, fmt.Errorf("unsupported algorithm: %s", key.Algorithm)
+	}
+	if key.Name == "" {
+		return nil, fmt.Errorf("empty key name")
+	}
+
+	var err error
+	if key.Secret, err = base64.StdEncoding.DecodeString(secret); err != nil || len(key.Secret) == 0 {
+		return nil, fmt.Errorf("secret is not valid base64")
+	}
+	return key, nil
+}
+
+// tsigVars TSIG 变量 (参与 MAC 计算但不都出现在 RDATA 中)
+type tsigVars struct {
+	timeSigned uint64
+	fudge      uint16
+	mac        []byte
+	origID     uint16
+	err        uint16
+	other      []byte
+}
+
+// digest 构造 MAC 计算中 "TSIG 变量" 部分 (RFC 8945 4.3.3)
+func (k *Key) digest(v tsigVars) ([]byte, error) {
+	name, err := packName(k.Name)
+	if err != nil {
+		return nil, err
+	}
+	alg, err := packName(k.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	b = append(b, name...)
+	b = binary.BigEndian.AppendUint16(b, classANY)
+	b = binary.BigEndian.AppendUint32(b, 0) // TTL
+	b = append(b, alg...)
+	b = appendUint48(b, v.timeSigned)
+	b = binary.BigEndian.AppendUint16(b, v.fudge)
+	b = binary.BigEndian.AppendUint16(b, v.err)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(v.other)))
+	return append(b, v.other...), nil
+}
+
+// mac 计算 HMAC
+func (k *Key) mac(parts ...[]byte) []byte {
+	h := hmac.New(algorithms[k.Algorithm], k.Secret)
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+// sign 为请求追加 TSIG 记录
+//
+// 返回签名后的报文和请求 MAC (用于验证响应)
+func (k *Key) sign(msg []byte, now time.Time) ([]byte, []byte, error) {
+	h, err := parseHeader(msg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	v := tsigVars{
+		timeSigned: uint64(now.Unix()),
+		fudge:      defaultFudge,
+		origID:     h.id,
+	}
+	vars, err := k.digest(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	v.mac = k.mac(msg, vars)
+
+	record, err := rr{
+		name:  k.Name,
+		typ:   typeTSIG,
+		class: classANY,
+		data:  k.rdata(v),
+	}.pack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h.arcount++
+	signed := append(h.pack(), msg[12:]...)
+	return append(signed, record...), v.mac, nil
+}
+
+// rdata 编码 TSIG RDATA
+func (k *Key) rdata(v tsigVars) []byte {
+	alg, _ := packName(k.Algorithm)
+
+	var b []byte
+	b = append(b, alg...)
+	b = appendUint48(b, v.timeSigned)
+	b = binary.BigEndian.AppendUint16(b, v.fudge)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(v.mac)))
+	b = append(b, v.mac...)
+	b = binary.BigEndian.AppendUint16(b, v.origID)
+	b = binary.BigEndian.AppendUint16(b, v.err)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(v.other)))
+	return append(b, v.other...)
+}
+
+// verify 验证响应的 TSIG (RFC 8945 5.3)
+//
+// requestMAC 是对应请求的 MAC
+func (k *Key) verify(msg, requestMAC []byte, now time.Time) error {
+	record, start, err := findTSIG(msg)
+	if err != nil {
+		return err
+	}
+	if start < 0 {
+		return fmt.Errorf("response is not signed")
+	}
+	if !strings.EqualFold(strings.TrimSuffix(record.name, "."), strings.TrimSuffix(k.Name, ".")) {
+		return fmt.Errorf("response signed with unknown key %q", record.name)
+	}
+
+	v, err := parseTSIGData(record.data)
+	if err != nil {
+		return err
+	}
+	if v.err != 0 {
+		return fmt.Errorf("server rejected signature: %s", rcodeName(int(v.err)))
+	}
+
+	// 去掉 TSIG 记录并恢复 ARCOUNT 和原始 ID
+	h, err := parseHeader(msg)
+	if err != nil {
+		return err
+	}
+	h.arcount--
+	h.id = v.origID
+	stripped := append(h.pack(), msg[12:start]...)
+
+	vars, err := k.digest(v)
+	if err != nil {
+		return err
+	}
+	size := binary.BigEndian.AppendUint16(nil, uint16(len(requestMAC)))
+	expected := k.mac(size, requestMAC, stripped, vars)
+	if !hmac.Equal(expected, v.mac) {
+		return fmt.Errorf("response signature mismatch")
+	}
+
+	signed := int64(v.timeSigned)
+	if d := now.Unix() - signed; d > int64(v.fudge) || -d > int64(v.fudge) {
+		return fmt.Errorf("response signed outside allowed time window")
+	}
+	return nil
+}
+
+// parseTSIGData 解码 TSIG RDATA
+func parseTSIGData(data []byte) (tsigVars, error) {
+	var v tsigVars
+
+	// 算法名 (响应中不压缩)
+	_, off, err := readName(data, 0)
+	if err != nil {
+		return v, err
+	}
+	if off+10 > len(data) {
+		return v, errShort
+	}
+	v.timeSigned = uint64(binary.BigEndian.Uint16(data[off:]))<<32 | uint64(binary.BigEndian.Uint32(data[off+2:]))
+	v.fudge = binary.BigEndian.Uint16(data[off+6:])
+	macSize := int(binary.BigEndian.Uint16(data[off+8:]))
+	off += 10
+	if off+macSize+6 > len(data) {
+		return v, errShort
+	}
+	v.mac = data[off : off+macSize]
+	off += macSize
+	v.origID = binary.BigEndian.Uint16(data[off:])
+	v.err = binary.BigEndian.Uint16(data[off+2:])
+	otherLen := int(binary.BigEndian.Uint16(data[off+4:]))
+	off += 6
+	if off+otherLen > len(data) {
+		return v, errShort
+	}
+	v.other = data[off : off+otherLen]
+	return v, nil
+}
+
+// appendUint48 追加 48 位大端整数 (TSIG 时间戳)
+func appendUint48(b []byte, v uint64) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(v>>32))
+	return binary.BigEndian.AppendUint32(b, uint32(v))
+}
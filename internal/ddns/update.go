@@ -0,0 +1,331 @@
+/*
+Package ddns 通过 DNS UPDATE (RFC 2136) 发布本机公网 IP
+
+依赖: 无 (只使用标准库)
+
+与公网 IP 检测配合，从 "我的 IP 是什么" 走到 "发布我的 IP":
+
+	ipq ddns update --zone example.com --name home \
+	  --server ns1.example.com:53 --tsig-key hmac-sha256:home-key:c2VjcmV0...
+
+工作方式:
+ 1. 直接向权威服务器查询当前发布的 A/AAAA 记录
+ 2. 与检测到的公网 IP 比较
+ 3. 不同时发送 UPDATE: 删除整个 RRset，再添加新记录 (原子替换)
+
+设计决策:
+- 只在记录与检测结果不一致时更新，避免无意义的区域序列号增长
+- 请求和响应都用 TSIG 验证；错误响应可以不签名，先报告响应码
+- 先用 UDP，响应截断时改用 TCP
+*/
+package ddns
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// defaultTimeout 单次 DNS 交互的超时
+const defaultTimeout = 5 * time.Second
+
+// Options 更新参数
+type Options struct {
+	Server string // 权威服务器 host:port
+	Zone   string // 区域 (如 "example.com")
+	Name   string // 记录名，相对区域或以 "." 结尾的绝对名
+	TTL    uint32 // 新记录的 TTL
+	Key    *Key   // TSIG 密钥 (nil 表示不签名，大多数服务器会拒绝)
+	DryRun bool   // 只比较，不更新
+}
+
+// Record 单个记录类型的同步结果
+type Record struct {
+	Type      string   `json:"type" yaml:"type"` // "A" 或 "AAAA"
+	Name      string   `json:"name" yaml:"name"`
+	Published []string `json:"published" yaml:"published"`
+	Detected  string   `json:"detected" yaml:"detected"`
+	Updated   bool     `json:"updated" yaml:"updated"`
+	Error     string   `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// InSync 已发布的记录是否与检测结果一致
+func (r Record) InSync() bool {
+	return len(r.Published) == 1 && r.Published[0] == r.Detected
+}
+
+// FQDN 根据区域计算记录的完整域名
+//
+//   - "home", "example.com"              -> "home.example.com"
+//   - "@" 或 "", "example.com"           -> "example.com"
+//   - "home.example.com", "example.com" -> "home.example.com"
+//   - "other.org.", "example.com"        -> "other.org"
+func FQDN(name, zone string) string {
+	zone = strings.TrimSuffix(zone, ".")
+	if name == "" || name == "@" {
+		return zone
+	}
+	if strings.HasSuffix(name, ".") {
+		return strings.TrimSuffix(name, ".")
+	}
+	if strings.EqualFold(name, zone) || strings.HasSuffix(strings.ToLower(name), "."+strings.ToLower(zone)) {
+		return name
+	}
+	return name + "." + zone
+}
+
+// ServerAddr 补全默认端口 53
+func ServerAddr(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(strings.Trim(server, "[]"), "53")
+}
+
+// Sync 比较并在需要时更新一个记录类型
+//
+// detected 为检测到的公网 IP，决定记录类型 (IPv4 → A，IPv6 → AAAA)
+func Sync(ctx context.Context, opts Options, detected string) Record {
+	ip := net.ParseIP(detected)
+	rtype, network, typeName := typeA, "ip4", "A"
+	if ip != nil && ip.To4() == nil {
+		rtype, network, typeName = typeAAAA, "ip6", "AAAA"
+	}
+
+	fqdn := FQDN(opts.Name, opts.Zone)
+	rec := Record{Type: typeName, Name: fqdn, Detected: detected, Published: []string{}}
+	if ip == nil {
+		rec.Error = fmt.Sprintf("invalid address: %s", detected)
+		return rec
+	}
+
+	published, err := Published(ctx, opts.Server, fqdn, network)
+	if err != nil {
+		rec.Error = err.Error()
+		return rec
+	}
+	rec.Published = published
+
+	if rec.InSync() || opts.DryRun {
+		return rec
+	}
+
+	if err := Update(ctx, opts, rtype, []net.IP{ip}); err != nil {
+		rec.Error = err.Error()
+		return rec
+	}
+	rec.Updated = true
+	return rec
+}
+
+// Published 向指定服务器查询当前发布的地址
+//
+// network 为 "ip4" 或 "ip6"。记录不存在时返回空列表而不是错误。
+func Published(ctx context.Context, server, fqdn, network string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	server = ServerAddr(server)
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, proto, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, proto, server)
+		},
+	}
+
+	// 结尾的 "." 避免 resolv.conf 中的 search 域被追加
+	ips, err := resolver.LookupIP(ctx, network, fqdn+".")
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("query %s: %w", server, err)
+	}
+
+	published := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		published = append(published, ip.String())
+	}
+	return published, nil
+}
+
+// Update 发送 DNS UPDATE，用 ips 替换 fqdn 的 rtype RRset
+func Update(ctx context.Context, opts Options, rtype uint16, ips []net.IP) error {
+	msg, err := buildUpdate(opts, rtype, ips)
+	if err != nil {
+		return err
+	}
+
+	var requestMAC []byte
+	if opts.Key != nil {
+		if msg, requestMAC, err = opts.Key.sign(msg, time.Now()); err != nil {
+			return fmt.Errorf("sign update: %w", err)
+		}
+	}
+
+	resp, err := exchange(ctx, ServerAddr(opts.Server), msg)
+	if err != nil {
+		return err
+	}
+
+	h, err := parseHeader(resp)
+	if err != nil {
+		return err
+	}
+	if h.id != binary.BigEndian.Uint16(msg) {
+		return fmt.Errorf("response ID mismatch")
+	}
+
+	// 先看响应码: 错误响应可以不签名 (RFC 2845 4.6)，不能因此掩盖真正的原因
+	if code := h.rcode(); code != 0 {
+		return fmt.Errorf("update rejected: %s", rcodeName(code)+tsigError(resp))
+	}
+	if opts.Key != nil {
+		if err := opts.Key.verify(resp, requestMAC, time.Now()); err != nil {
+			return fmt.Errorf("cannot verify response: %w", err)
+		}
+	}
+	return nil
+}
+
+// tsigError 错误响应中 TSIG 的错误码，如 " (BADSIG)"，没有时为空
+//
+// 签名错误时服务器通常返回 NOTAUTH，TSIG 错误码更具体
+func tsigError(resp []byte) string {
+	record, start, err := findTSIG(resp)
+	if err != nil || start < 0 {
+		return ""
+	}
+	v, err := parseTSIGData(record.data)
+	if err != nil || v.err == 0 {
+		return ""
+	}
+	return " (" + rcodeName(int(v.err)) + ")"
+}
+
+// buildUpdate 构造未签名的 UPDATE 报文
+func buildUpdate(opts Options, rtype uint16, ips []net.IP) ([]byte, error) {
+	var id [2]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+
+	h := header{
+		id:      binary.BigEndian.Uint16(id[:]),
+		flags:   opcodeUpdate << 11,
+		qdcount: 1,                    // 区域段
+		nscount: uint16(1 + len(ips)), // 更新段: 删除 RRset + 添加记录
+	}
+	msg := h.pack()
+
+	// 区域段: zone SOA IN
+	zone, err := packName(opts.Zone)
+	if err != nil {
+		return nil, err
+	}
+	msg = append(msg, zone...)
+	msg = binary.BigEndian.AppendUint16(msg, typeSOA)
+	msg = binary.BigEndian.AppendUint16(msg, classIN)
+
+	// 删除整个 RRset: CLASS=ANY, TTL=0, RDLENGTH=0 (RFC 2136 2.5.2)
+	fqdn := FQDN(opts.Name, opts.Zone)
+	records := []rr{{name: fqdn, typ: rtype, class: classANY}}
+
+	// 添加新记录
+	for _, ip := range ips {
+		data := ip.To4()
+		if rtype == typeAAAA {
+			data = ip.To16()
+		}
+		records = append(records, rr{name: fqdn, typ: rtype, class: classIN, ttl: opts.TTL, data: data})
+	}
+
+	for _, r := range records {
+		b, err := r.pack()
+		if err != nil {
+			return nil, err
+		}
+		msg = append(msg, b...)
+	}
+	return msg, nil
+}
+
+// exchange 发送报文并读取响应，UDP 响应截断时改用 TCP
+func exchange(ctx context.Context, server string, msg []byte) ([]byte, error) {
+	resp, err := exchangeUDP(ctx, server, msg)
+	if err != nil {
+		return nil, err
+	}
+	if h, err := parseHeader(resp); err == nil && h.truncated() {
+		return exchangeTCP(ctx, server, msg)
+	}
+	return resp, nil
+}
+
+// exchangeUDP 通过 UDP 交换报文
+func exchangeUDP(ctx context.Context, server string, msg []byte) ([]byte, error) {
+	conn, err := dial(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(msg); err != nil {
+		return nil, fmt.Errorf("send to %s: %w", server, err)
+	}
+
+	buf := make([]byte, 65535)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, fmt.Errorf("no response from %s: %w", server, err)
+	}
+	return buf[:n], nil
+}
+
+// exchangeTCP 通过 TCP 交换报文 (2 字节长度前缀)
+func exchangeTCP(ctx context.Context, server string, msg []byte) ([]byte, error) {
+	conn, err := dial(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	framed := binary.BigEndian.AppendUint16(nil, uint16(len(msg)))
+	if _, err := conn.Write(append(framed, msg...)); err != nil {
+		return nil, fmt.Errorf("send to %s: %w", server, err)
+	}
+
+	var size [2]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return nil, fmt.Errorf("no response from %s: %w", server, err)
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(size[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, fmt.Errorf("read from %s: %w", server, err)
+	}
+	return resp, nil
+}
+
+// dial 建立带超时的连接
+func dial(ctx context.Context, network, server string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach %s: %w", server, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	return conn, nil
+}
@@ -0,0 +1,497 @@
+package ddns
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试用 DNS 服务器: 在同一端口监听 UDP 和 TCP，按 handler 回复
+//
+// 请求的解析和 MAC 计算都在测试中独立实现，不复用被测代码
+
+var testKey = &Key{Name: "test-key", Algorithm: "hmac-sha256", Secret: []byte("0123456789abcdef")}
+
+// wireName 不压缩的域名线格式
+func wireName(name string) []byte {
+	var b []byte
+	for _, l := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		b = append(b, byte(len(l)))
+		b = append(b, l...)
+	}
+	return append(b, 0)
+}
+
+// parsedRR 测试中解析出的记录
+type parsedRR struct {
+	name       string
+	typ, class uint16
+	ttl        uint32
+	data       []byte
+	start      int // 在报文中的起始偏移
+}
+
+// readWireName 读取不压缩的域名
+func readWireName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	for {
+		if off >= len(msg) {
+			return "", 0, fmt.Errorf("name runs past end of message")
+		}
+		n := int(msg[off])
+		if n == 0 {
+			return strings.Join(labels, "."), off + 1, nil
+		}
+		if n&0xc0 != 0 {
+			return "", 0, fmt.Errorf("unexpected compression pointer at %d", off)
+		}
+		if off+1+n > len(msg) {
+			return "", 0, fmt.Errorf("label runs past end of message")
+		}
+		labels = append(labels, string(msg[off+1:off+1+n]))
+		off += 1 + n
+	}
+}
+
+// parseRequest 解析 UPDATE 请求: 区域段和全部记录
+func parseRequest(msg []byte) (zone string, zoneType uint16, rrs []parsedRR, err error) {
+	if len(msg) < 12 {
+		return "", 0, nil, fmt.Errorf("short message: %d bytes", len(msg))
+	}
+	if zocount := binary.BigEndian.Uint16(msg[4:]); zocount != 1 {
+		return "", 0, nil, fmt.Errorf("ZOCOUNT = %d, want 1", zocount)
+	}
+	zone, off, err := readWireName(msg, 12)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	if off+4 > len(msg) {
+		return "", 0, nil, fmt.Errorf("truncated zone section")
+	}
+	zoneType = binary.BigEndian.Uint16(msg[off:])
+	off += 4
+
+	total := int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + int(binary.BigEndian.Uint16(msg[10:]))
+	for i := 0; i < total; i++ {
+		r := parsedRR{start: off}
+		if r.name, off, err = readWireName(msg, off); err != nil {
+			return "", 0, nil, err
+		}
+		if off+10 > len(msg) {
+			return "", 0, nil, fmt.Errorf("truncated record %d", i)
+		}
+		r.typ = binary.BigEndian.Uint16(msg[off:])
+		r.class = binary.BigEndian.Uint16(msg[off+2:])
+		r.ttl = binary.BigEndian.Uint32(msg[off+4:])
+		n := int(binary.BigEndian.Uint16(msg[off+8:]))
+		if off+10+n > len(msg) {
+			return "", 0, nil, fmt.Errorf("record %d data runs past end of message", i)
+		}
+		r.data = msg[off+10 : off+10+n]
+		off += 10 + n
+		rrs = append(rrs, r)
+	}
+	if off != len(msg) {
+		return "", 0, nil, fmt.Errorf("%d trailing bytes after records", len(msg)-off)
+	}
+	return zone, zoneType, rrs, nil
+}
+
+// tsigFields TSIG RDATA 的字段
+type tsigFields struct {
+	alg        string
+	timeSigned uint64
+	fudge      uint16
+	mac        []byte
+	origID     uint16
+	err        uint16
+}
+
+func parseTSIGRData(data []byte) (tsigFields, error) {
+	var f tsigFields
+	alg, off, err := readWireName(data, 0)
+	if err != nil {
+		return f, err
+	}
+	f.alg = alg
+	if off+10 > len(data) {
+		return f, fmt.Errorf("truncated TSIG RDATA")
+	}
+	f.timeSigned = uint64(binary.BigEndian.Uint16(data[off:]))<<32 | uint64(binary.BigEndian.Uint32(data[off+2:]))
+	f.fudge = binary.BigEndian.Uint16(data[off+6:])
+	n := int(binary.BigEndian.Uint16(data[off+8:]))
+	if off+10+n+6 > len(data) {
+		return f, fmt.Errorf("truncated TSIG RDATA")
+	}
+	f.mac = data[off+10 : off+10+n]
+	off += 10 + n
+	f.origID = binary.BigEndian.Uint16(data[off:])
+	f.err = binary.BigEndian.Uint16(data[off+2:])
+	return f, nil
+}
+
+// tsigMAC RFC 8945 4.3: prior (请求 MAC，仅响应) + 去掉 TSIG 的报文 + TSIG 变量
+func tsigMAC(key *Key, prior, stripped []byte, f tsigFields) []byte {
+	h := hmac.New(sha256.New, key.Secret)
+	if prior != nil {
+		h.Write(binary.BigEndian.AppendUint16(nil, uint16(len(prior))))
+		h.Write(prior)
+	}
+	h.Write(stripped)
+	h.Write(wireName(key.Name))
+	h.Write([]byte{0, 255, 0, 0, 0, 0}) // CLASS ANY, TTL 0
+	h.Write(wireName(key.Algorithm))
+	h.Write([]byte{byte(f.timeSigned >> 40), byte(f.timeSigned >> 32), byte(f.timeSigned >> 24), byte(f.timeSigned >> 16), byte(f.timeSigned >> 8), byte(f.timeSigned)})
+	h.Write(binary.BigEndian.AppendUint16(nil, f.fudge))
+	h.Write(binary.BigEndian.AppendUint16(nil, f.err))
+	h.Write([]byte{0, 0}) // other len
+	return h.Sum(nil)
+}
+
+// verifyRequest 检查请求的 TSIG，返回请求 MAC
+func verifyRequest(msg []byte, rrs []parsedRR) ([]byte, error) {
+	if len(rrs) == 0 {
+		return nil, fmt.Errorf("request has no records")
+	}
+	tsig := rrs[len(rrs)-1]
+	if tsig.typ != 250 || tsig.class != 255 || tsig.name != testKey.Name {
+		return nil, fmt.Errorf("last record is not a TSIG for %s: %+v", testKey.Name, tsig)
+	}
+	f, err := parseTSIGRData(tsig.data)
+	if err != nil {
+		return nil, err
+	}
+	if f.alg != "hmac-sha256" {
+		return nil, fmt.Errorf("TSIG algorithm = %q", f.alg)
+	}
+	if d := time.Now().Unix() - int64(f.timeSigned); d < -5 || d > 5 {
+		return nil, fmt.Errorf("TSIG time off by %ds", d)
+	}
+
+	stripped := append([]byte(nil), msg[:tsig.start]...)
+	binary.BigEndian.PutUint16(stripped[0:], f.origID)
+	binary.BigEndian.PutUint16(stripped[10:], binary.BigEndian.Uint16(msg[10:])-1)
+	if want := tsigMAC(testKey, nil, stripped, f); !hmac.Equal(f.mac, want) {
+		return nil, fmt.Errorf("request TSIG MAC does not verify")
+	}
+	return f.mac, nil
+}
+
+// checkRequest 解析并验证签名，返回记录和请求 MAC
+func checkRequest(req []byte) ([]parsedRR, []byte, error) {
+	_, _, rrs, err := parseRequest(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	mac, err := verifyRequest(req, rrs)
+	return rrs, mac, err
+}
+
+// response 构造响应，key 不为 nil 时签名；tsigErr 为 TSIG 错误码 (此时不计算 MAC)
+func response(req []byte, rcode int, key *Key, requestMAC []byte, tsigErr uint16) []byte {
+	id := binary.BigEndian.Uint16(req)
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], 0x8000|5<<11|uint16(rcode))
+	if key == nil && tsigErr == 0 {
+		return msg
+	}
+
+	f := tsigFields{timeSigned: uint64(time.Now().Unix()), fudge: 300, origID: id, err: tsigErr}
+	if tsigErr == 0 {
+		f.mac = tsigMAC(key, requestMAC, msg, f)
+	}
+	rdata := wireName("hmac-sha256")
+	rdata = append(rdata, byte(f.timeSigned>>40), byte(f.timeSigned>>32), byte(f.timeSigned>>24), byte(f.timeSigned>>16), byte(f.timeSigned>>8), byte(f.timeSigned))
+	rdata = binary.BigEndian.AppendUint16(rdata, f.fudge)
+	rdata = binary.BigEndian.AppendUint16(rdata, uint16(len(f.mac)))
+	rdata = append(rdata, f.mac...)
+	rdata = binary.BigEndian.AppendUint16(rdata, f.origID)
+	rdata = binary.BigEndian.AppendUint16(rdata, f.err)
+	rdata = binary.BigEndian.AppendUint16(rdata, 0)
+
+	binary.BigEndian.PutUint16(msg[10:], 1)
+	msg = append(msg, wireName(testKey.Name)...)
+	msg = binary.BigEndian.AppendUint16(msg, 250)
+	msg = binary.BigEndian.AppendUint16(msg, 255)
+	msg = binary.BigEndian.AppendUint32(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(rdata)))
+	return append(msg, rdata...)
+}
+
+// handler 处理一个请求；返回错误时记为测试失败，不回复
+type handler func(req []byte) ([]byte, error)
+
+// serve 启动 UDP+TCP 服务器，udp/tcp 处理各自协议的请求
+func serve(t *testing.T, udp, tcp handler) string {
+	t.Helper()
+	var pc net.PacketConn
+	var ln net.Listener
+	for i := 0; ; i++ {
+		var err error
+		if pc, err = net.ListenPacket("udp", "127.0.0.1:0"); err != nil {
+			t.Fatal(err)
+		}
+		if ln, err = net.Listen("tcp", pc.LocalAddr().String()); err == nil {
+			break
+		}
+		pc.Close()
+		if i > 10 {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { pc.Close(); ln.Close() })
+
+	handle := func(h handler, req []byte) []byte {
+		if h == nil {
+			return nil
+		}
+		resp, err := h(req)
+		if err != nil {
+			t.Error(err)
+			return nil
+		}
+		return resp
+	}
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := handle(udp, append([]byte(nil), buf[:n]...)); resp != nil {
+				pc.WriteTo(resp, addr)
+			}
+		}
+	}()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			var size [2]byte
+			if _, err := io.ReadFull(conn, size[:]); err == nil {
+				req := make([]byte, binary.BigEndian.Uint16(size[:]))
+				if _, err := io.ReadFull(conn, req); err == nil {
+					if resp := handle(tcp, req); resp != nil {
+						conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+					}
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func testOptions(server string) Options {
+	return Options{Server: server, Zone: "example.com", Name: "home", TTL: 60, Key: testKey}
+}
+
+// update 以较短的超时发送更新，服务器不回复时测试不会挂住
+func update(server string, rtype uint16, ip string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return Update(ctx, testOptions(server), rtype, []net.IP{net.ParseIP(ip)})
+}
+
+func TestUpdateMessageLayout(t *testing.T) {
+	var checked atomic.Bool
+	server := serve(t, func(req []byte) ([]byte, error) {
+		if op := binary.BigEndian.Uint16(req[2:]) >> 11 & 0xf; op != opcodeUpdate {
+			return nil, fmt.Errorf("opcode = %d, want 5", op)
+		}
+		zone, zoneType, rrs, err := parseRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		if zone != "example.com" || zoneType != 6 {
+			return nil, fmt.Errorf("zone section = %s type %d, want example.com SOA", zone, zoneType)
+		}
+		if up := binary.BigEndian.Uint16(req[8:]); up != 2 {
+			return nil, fmt.Errorf("UPCOUNT = %d, want 2", up)
+		}
+		if ar := binary.BigEndian.Uint16(req[10:]); ar != 1 {
+			return nil, fmt.Errorf("ARCOUNT = %d, want 1", ar)
+		}
+		del, add := rrs[0], rrs[1]
+		if del.name != "home.example.com" || del.typ != 1 || del.class != 255 || del.ttl != 0 || len(del.data) != 0 {
+			return nil, fmt.Errorf("first update is not a delete-RRset: %+v", del)
+		}
+		if add.name != "home.example.com" || add.typ != 1 || add.class != 1 || add.ttl != 60 || !bytes.Equal(add.data, []byte{203, 0, 113, 7}) {
+			return nil, fmt.Errorf("second update is not the new A record: %+v", add)
+		}
+		mac, err := verifyRequest(req, rrs)
+		if err != nil {
+			return nil, err
+		}
+		checked.Store(true)
+		return response(req, 0, testKey, mac, 0), nil
+	}, nil)
+
+	if err := update(server, typeA, "203.0.113.7"); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if !checked.Load() {
+		t.Fatal("server did not check the update")
+	}
+}
+
+func TestUpdateAAAA(t *testing.T) {
+	server := serve(t, func(req []byte) ([]byte, error) {
+		rrs, mac, err := checkRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		if add := rrs[1]; add.typ != 28 || !net.IP(add.data).Equal(net.ParseIP("2001:db8::7")) {
+			return nil, fmt.Errorf("AAAA record = %+v", add)
+		}
+		return response(req, 0, testKey, mac, 0), nil
+	}, nil)
+
+	if err := update(server, typeAAAA, "2001:db8::7"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateResponses(t *testing.T) {
+	wrongKey := &Key{Name: testKey.Name, Algorithm: testKey.Algorithm, Secret: []byte("not the secret")}
+	tests := []struct {
+		name    string
+		respond func(req, mac []byte) []byte
+		want    string // 错误中应包含的文本，空表示成功
+	}{
+		{"noerror signed", func(req, mac []byte) []byte { return response(req, 0, testKey, mac, 0) }, ""},
+		// 错误响应可以不签名 (RFC 2845 4.6)，报告响应码而不是 "not signed"
+		{"refused unsigned", func(req, mac []byte) []byte { return response(req, 5, nil, nil, 0) }, "REFUSED"},
+		{"notauth badsig", func(req, mac []byte) []byte { return response(req, 9, nil, nil, 16) }, "NOTAUTH (BADSIG)"},
+		{"notauth badkey", func(req, mac []byte) []byte { return response(req, 9, nil, nil, 17) }, "NOTAUTH (BADKEY)"},
+		{"noerror unsigned", func(req, mac []byte) []byte { return response(req, 0, nil, nil, 0) }, "not signed"},
+		{"noerror bad signature", func(req, mac []byte) []byte { return response(req, 0, wrongKey, mac, 0) }, "signature mismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := serve(t, func(req []byte) ([]byte, error) {
+				_, mac, err := checkRequest(req)
+				if err != nil {
+					return nil, err
+				}
+				return tt.respond(req, mac), nil
+			}, nil)
+
+			err := update(server, typeA, "203.0.113.7")
+			switch {
+			case tt.want == "" && err != nil:
+				t.Fatalf("want success, got %v", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Fatalf("want error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestUpdateTruncatedFallsBackToTCP(t *testing.T) {
+	var overTCP atomic.Bool
+	server := serve(t,
+		func(req []byte) ([]byte, error) {
+			resp := response(req, 0, nil, nil, 0)
+			binary.BigEndian.PutUint16(resp[2:], binary.BigEndian.Uint16(resp[2:])|0x0200) // TC
+			return resp, nil
+		},
+		func(req []byte) ([]byte, error) {
+			_, mac, err := checkRequest(req)
+			if err != nil {
+				return nil, err
+			}
+			overTCP.Store(true)
+			return response(req, 0, testKey, mac, 0), nil
+		})
+
+	if err := update(server, typeA, "203.0.113.7"); err != nil {
+		t.Fatal(err)
+	}
+	if !overTCP.Load() {
+		t.Fatal("update was not retried over TCP")
+	}
+}
+
+// answer 回复标准查询: A 查询返回 published，其他类型无记录
+func answer(req []byte, published string) ([]byte, error) {
+	_, off, err := readWireName(req, 12)
+	if err != nil {
+		return nil, err
+	}
+	qtype := binary.BigEndian.Uint16(req[off:])
+	question := req[12 : off+4]
+
+	msg := make([]byte, 12)
+	copy(msg, req[:2])
+	binary.BigEndian.PutUint16(msg[2:], 0x8400|binary.BigEndian.Uint16(req[2:])&0x0100) // QR AA RD
+	binary.BigEndian.PutUint16(msg[4:], 1)
+	msg = append(msg, question...)
+	if qtype != typeA {
+		return msg, nil
+	}
+	binary.BigEndian.PutUint16(msg[6:], 1)
+	msg = append(msg, 0xc0, 12) // 指向问题中的域名
+	msg = binary.BigEndian.AppendUint16(msg, typeA)
+	msg = binary.BigEndian.AppendUint16(msg, 1)
+	msg = binary.BigEndian.AppendUint32(msg, 60)
+	msg = binary.BigEndian.AppendUint16(msg, 4)
+	return append(msg, net.ParseIP(published).To4()...), nil
+}
+
+// 发布的记录已是检测到的地址时不发送 UPDATE
+func TestSyncSkipsUpdateWhenInSync(t *testing.T) {
+	tests := []struct {
+		published string
+		updated   bool
+	}{
+		{"203.0.113.7", false},
+		{"203.0.113.1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.published, func(t *testing.T) {
+			var updates atomic.Int32
+			server := serve(t, func(req []byte) ([]byte, error) {
+				if opcode := req[2] >> 3 & 0xf; opcode != 5 {
+					return answer(req, tt.published)
+				}
+				updates.Add(1)
+				_, mac, err := checkRequest(req)
+				if err != nil {
+					return nil, err
+				}
+				return response(req, 0, testKey, mac, 0), nil
+			}, nil)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			rec := Sync(ctx, testOptions(server), "203.0.113.7")
+			if rec.Error != "" {
+				t.Fatal(rec.Error)
+			}
+			if strings.Join(rec.Published, ",") != tt.published {
+				t.Errorf("published = %v, want %s", rec.Published, tt.published)
+			}
+			want := int32(0)
+			if tt.updated {
+				want = 1
+			}
+			if rec.Updated != tt.updated || updates.Load() != want {
+				t.Errorf("updated = %v after %d UPDATE requests, want %v", rec.Updated, updates.Load(), tt.updated)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+/*
+Package dnsleak 查出 DNS 查询实际从哪个地址离开 (DNS 泄漏检测)
+
+依赖: internal/network, internal/route
+
+"whoami" 类域名的权威服务器会回答向它发起查询的地址，也就是
+递归解析器的出口，而不是本机:
+
+	whoami.akamai.net          A    -> 解析器出口 IP
+	o-o.myaddr.l.google.com    TXT  -> 解析器出口 IP (可能附带 edns0-client-subnet)
+
+分别通过系统解析器和 resolv.conf 中的每个服务器查询，得到各自的出口，
+再与 HTTP 检测到的公网 IP 比较 ASN。VPN 生效时 (默认路由走 tun/wg 等接口)，
+解析器出口却在另一个运营商网络里，说明 DNS 查询绕过了 VPN: 即 DNS 泄漏。
+
+默认路由、公网 IP 和地理位置查询都是可替换的字段；探测域名也可以自定义，
+如换成内部 DNS 上的 whoami 记录。
+*/
+package dnsleak
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github/shawn/ip-tool/internal/network"
+)
+
+// SystemResolver 系统解析器在报告中的名称
+const SystemResolver = "system"
+
+// queryTimeout 每个查询的超时
+const queryTimeout = 5 * time.Second
+
+// DefaultProbes 默认的探测域名
+var DefaultProbes = []string{
+	"whoami.akamai.net",
+	"o-o.myaddr.l.google.com TXT",
+}
+
+// publicResolvers 公共解析器运营商的 ASN
+//
+// 出口在这些网络里说明用的是公共 DNS，不能据 ASN 判断是否经过 VPN
+var publicResolvers = map[string]string{
+	"AS13335": "Cloudflare",
+	"AS15169": "Google Public DNS",
+	"AS19281": "Quad9",
+	"AS36692": "OpenDNS",
+}
+
+// Query 一个解析器对一个探测域名的结果
+type Query struct {
+	Resolver     string   `json:"resolver" yaml:"resolver"` // "system" 或服务器地址
+	Probe        string   `json:"probe" yaml:"probe"`
+	Egress       []string `json:"egress,omitempty" yaml:"egress,omitempty"`
+	ClientSubnet string   `json:"client_subnet,omitempty" yaml:"client_subnet,omitempty"` // 解析器转发的 EDNS Client Subnet
+	Error        string   `json:"error,omitempty" yaml:"error,omitempty"`
+	Duration     int64    `json:"duration_ms" yaml:"duration_ms"`
+}
+
+// Egress 一个解析器出口地址
+type Egress struct {
+	IP        string   `json:"ip" yaml:"ip"`
+	AS        string   `json:"as,omitempty" yaml:"as,omitempty"`
+	ISP       string   `json:"isp,omitempty" yaml:"isp,omitempty"`
+	Country   string   `json:"country,omitempty" yaml:"country,omitempty"`
+	City      string   `json:"city,omitempty" yaml:"city,omitempty"`
+	Operator  string   `json:"operator,omitempty" yaml:"operator,omitempty"` // 公共 DNS 运营商
+	Resolvers []string `json:"resolvers" yaml:"resolvers"`                   // 经过此出口的解析器
+	Leak      bool     `json:"leak" yaml:"leak"`
+}
+
+// Report 检测结果
+type Report struct {
+	Public       string   `json:"public,omitempty" yaml:"public,omitempty"` // HTTP 检测的公网 IP
+	PublicAS     string   `json:"public_as,omitempty" yaml:"public_as,omitempty"`
+	GeoError     string   `json:"geo_error,omitempty" yaml:"geo_error,omitempty"` // 查询 ASN 和位置失败的原因
+	VPN          bool     `json:"vpn" yaml:"vpn"`
+	VPNInterface string   `json:"vpn_interface,omitempty" yaml:"vpn_interface,omitempty"`
+	Resolvers    []string `json:"resolvers" yaml:"resolvers"`
+	Queries      []Query  `json:"queries" yaml:"queries"`
+	Egresses     []Egress `json:"egresses" yaml:"egresses"`
+	Leak         bool     `json:"leak" yaml:"leak"`
+	Verdict      string   `json:"verdict" yaml:"verdict"`
+}
+
+// Checker 检测配置
+type Checker struct {
+	Probes    []Probe  // 探测域名
+	Resolvers []string // 额外查询的服务器 (resolv.conf 之外)
+	AssumeVPN bool     // 无法从路由识别 VPN 时由用户声明
+
+	ResolvConfPath string
+
+	// 外部依赖，默认使用真实实现，测试时可替换
+	DefaultInterface func() string
+	FetchIPv4        func(ctx context.Context) (string, error)
+	FetchGeo         func(ctx context.Context, ips []string) (map[string]*network.GeoInfo, error)
+}
+
+// New 创建使用真实系统和网络的 Checker
+func New(probes []Probe) *Checker {
+	return &Checker{
+		Probes:           probes,
+		ResolvConfPath:   "/etc/resolv.conf",
+		DefaultInterface: defaultInterface,
+		FetchIPv4:        network.FetchPublicIPv4,
+		FetchGeo:         network.FetchGeoBatch,
+	}
+}
+
+// Run 并发执行所有查询，补充地理信息后比较
+func (c *Checker) Run(ctx context.Context) *Report {
+	report := &Report{Resolvers: []string{SystemResolver}}
+	servers, _ := nameservers(c.ResolvConfPath)
+	for _, s := range append(servers, c.Resolvers...) {
+		s = withPort(s)
+		if !contains(report.Resolvers, s) {
+			report.Resolvers = append(report.Resolvers, s)
+		}
+	}
+
+	if iface := c.DefaultInterface(); isTunnel(iface) {
+		report.VPN, report.VPNInterface = true, iface
+	}
+	report.VPN = report.VPN || c.AssumeVPN
+
+	report.Queries = make([]Query, len(report.Resolvers)*len(c.Probes))
+	var wg sync.WaitGroup
+	for i, resolver := range report.Resolvers {
+		for j, probe := range c.Probes {
+			wg.Add(1)
+			go func(k int, resolver string, probe Probe) {
+				defer wg.Done()
+				report.Queries[k] = query(ctx, resolver, probe)
+			}(i*len(c.Probes)+j, resolver, probe)
+		}
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		report.Public, _ = c.FetchIPv4(ctx)
+	}()
+	wg.Wait()
+
+	report.collect()
+	c.enrich(ctx, report)
+	report.analyze()
+	return report
+}
+
+// collect 汇总出口地址及经过它的解析器
+func (r *Report) collect() {
+	index := make(map[string]int)
+	for _, q := range r.Queries {
+		for _, addr := range q.Egress {
+			i, ok := index[addr]
+			if !ok {
+				i = len(r.Egresses)
+				index[addr] = i
+				r.Egresses = append(r.Egresses, Egress{IP: addr})
+			}
+			if !contains(r.Egresses[i].Resolvers, q.Resolver) {
+				r.Egresses[i].Resolvers = append(r.Egresses[i].Resolvers, q.Resolver)
+			}
+		}
+	}
+	sort.SliceStable(r.Egresses, func(i, j int) bool {
+		return len(r.Egresses[i].Resolvers) > len(r.Egresses[j].Resolvers)
+	})
+}
+
+// enrich 一次批量查询出口与公网 IP 的 ASN 和位置
+func (c *Checker) enrich(ctx context.Context, r *Report) {
+	ips := make([]string, 0, len(r.Egresses)+1)
+	for _, e := range r.Egresses {
+		ips = append(ips, e.IP)
+	}
+	if r.Public != "" {
+		ips = append(ips, r.Public)
+	}
+	if len(ips) == 0 {
+		return
+	}
+
+	// 失败时写入报告，否则结论只能说 "公网 IP 的网络未知"，看不出原因
+	geo, err := c.FetchGeo(ctx, ips)
+	if err != nil {
+		r.GeoError = err.Error()
+		return
+	}
+	if g := geo[r.Public]; g != nil && g.IsSuccess() {
+		r.PublicAS = g.AS
+	}
+	for i := range r.Egresses {
+		e := &r.Egresses[i]
+		if g := geo[e.IP]; g != nil && g.IsSuccess() {
+			e.AS, e.ISP, e.Country, e.City = g.AS, g.ISP, g.Country, g.City
+			e.Operator = publicResolvers[asn(g.AS)]
+		}
+	}
+}
+
+// analyze 判断是否泄漏并给出结论
+//
+// 只有 VPN 生效、出口与公网 IP 不在同一 ASN、且不是公共 DNS 时才算泄漏
+func (r *Report) analyze() {
+	if len(r.Egresses) == 0 {
+		r.Verdict = "no probe answered, cannot tell where DNS queries egress"
+		return
+	}
+
+	publicASN := asn(r.PublicAS)
+	var leaks []string
+	for i := range r.Egresses {
+		e := &r.Egresses[i]
+		if !r.VPN || publicASN == "" || e.AS == "" || e.Operator != "" {
+			continue
+		}
+		if asn(e.AS) != publicASN {
+			e.Leak = true
+			leaks = append(leaks, e.AS)
+		}
+	}
+
+	switch {
+	case len(leaks) > 0:
+		r.Leak = true
+		r.Verdict = "DNS leak: queries egress via " + strings.Join(unique(leaks), ", ") +
+			" while web traffic leaves via " + r.PublicAS
+	case !r.VPN:
+		r.Verdict = "no VPN detected, DNS egress is expected to be your ISP or DNS provider"
+	case publicASN == "" && r.GeoError != "":
+		r.Verdict = "VPN active, but the AS lookup failed (" + r.GeoError + ") so egress cannot be compared"
+	case publicASN == "":
+		r.Verdict = "VPN active, but the public IP's network is unknown so egress cannot be compared"
+	default:
+		r.Verdict = "no leak: DNS egresses through the VPN's network or a public DNS provider"
+	}
+}
+
+// asn "AS15169 Google LLC" -> "AS15169"
+func asn(as string) string {
+	n, _, _ := strings.Cut(as, " ")
+	return n
+}
+
+// contains 切片中是否包含 s
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// unique 去重并保持顺序
+func unique(list []string) []string {
+	var out []string
+	for _, s := range list {
+		if !contains(out, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
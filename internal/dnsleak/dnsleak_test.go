@@ -0,0 +1,149 @@
+package dnsleak
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github/shawn/ip-tool/internal/network"
+)
+
+// geo 成功的地理位置查询结果
+func geo(as string) *network.GeoInfo {
+	return &network.GeoInfo{Status: "success", AS: as, ISP: as, Country: "Testland"}
+}
+
+// 查询结果按 IP 给出 ASN 的 FetchGeo 替身
+func fetchGeo(byIP map[string]*network.GeoInfo) func(context.Context, []string) (map[string]*network.GeoInfo, error) {
+	return func(_ context.Context, ips []string) (map[string]*network.GeoInfo, error) {
+		out := make(map[string]*network.GeoInfo, len(ips))
+		for _, ip := range ips {
+			if g, ok := byIP[ip]; ok {
+				out[ip] = g
+			}
+		}
+		return out, nil
+	}
+}
+
+// 公网 IP 203.0.113.1 在 AS64500 (VPN 运营商)
+var testGeo = map[string]*network.GeoInfo{
+	"203.0.113.1":  geo("AS64500 VPN Provider"),
+	"198.51.100.7": geo("AS64500 VPN Provider"),
+	"192.0.2.53":   geo("AS64501 Home ISP"),
+	"172.253.1.1":  geo("AS15169 Google LLC"),
+	"162.158.1.1":  geo("AS13335 Cloudflare, Inc."),
+}
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name     string
+		vpn      bool
+		egresses []string
+		leak     bool
+		leaked   []string // Leak 为 true 的出口
+		verdict  string
+	}{
+		{
+			name:     "vpn on, egress in another ASN",
+			vpn:      true,
+			egresses: []string{"198.51.100.7", "192.0.2.53"},
+			leak:     true,
+			leaked:   []string{"192.0.2.53"},
+			verdict:  "DNS leak: queries egress via AS64501 Home ISP while web traffic leaves via AS64500 VPN Provider",
+		},
+		{
+			name:     "vpn on, egress in the VPN's ASN",
+			vpn:      true,
+			egresses: []string{"198.51.100.7"},
+			verdict:  "no leak",
+		},
+		{
+			name:     "vpn on, public DNS operators",
+			vpn:      true,
+			egresses: []string{"172.253.1.1", "162.158.1.1"},
+			verdict:  "no leak",
+		},
+		{
+			name:     "vpn off",
+			egresses: []string{"192.0.2.53"},
+			verdict:  "no VPN detected",
+		},
+		{
+			name:    "no egress",
+			vpn:     true,
+			verdict: "no probe answered",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Report{Public: "203.0.113.1", VPN: tt.vpn}
+			for _, ip := range tt.egresses {
+				r.Queries = append(r.Queries, Query{Resolver: SystemResolver, Egress: []string{ip}})
+			}
+			r.collect()
+			(&Checker{FetchGeo: fetchGeo(testGeo)}).enrich(context.Background(), r)
+			r.analyze()
+
+			if r.Leak != tt.leak {
+				t.Errorf("leak = %v, want %v", r.Leak, tt.leak)
+			}
+			var leaked []string
+			for _, e := range r.Egresses {
+				if e.Leak {
+					leaked = append(leaked, e.IP)
+				}
+			}
+			if strings.Join(leaked, ",") != strings.Join(tt.leaked, ",") {
+				t.Errorf("leaked egresses = %v, want %v", leaked, tt.leaked)
+			}
+			if !strings.HasPrefix(r.Verdict, tt.verdict) {
+				t.Errorf("verdict = %q, want prefix %q", r.Verdict, tt.verdict)
+			}
+		})
+	}
+}
+
+func TestEnrich(t *testing.T) {
+	r := &Report{Public: "203.0.113.1", Queries: []Query{
+		{Resolver: SystemResolver, Egress: []string{"172.253.1.1"}},
+		{Resolver: "192.0.2.1:53", Egress: []string{"172.253.1.1", "192.0.2.53"}},
+	}}
+	r.collect()
+	(&Checker{FetchGeo: fetchGeo(testGeo)}).enrich(context.Background(), r)
+
+	if r.PublicAS != "AS64500 VPN Provider" || r.GeoError != "" {
+		t.Errorf("public AS = %q, geo error = %q", r.PublicAS, r.GeoError)
+	}
+	// 经过的解析器多的出口在前
+	if len(r.Egresses) != 2 || r.Egresses[0].IP != "172.253.1.1" || len(r.Egresses[0].Resolvers) != 2 {
+		t.Fatalf("egresses = %+v", r.Egresses)
+	}
+	if e := r.Egresses[0]; e.Operator != "Google Public DNS" || e.Country != "Testland" {
+		t.Errorf("egress = %+v", e)
+	}
+	if e := r.Egresses[1]; e.Operator != "" || e.AS != "AS64501 Home ISP" {
+		t.Errorf("egress = %+v", e)
+	}
+}
+
+// 地理位置查询失败时原因写入报告和结论
+func TestEnrichGeoError(t *testing.T) {
+	r := &Report{Public: "203.0.113.1", VPN: true, Queries: []Query{
+		{Resolver: SystemResolver, Egress: []string{"192.0.2.53"}},
+	}}
+	r.collect()
+	checker := &Checker{FetchGeo: func(context.Context, []string) (map[string]*network.GeoInfo, error) {
+		return nil, errors.New("rate limited")
+	}}
+	checker.enrich(context.Background(), r)
+	r.analyze()
+
+	if r.GeoError != "rate limited" {
+		t.Errorf("geo error = %q, want %q", r.GeoError, "rate limited")
+	}
+	if r.Leak || !strings.Contains(r.Verdict, "rate limited") {
+		t.Errorf("leak = %v, verdict = %q; want no leak and the geo error", r.Leak, r.Verdict)
+	}
+}
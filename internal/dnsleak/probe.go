@@ -0,0 +1,175 @@
+package dnsleak
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github/shawn/ip-tool/internal/route"
+)
+
+// Probe 一个探测域名
+type Probe struct {
+	Name string // 完全限定域名
+	Type string // "A" (含 AAAA) 或 "TXT"
+}
+
+// String 与 ParseProbe 的格式一致
+func (p Probe) String() string {
+	if p.Type == "A" {
+		return p.Name
+	}
+	return p.Name + " " + p.Type
+}
+
+// ParseProbe 解析 "name" 或 "name TXT" (与 dig 参数顺序一致)
+func ParseProbe(s string) (Probe, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return Probe{}, fmt.Errorf("invalid probe %q, use \"name\" or \"name TXT\"", s)
+	}
+
+	p := Probe{Name: strings.TrimSuffix(fields[0], "."), Type: "A"}
+	if len(fields) == 2 {
+		p.Type = strings.ToUpper(fields[1])
+	}
+	switch p.Type {
+	case "A", "TXT":
+	case "AAAA":
+		p.Type = "A"
+	default:
+		return Probe{}, fmt.Errorf("invalid probe type %q, use A or TXT", fields[1])
+	}
+	return p, nil
+}
+
+// ParseProbes 解析多个探测域名
+func ParseProbes(list []string) ([]Probe, error) {
+	probes := make([]Probe, 0, len(list))
+	for _, s := range list {
+		p, err := ParseProbe(s)
+		if err != nil {
+			return nil, err
+		}
+		probes = append(probes, p)
+	}
+	return probes, nil
+}
+
+// query 通过 resolver 查询探测域名
+func query(ctx context.Context, resolver string, probe Probe) Query {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	q := Query{Resolver: resolver, Probe: probe.String()}
+	r := net.DefaultResolver
+	if resolver != SystemResolver {
+		r = resolverFor(resolver)
+	}
+
+	start := time.Now()
+	var err error
+	switch probe.Type {
+	case "TXT":
+		var txts []string
+		txts, err = r.LookupTXT(ctx, probe.Name+".")
+		for _, txt := range txts {
+			txt = strings.TrimSpace(txt)
+			if subnet, ok := strings.CutPrefix(txt, "edns0-client-subnet "); ok {
+				q.ClientSubnet = subnet
+			} else if addr := net.ParseIP(txt); addr != nil {
+				q.Egress = append(q.Egress, addr.String())
+			}
+		}
+	default:
+		var addrs []net.IP
+		addrs, err = r.LookupIP(ctx, "ip", probe.Name+".")
+		for _, addr := range addrs {
+			q.Egress = append(q.Egress, addr.String())
+		}
+	}
+	q.Duration = time.Since(start).Milliseconds()
+
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr) && dnsErr.IsTimeout:
+		q.Error = "timeout"
+	case errors.As(err, &dnsErr):
+		// DNSError 中的服务器是 resolv.conf 里的，不是实际拨号的地址
+		q.Error = dnsErr.Err
+	case err != nil:
+		q.Error = err.Error()
+	case len(q.Egress) == 0:
+		q.Error = "no address in answer"
+	}
+	return q
+}
+
+// resolverFor 只向 server 查询的解析器
+func resolverFor(server string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, proto, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, proto, server)
+		},
+	}
+}
+
+// withPort 没有端口时补上 53
+func withPort(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(strings.Trim(server, "[]"), "53")
+}
+
+// nameservers 读取 resolv.conf 中的 nameserver
+func nameservers(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	return servers, scanner.Err()
+}
+
+// tunnelPrefixes 常见 VPN 接口的名称前缀
+var tunnelPrefixes = []string{"tun", "tap", "wg", "ppp", "utun", "ipsec", "nordlynx", "proton", "mullvad", "tailscale", "zt"}
+
+// isTunnel 接口名称是否像 VPN 隧道
+func isTunnel(iface string) bool {
+	iface = strings.ToLower(iface)
+	for _, prefix := range tunnelPrefixes {
+		if strings.HasPrefix(iface, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultInterface 访问互联网时使用的接口
+func defaultInterface() string {
+	routes, err := route.Table()
+	if err != nil {
+		return ""
+	}
+	if r := route.Lookup(routes, net.IPv4(1, 1, 1, 1)); r != nil {
+		return r.Interface
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package dnsleak
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProbes(t *testing.T) {
+	probes, err := ParseProbes([]string{
+		"whoami.akamai.net",
+		"o-o.myaddr.l.google.com TXT",
+		"whoami.example.com. aaaa",
+		"  txt.example.com   txt ",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Probe{
+		{Name: "whoami.akamai.net", Type: "A"},
+		{Name: "o-o.myaddr.l.google.com", Type: "TXT"},
+		{Name: "whoami.example.com", Type: "A"},
+		{Name: "txt.example.com", Type: "TXT"},
+	}
+	if !reflect.DeepEqual(probes, want) {
+		t.Errorf("probes = %+v, want %+v", probes, want)
+	}
+
+	// String 与 ParseProbe 的格式一致
+	for _, p := range want {
+		if got, err := ParseProbe(p.String()); err != nil || got != p {
+			t.Errorf("ParseProbe(%q) = %+v, %v", p.String(), got, err)
+		}
+	}
+
+	if probes, err := ParseProbes(DefaultProbes); err != nil || len(probes) != len(DefaultProbes) {
+		t.Errorf("default probes: %+v, %v", probes, err)
+	}
+}
+
+func TestParseProbesInvalid(t *testing.T) {
+	for _, list := range [][]string{
+		{""},
+		{"whoami.akamai.net", "example.com MX"},
+		{"example.com TXT extra"},
+	} {
+		if probes, err := ParseProbes(list); err == nil {
+			t.Errorf("ParseProbes(%q) = %+v, want error", list, probes)
+		}
+	}
+}
@@ -0,0 +1,394 @@
+/*
+各项检查
+
+每项检查只返回 Check，不返回错误: 检查失败本身就是结果。
+*/
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github/shawn/ip-tool/internal/ip"
+	"github/shawn/ip-tool/internal/route"
+)
+
+// dnsTimeout 单个 DNS 服务器的查询超时
+const dnsTimeout = 3 * time.Second
+
+// maxNameservers glibc 只使用前 3 个 nameserver
+const maxNameservers = 3
+
+// defaultQueryName 没有目标时用于测试 DNS 的域名
+const defaultQueryName = "example.com"
+
+// 出口探测目标: 只用于路由选择，不会发送数据包
+const (
+	probeIPv4 = "8.8.8.8:53"
+	probeIPv6 = "[2001:4860:4860::8888]:53"
+)
+
+// proxyVars 影响 HTTP 请求的代理环境变量
+var proxyVars = []string{
+	"HTTPS_PROXY", "https_proxy",
+	"HTTP_PROXY", "http_proxy",
+	"ALL_PROXY", "all_proxy",
+}
+
+// checkResolvConf 检查解析器配置
+func (d *Doctor) checkResolvConf(rc *resolvConf, err error) Check {
+	c := Check{ID: "resolv_conf", Name: "Resolver config"}
+
+	if err != nil {
+		if os.IsNotExist(err) && runtime.GOOS == "windows" {
+			c.Status, c.Message = StatusSkip, "not used on Windows"
+			return c
+		}
+		c.Status = StatusFail
+		c.Message = fmt.Sprintf("cannot read %s: %v", d.ResolvConfPath, err)
+		c.Suggestion = "Restore " + d.ResolvConfPath + " or restart the network manager"
+		return c
+	}
+
+	c.Details = append(c.Details, "nameserver: "+orNone(rc.Nameservers))
+	c.Details = append(c.Details, "search: "+orNone(rc.Search))
+	if len(rc.Options) > 0 {
+		c.Details = append(c.Details, "options: "+strings.Join(rc.Options, " "))
+	}
+
+	switch {
+	case len(rc.Nameservers) == 0:
+		c.Status = StatusFail
+		c.Message = "no nameserver configured, the resolver falls back to 127.0.0.1"
+		c.Suggestion = "Add a line like 'nameserver 1.1.1.1' to " + d.ResolvConfPath
+	case len(rc.Nameservers) > maxNameservers:
+		c.Status = StatusWarn
+		c.Message = fmt.Sprintf("%d nameservers, only the first %d are used", len(rc.Nameservers), maxNameservers)
+		c.Suggestion = "Remove the extra nameserver lines from " + d.ResolvConfPath
+	default:
+		c.Status = StatusPass
+		c.Message = fmt.Sprintf("%d nameserver(s)", len(rc.Nameservers))
+		if isLoopback(rc.Nameservers[0]) {
+			c.Message += ", local stub resolver (e.g. systemd-resolved)"
+		}
+	}
+	return c
+}
+
+// checkHosts 检查目标是否被 hosts 文件覆盖
+func (d *Doctor) checkHosts() Check {
+	c := Check{ID: "hosts", Name: "Hosts file"}
+
+	switch {
+	case d.Target == "":
+		c.Status, c.Message = StatusSkip, "no target given"
+		return c
+	case net.ParseIP(d.Target) != nil:
+		c.Status, c.Message = StatusSkip, "target is an IP address"
+		return c
+	}
+
+	addrs, err := hostsLookup(d.HostsPath, d.Target)
+	if err != nil {
+		c.Status, c.Message = StatusSkip, fmt.Sprintf("cannot read %s", d.HostsPath)
+		return c
+	}
+	if len(addrs) > 0 && isLocalhost(d.Target, addrs) {
+		c.Status, c.Message = StatusPass, d.Target+" maps to loopback (standard entry)"
+		return c
+	}
+	if len(addrs) > 0 {
+		c.Status = StatusWarn
+		c.Message = fmt.Sprintf("%s is overridden: %s", d.Target, strings.Join(addrs, ", "))
+		c.Suggestion = "Remove the entry from " + d.HostsPath + " if this is not intended"
+		return c
+	}
+	c.Status, c.Message = StatusPass, fmt.Sprintf("%s not overridden", d.Target)
+	return c
+}
+
+// checkRoute 检查默认路由
+//
+// IPv4 没有默认路由算失败；IPv6 很多网络本就没有，只算警告
+func (d *Doctor) checkRoute(id, name, family string, routes []route.Route, tableErr error) Check {
+	c := Check{ID: id, Name: name}
+
+	probe, missing := probeIPv4, StatusFail
+	if family == "ipv6" {
+		probe, missing = probeIPv6, StatusWarn
+	}
+	source := d.Egress(probe)
+
+	if tableErr != nil {
+		// 读不到路由表时只能看内核能否选出源地址
+		if source != "" {
+			c.Status, c.Message = StatusPass, "kernel has a route (source "+source+")"
+		} else {
+			c.Status, c.Message = missing, "no route to the Internet"
+		}
+		c.Details = []string{tableErr.Error()}
+		return c
+	}
+
+	for _, r := range routes {
+		if r.Family == family && r.IsDefault() {
+			c.Status, c.Message = StatusPass, r.String()
+			if source != "" {
+				c.Details = []string{"source: " + source}
+			}
+			return c
+		}
+	}
+
+	// 策略路由 (VPN) 可能把默认路由放在其他表中
+	if source != "" {
+		c.Status = StatusWarn
+		c.Message = "no default route in the main table, but the kernel picks source " + source
+		c.Suggestion = "Policy routing (VPN?) is in effect; inspect it with: ip rule; ip route show table all"
+		return c
+	}
+
+	c.Status = missing
+	c.Message = "no default route"
+	if family == "ipv6" {
+		c.Suggestion = "IPv6 sites are unreachable; ignore this if your network has no IPv6"
+	} else {
+		c.Suggestion = "Check the cable/Wi-Fi and DHCP, or add one: ip route add default via <gateway>"
+	}
+	return c
+}
+
+// checkProxy 检查代理环境变量
+func (d *Doctor) checkProxy() Check {
+	c := Check{ID: "proxy_env", Name: "Proxy environment"}
+
+	for _, key := range proxyVars {
+		if v := d.Getenv(key); v != "" {
+			c.Details = append(c.Details, key+"="+redactProxy(v))
+		}
+	}
+	if v := d.Getenv("NO_PROXY") + d.Getenv("no_proxy"); v != "" && len(c.Details) > 0 {
+		c.Details = append(c.Details, "NO_PROXY="+v)
+	}
+
+	if len(c.Details) == 0 {
+		c.Status, c.Message = StatusPass, "no proxy configured"
+		return c
+	}
+	c.Status = StatusWarn
+	c.Message = "HTTP requests go through a proxy, so the detected public IP is the proxy's"
+	c.Suggestion = "To see this host's own IP: env -u HTTPS_PROXY -u https_proxy ipq"
+	return c
+}
+
+// checkDNS 检查每个 nameserver 是否应答
+func (d *Doctor) checkDNS(ctx context.Context, rc *resolvConf) Check {
+	c := Check{ID: "dns_servers", Name: "DNS servers"}
+	if rc == nil || len(rc.Nameservers) == 0 {
+		c.Status, c.Message = StatusSkip, "no nameserver to test"
+		return c
+	}
+
+	name := defaultQueryName
+	if d.Target != "" && net.ParseIP(d.Target) == nil {
+		name = d.Target
+	}
+
+	servers := rc.Nameservers
+	if len(servers) > maxNameservers {
+		servers = servers[:maxNameservers]
+	}
+
+	answered := 0
+	for _, server := range servers {
+		start := time.Now()
+		if err := d.QueryDNS(ctx, server, name); err != nil {
+			c.Details = append(c.Details, fmt.Sprintf("%s: %v", server, err))
+			continue
+		}
+		answered++
+		c.Details = append(c.Details, fmt.Sprintf("%s: answered in %s", server, time.Since(start).Round(time.Millisecond)))
+	}
+
+	switch {
+	case answered == len(servers):
+		c.Status = StatusPass
+		c.Message = fmt.Sprintf("%d/%d answered for %s", answered, len(servers), name)
+	case answered > 0:
+		c.Status = StatusWarn
+		c.Message = fmt.Sprintf("%d/%d answered for %s, lookups may be slow", answered, len(servers), name)
+		c.Suggestion = "Remove the unresponsive nameserver from " + d.ResolvConfPath
+	default:
+		c.Status = StatusFail
+		c.Message = fmt.Sprintf("no nameserver answered for %s", name)
+		c.Suggestion = "Check the firewall for UDP/53, or try a public resolver: dig @1.1.1.1 " + name
+	}
+	return c
+}
+
+// checkPublicIPv4 检测公网 IPv4 并与本机出口地址比较
+func (d *Doctor) checkPublicIPv4(ctx context.Context) Check {
+	c := Check{ID: "public_ipv4", Name: "Public IPv4"}
+	source := d.Egress(probeIPv4)
+
+	public, err := d.FetchIPv4(ctx)
+	if err != nil {
+		c.Status = StatusFail
+		c.Message = "not detected: " + err.Error()
+		c.Suggestion = "Check HTTPS egress (firewall, proxy) with: ipq route get 1.1.1.1"
+		return c
+	}
+	if addr := net.ParseIP(public); addr == nil || addr.To4() == nil {
+		c.Status = StatusFail
+		c.Message = fmt.Sprintf("echo service returned %q, not an IPv4 address", truncate(public, 40))
+		c.Suggestion = "A captive portal or intercepting proxy may be answering; open a browser to log in"
+		return c
+	}
+
+	c.Status = StatusPass
+	switch {
+	case source == "":
+		c.Message = public
+	case source == public:
+		c.Message = public + " (assigned to this host, no NAT)"
+	default:
+		c.Message = public + " (NAT from " + source + ")"
+		if ip.Classify(source) == ip.TypePublic {
+			c.Status = StatusWarn
+			c.Message = public + ", but this host's public source is " + source
+			c.Suggestion = "Traffic is leaving through another address (proxy or VPN?)"
+		}
+	}
+	return c
+}
+
+// checkPublicIPv6 检测公网 IPv6 并与本机出口地址比较
+//
+// 没有 IPv6 很常见，只算警告
+func (d *Doctor) checkPublicIPv6(ctx context.Context) Check {
+	c := Check{ID: "public_ipv6", Name: "Public IPv6"}
+	source := d.Egress(probeIPv6)
+	global := source != "" && ip.Classify(source) == ip.TypePublic
+
+	public, err := d.FetchIPv6(ctx)
+	if err != nil {
+		c.Status = StatusWarn
+		if global {
+			c.Message = "host has global address " + source + " but IPv6 Internet access fails"
+			c.Suggestion = "Check the IPv6 firewall and router advertisements, or disable IPv6 to avoid slow fallbacks"
+		} else {
+			c.Message = "no IPv6 connectivity"
+			c.Suggestion = "Ignore this if your network has no IPv6"
+		}
+		return c
+	}
+	if addr := net.ParseIP(public); addr == nil || addr.To4() != nil {
+		c.Status = StatusFail
+		c.Message = fmt.Sprintf("echo service returned %q, not an IPv6 address", truncate(public, 40))
+		c.Suggestion = "A captive portal or intercepting proxy may be answering"
+		return c
+	}
+
+	switch {
+	case source == public:
+		c.Status, c.Message = StatusPass, public+" (assigned to this host)"
+	case source == "":
+		c.Status, c.Message = StatusPass, public
+	default:
+		// IPv6 通常不做 NAT，地址不一致意味着 NPTv6 或代理
+		c.Status = StatusWarn
+		c.Message = public + " differs from this host's source " + source
+		c.Suggestion = "Prefix translation (NPTv6) or a proxy is rewriting IPv6 traffic"
+	}
+	return c
+}
+
+// queryDNS 直接向指定服务器查询 A 记录
+//
+// server 可以带端口 (默认 53)；NXDOMAIN 也算应答: 只关心服务器是否工作
+func queryDNS(ctx context.Context, server, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout)
+	defer cancel()
+
+	addr := server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		addr = net.JoinHostPort(server, "53")
+	}
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, proto, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, proto, addr)
+		},
+	}
+
+	_, err := resolver.LookupIP(ctx, "ip4", strings.TrimSuffix(name, ".")+".")
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		switch {
+		case dnsErr.IsNotFound:
+			return nil
+		case dnsErr.IsTimeout:
+			return fmt.Errorf("timeout (>%s)", dnsTimeout)
+		}
+		// DNSError 中的服务器是 resolv.conf 里的，不是实际拨号的地址
+		return errors.New(dnsErr.Err)
+	}
+	return err
+}
+
+// redactProxy 隐藏代理 URL 中的密码
+func redactProxy(v string) string {
+	u, err := url.Parse(v)
+	if err != nil || u.User == nil {
+		return v
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxx")
+	}
+	return u.String()
+}
+
+// isLoopback 是否为回环地址
+func isLoopback(s string) bool {
+	addr := net.ParseIP(s)
+	return addr != nil && addr.IsLoopback()
+}
+
+// isLocalhost localhost 指向回环地址是标准条目，不算覆盖
+func isLocalhost(name string, addrs []string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if name != "localhost" && !strings.HasSuffix(name, ".localhost") {
+		return false
+	}
+	for _, a := range addrs {
+		if !isLoopback(a) {
+			return false
+		}
+	}
+	return true
+}
+
+// orNone 连接列表，为空时返回 "(none)"
+func orNone(list []string) string {
+	if len(list) == 0 {
+		return "(none)"
+	}
+	return strings.Join(list, " ")
+}
+
+// truncate 截断过长的字符串
+func truncate(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) > n {
+		return s[:n] + "…"
+	}
+	return s
+}
@@ -0,0 +1,158 @@
+/*
+Package doctor 诊断本机的网络连通性和解析器配置
+
+依赖: internal/ip, internal/local, internal/network, internal/route
+
+"ipq 查不到" 时，问题往往不在 ipq 本身，而在本机:
+resolv.conf 写错、hosts 覆盖、缺默认路由、代理环境变量、DNS 不应答……
+doctor 依次检查这些环节，每项给出 pass/warn/fail 和修复建议。
+
+CLI Guidelines 原则 - Human-first Design:
+- 每个问题都附带可操作的建议 (与 output.NewError 一致)
+- JSON 输出包含主机名和时间，便于批量收集
+
+所有外部依赖 (文件路径、路由表、网络查询) 都是可替换的字段，
+测试时可以用本地替身代替真实网络。
+*/
+package doctor
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github/shawn/ip-tool/internal/local"
+	"github/shawn/ip-tool/internal/network"
+	"github/shawn/ip-tool/internal/route"
+)
+
+// Status 检查结果
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// Check 一项检查的结果
+type Check struct {
+	ID         string   `json:"id" yaml:"id"` // 稳定的标识，便于批量分析
+	Name       string   `json:"name" yaml:"name"`
+	Status     Status   `json:"status" yaml:"status"`
+	Message    string   `json:"message" yaml:"message"`
+	Details    []string `json:"details,omitempty" yaml:"details,omitempty"`
+	Suggestion string   `json:"suggestion,omitempty" yaml:"suggestion,omitempty"`
+}
+
+// Summary 各状态的数量
+type Summary struct {
+	Pass int `json:"pass" yaml:"pass"`
+	Warn int `json:"warn" yaml:"warn"`
+	Fail int `json:"fail" yaml:"fail"`
+	Skip int `json:"skip" yaml:"skip"`
+}
+
+// Report 完整的诊断报告
+type Report struct {
+	Hostname string    `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	Time     time.Time `json:"time" yaml:"time"`
+	Target   string    `json:"target,omitempty" yaml:"target,omitempty"`
+	Status   Status    `json:"status" yaml:"status"` // 最差的一项
+	Summary  Summary   `json:"summary" yaml:"summary"`
+	Checks   []Check   `json:"checks" yaml:"checks"`
+}
+
+// Doctor 诊断配置
+type Doctor struct {
+	Target string // 用于 hosts 和 DNS 检查的域名 (可选)
+
+	ResolvConfPath string
+	HostsPath      string
+
+	// 外部依赖，默认使用真实实现，测试时可替换
+	Routes    func() ([]route.Route, error)
+	Egress    func(probe string) string
+	Getenv    func(key string) string
+	QueryDNS  func(ctx context.Context, server, name string) error
+	FetchIPv4 func(ctx context.Context) (string, error)
+	FetchIPv6 func(ctx context.Context) (string, error)
+}
+
+// New 创建使用真实系统和网络的 Doctor
+func New(target string) *Doctor {
+	return &Doctor{
+		Target:         target,
+		ResolvConfPath: "/etc/resolv.conf",
+		HostsPath:      "/etc/hosts",
+		Routes:         route.Table,
+		Egress:         local.EgressIP,
+		Getenv:         os.Getenv,
+		QueryDNS:       queryDNS,
+		FetchIPv4:      network.FetchPublicIPv4,
+		FetchIPv6:      network.FetchPublicIPv6,
+	}
+}
+
+// Run 执行全部检查
+//
+// 本地检查按顺序执行；DNS 和公网 IP 检查涉及网络，并发执行
+func (d *Doctor) Run(ctx context.Context) *Report {
+	rc, rcErr := parseResolvConf(d.ResolvConfPath)
+	routes, routesErr := d.Routes()
+
+	checks := []Check{
+		d.checkResolvConf(rc, rcErr),
+		d.checkHosts(),
+		d.checkRoute("route_ipv4", "IPv4 default route", "ipv4", routes, routesErr),
+		d.checkRoute("route_ipv6", "IPv6 default route", "ipv6", routes, routesErr),
+		d.checkProxy(),
+	}
+
+	slow := []func() Check{
+		func() Check { return d.checkDNS(ctx, rc) },
+		func() Check { return d.checkPublicIPv4(ctx) },
+		func() Check { return d.checkPublicIPv6(ctx) },
+	}
+	results := make([]Check, len(slow))
+	var wg sync.WaitGroup
+	for i, fn := range slow {
+		wg.Add(1)
+		go func(i int, fn func() Check) {
+			defer wg.Done()
+			results[i] = fn()
+		}(i, fn)
+	}
+	wg.Wait()
+	checks = append(checks, results...)
+
+	hostname, _ := os.Hostname()
+	report := &Report{
+		Hostname: hostname,
+		Time:     time.Now(),
+		Target:   d.Target,
+		Status:   StatusPass,
+		Checks:   checks,
+	}
+	for _, c := range checks {
+		switch c.Status {
+		case StatusPass:
+			report.Summary.Pass++
+		case StatusWarn:
+			report.Summary.Warn++
+		case StatusFail:
+			report.Summary.Fail++
+		case StatusSkip:
+			report.Summary.Skip++
+		}
+	}
+	switch {
+	case report.Summary.Fail > 0:
+		report.Status = StatusFail
+	case report.Summary.Warn > 0:
+		report.Status = StatusWarn
+	}
+	return report
+}
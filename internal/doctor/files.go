@@ -0,0 +1,83 @@
+/*
+系统配置文件解析
+
+resolv.conf (resolv.conf(5)):
+
+	nameserver 10.0.0.53
+	search corp.example.com example.com
+	options ndots:2 timeout:1
+
+hosts (hosts(5)):
+
+	192.168.1.10  nas nas.home.arpa
+*/
+package doctor
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// resolvConf resolv.conf 中与诊断相关的内容
+type resolvConf struct {
+	Nameservers []string
+	Search      []string
+	Options     []string
+}
+
+// parseResolvConf 解析 resolv.conf
+//
+// "domain" 与 "search" 同样处理；以 # 或 ; 开头的是注释
+func parseResolvConf(path string) (*resolvConf, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rc := &resolvConf{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			rc.Nameservers = append(rc.Nameservers, fields[1])
+		case "search", "domain":
+			rc.Search = fields[1:] // 后出现的覆盖前面的
+		case "options":
+			rc.Options = append(rc.Options, fields[1:]...)
+		}
+	}
+	return rc, scanner.Err()
+}
+
+// hostsLookup 返回 hosts 文件中 name 对应的地址
+func hostsLookup(path, name string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	var addrs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		for _, host := range fields[1:] {
+			if strings.ToLower(host) == name {
+				addrs = append(addrs, fields[0])
+				break
+			}
+		}
+	}
+	return addrs, scanner.Err()
+}
@@ -0,0 +1,185 @@
+/*
+Package gateway 向家用路由器查询它的外部 (WAN) 地址
+
+依赖: internal/ip, internal/network, internal/route
+
+三种协议并发查询，任一成功即可:
+- UPnP IGD: SSDP 发现设备，再调用 WANIPConnection 的 GetExternalIPAddress (upnp.go)
+- NAT-PMP (RFC 6886) 与 PCP (RFC 6887): 直接向默认网关的 5351 端口发 UDP 请求 (natpmp.go)
+
+比较路由器的 WAN 地址与公网 IP:
+- WAN 地址位于 100.64.0.0/10 (RFC 6598 共享地址): 路由器之外还有一层运营商级 NAT (CGNAT)
+- WAN 地址是私有地址: 上游还有一台路由器 (双重 NAT)
+- WAN 地址是公网地址但与公网 IP 不同: 流量没有从这台路由器出去 (VPN 或代理)
+
+前两种情况下，在路由器上做端口转发都无法从外部访问。
+
+所有外部依赖都是可替换的字段，测试时可以用本地替身代替真实网络。
+*/
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github/shawn/ip-tool/internal/ip"
+	"github/shawn/ip-tool/internal/network"
+	"github/shawn/ip-tool/internal/route"
+)
+
+// 协议名称
+const (
+	ProtocolUPnP   = "upnp"
+	ProtocolNATPMP = "nat-pmp"
+	ProtocolPCP    = "pcp"
+)
+
+// defaultTimeout 每种协议的等待时间
+const defaultTimeout = 3 * time.Second
+
+// cgnatBlock RFC 6598 运营商级 NAT 共享地址段
+var cgnatBlock = &net.IPNet{IP: net.IPv4(100, 64, 0, 0).To4(), Mask: net.CIDRMask(10, 32)}
+
+// Answer 一种协议的查询结果
+type Answer struct {
+	Protocol string `json:"protocol" yaml:"protocol"`
+	Address  string `json:"address,omitempty" yaml:"address,omitempty"`
+	Device   string `json:"device,omitempty" yaml:"device,omitempty"` // UPnP 设备名称
+	Error    string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Report 查询与比较结果
+type Report struct {
+	Gateway string `json:"gateway,omitempty" yaml:"gateway,omitempty"` // 默认网关
+	WAN     string `json:"wan,omitempty" yaml:"wan,omitempty"`         // 路由器报告的外部地址
+	WANType string `json:"wan_type,omitempty" yaml:"wan_type,omitempty"`
+	Public  string `json:"public,omitempty" yaml:"public,omitempty"` // 公网 IP 检测结果
+	CGNAT   bool   `json:"cgnat" yaml:"cgnat"`                       // WAN 地址位于 100.64.0.0/10
+	// DoubleNAT WAN 地址是私有地址，上游还有一台路由器
+	DoubleNAT bool `json:"double_nat" yaml:"double_nat"`
+	// EgressDiffers WAN 地址是公网地址，但流量从其他地址出去 (VPN 或代理)
+	EgressDiffers bool     `json:"egress_differs" yaml:"egress_differs"`
+	Verdict       string   `json:"verdict" yaml:"verdict"`
+	Answers       []Answer `json:"answers" yaml:"answers"`
+}
+
+// Prober 查询配置
+type Prober struct {
+	SSDPAddr   string        // SSDP 组播地址
+	NATPMPPort int           // NAT-PMP/PCP 服务端口
+	Timeout    time.Duration // 每种协议的等待时间
+
+	// 外部依赖，默认使用真实实现，测试时可替换
+	Gateway   func() (net.IP, error)
+	FetchIPv4 func(ctx context.Context) (string, error)
+}
+
+// New 创建使用真实系统和网络的 Prober
+func New() *Prober {
+	return &Prober{
+		SSDPAddr:   "239.255.255.250:1900",
+		NATPMPPort: 5351,
+		Timeout:    defaultTimeout,
+		Gateway:    defaultGateway,
+		FetchIPv4:  network.FetchPublicIPv4,
+	}
+}
+
+// Run 并发查询三种协议和公网 IP，然后比较
+func (p *Prober) Run(ctx context.Context) *Report {
+	report := &Report{}
+
+	gw, gwErr := p.Gateway()
+	if gwErr == nil {
+		report.Gateway = gw.String()
+	}
+
+	answers := make([]Answer, 3)
+	var public string
+	var wg sync.WaitGroup
+	run := func(fn func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn()
+		}()
+	}
+
+	run(func() { answers[0] = p.queryUPnP(ctx, gw) })
+	run(func() { answers[1] = p.queryGateway(ctx, ProtocolNATPMP, gw, gwErr, p.natPMP) })
+	run(func() { answers[2] = p.queryGateway(ctx, ProtocolPCP, gw, gwErr, p.pcp) })
+	run(func() { public, _ = p.FetchIPv4(ctx) })
+	wg.Wait()
+
+	report.Answers = answers
+	report.Public = public
+	for _, a := range answers {
+		if a.Address != "" {
+			report.WAN = a.Address
+			break
+		}
+	}
+	report.analyze()
+	return report
+}
+
+// queryGateway 执行需要默认网关的查询
+func (p *Prober) queryGateway(ctx context.Context, protocol string, gw net.IP, gwErr error, fn func(context.Context, net.IP) (net.IP, error)) Answer {
+	a := Answer{Protocol: protocol}
+	if gwErr != nil {
+		a.Error = gwErr.Error()
+		return a
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+	addr, err := fn(ctx, gw)
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	a.Address = addr.String()
+	return a
+}
+
+// analyze 比较 WAN 地址与公网 IP，给出结论
+func (r *Report) analyze() {
+	if r.WAN == "" {
+		r.Verdict = "the router did not answer UPnP, NAT-PMP or PCP"
+		return
+	}
+	r.WANType = string(ip.Classify(r.WAN))
+
+	wan := net.ParseIP(r.WAN)
+	switch {
+	case cgnatBlock.Contains(wan):
+		r.CGNAT = true
+		r.Verdict = "behind carrier-grade NAT: the router's WAN address is in 100.64.0.0/10"
+	case wan.IsPrivate(): // RFC 1918
+		r.DoubleNAT = true
+		r.Verdict = "behind another router (double NAT): the router's WAN address is private"
+	case r.Public == "":
+		r.Verdict = "public IP unknown, cannot compare with the router's WAN address"
+	case r.Public != r.WAN:
+		r.EgressDiffers = true
+		r.Verdict = "egress differs from the router's WAN address " + r.WAN + " (VPN or proxy?)"
+	default:
+		r.Verdict = "the router holds the public IP, port forwarding on it can work"
+	}
+}
+
+// defaultGateway IPv4 默认路由的网关
+func defaultGateway() (net.IP, error) {
+	routes, err := route.Table()
+	if err != nil {
+		return nil, err
+	}
+	r := route.Lookup(routes, net.IPv4(1, 1, 1, 1))
+	if r == nil || r.Gateway == "" {
+		return nil, errors.New("no IPv4 default gateway")
+	}
+	return net.ParseIP(r.Gateway), nil
+}
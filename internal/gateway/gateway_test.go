@@ -0,0 +1,344 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 本地替身: 带设备描述和 SOAP 控制接口的 IGD、SSDP 应答器、NAT-PMP/PCP 网关
+
+const wanIPService = "urn:schemas-upnp-org:service:WANIPConnection:1"
+
+// igdDescription 典型的设备描述: WAN 服务位于嵌套的子设备中，controlURL 为相对路径
+const igdDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <friendlyName>Test Router</friendlyName>
+    <serviceList>
+      <service><serviceType>urn:schemas-upnp-org:service:Layer3Forwarding:1</serviceType><controlURL>/l3f</controlURL></service>
+    </serviceList>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service><serviceType>` + wanIPService + `</serviceType><controlURL>ctl/ipconn</controlURL></service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`
+
+// igdServer 启动 IGD 替身，返回设备描述的 URL
+//
+// GetExternalIPAddress 返回 wan；fault 不为空时返回 SOAP Fault
+func igdServer(t *testing.T, wan, fault string) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /igd/desc.xml", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, igdDescription)
+	})
+	mux.HandleFunc("POST /igd/ctl/ipconn", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got := r.Header.Get("SOAPAction"); got != `"`+wanIPService+`#GetExternalIPAddress"` {
+			http.Error(w, "bad SOAPAction "+got, http.StatusBadRequest)
+			return
+		}
+		if !bytes.Contains(body, []byte("GetExternalIPAddress")) {
+			http.Error(w, "bad envelope", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+		if fault != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>`+
+				`<s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail>`+
+				`<UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>501</errorCode>`+
+				`<errorDescription>%s</errorDescription></UPnPError></detail></s:Fault></s:Body></s:Envelope>`, fault)
+			return
+		}
+		fmt.Fprintf(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>`+
+			`<u:GetExternalIPAddressResponse xmlns:u="%s"><NewExternalIPAddress>%s</NewExternalIPAddress>`+
+			`</u:GetExternalIPAddressResponse></s:Body></s:Envelope>`, wanIPService, wan)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv.URL + "/igd/desc.xml"
+}
+
+// ssdpServer 启动 SSDP 应答器，对每个 M-SEARCH 回复 location，返回 "127.0.0.1:port"
+func ssdpServer(t *testing.T, location string) string {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if !bytes.HasPrefix(buf[:n], []byte("M-SEARCH * HTTP/1.1\r\n")) {
+				continue
+			}
+			conn.WriteToUDP([]byte("HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=120\r\n"+
+				"ST: "+wanIPService+"\r\nLOCATION: "+location+"\r\n\r\n"), from)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+// pmpServer NAT-PMP/PCP 网关替身
+type pmpServer struct {
+	external   net.IP // 报告的外部地址
+	resultCode uint16 // NAT-PMP 结果码
+	natpmpOnly bool   // 不支持 PCP: 以版本 0 回复 "unsupported version"
+}
+
+// start 在 127.0.0.1 上监听，返回端口
+func (s *pmpServer) start(t *testing.T) int {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1100)
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if resp := s.reply(buf[:n]); resp != nil {
+				conn.WriteToUDP(resp, from)
+			}
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// reply 按版本分别应答 NAT-PMP 外部地址请求和 PCP MAP 请求
+func (s *pmpServer) reply(req []byte) []byte {
+	switch {
+	case len(req) == 2 && req[0] == natpmpVersion && req[1] == natpmpOpAddress:
+		resp := []byte{natpmpVersion, 128 + natpmpOpAddress, 0, 0}
+		binary.BigEndian.PutUint16(resp[2:4], s.resultCode)
+		resp = binary.BigEndian.AppendUint32(resp, 3600) // 启动时间
+		return append(resp, s.external.To4()...)
+
+	case len(req) == 60 && req[0] == pcpVersion && req[1] == pcpOpMap:
+		if s.natpmpOnly {
+			return []byte{natpmpVersion, 128 + pcpOpMap, 0, 1} // unsupported version
+		}
+		resp := make([]byte, 60)
+		resp[0] = pcpVersion
+		resp[1] = pcpResponse | pcpOpMap
+		copy(resp[4:8], req[4:8]) // lifetime
+		binary.BigEndian.PutUint32(resp[8:12], 3600)
+		copy(resp[24:44], req[24:44]) // nonce、协议、内部端口
+		binary.BigEndian.PutUint16(resp[42:44], 40000)
+		copy(resp[44:60], s.external.To16())
+		return resp
+	}
+	return nil
+}
+
+// prober 使用本地替身的 Prober，ssdp 为空时 UPnP 没有应答
+func prober(ssdp string, pmpPort int, public string) *Prober {
+	if ssdp == "" {
+		ssdp = "127.0.0.1:9" // discard: 没有应答
+	}
+	return &Prober{
+		SSDPAddr:   ssdp,
+		NATPMPPort: pmpPort,
+		Timeout:    time.Second,
+		Gateway:    func() (net.IP, error) { return net.IPv4(127, 0, 0, 1), nil },
+		FetchIPv4: func(context.Context) (string, error) {
+			if public == "" {
+				return "", errors.New("offline")
+			}
+			return public, nil
+		},
+	}
+}
+
+func TestExternalAddress(t *testing.T) {
+	name, addr, err := externalAddress(context.Background(), igdServer(t, "100.64.3.4", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "Test Router" || addr != "100.64.3.4" {
+		t.Errorf("got %q %q, want \"Test Router\" \"100.64.3.4\"", name, addr)
+	}
+}
+
+func TestExternalAddressErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		location func(t *testing.T) string
+		want     string
+	}{
+		{"SOAP fault", func(t *testing.T) string {
+			return igdServer(t, "", "ActionNotAuthorized")
+		}, "ActionNotAuthorized (500)"},
+		{"invalid address", func(t *testing.T) string {
+			return igdServer(t, "", "")
+		}, "invalid address"},
+		{"no WAN service", func(t *testing.T) string {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, `<root><device><friendlyName>Printer</friendlyName></device></root>`)
+			}))
+			t.Cleanup(srv.Close)
+			return srv.URL
+		}, "no WAN connection service"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := externalAddress(context.Background(), tt.location(t))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// 发往路由器的请求不能交给 HTTP_PROXY 中的代理
+func TestUPnPClientIgnoresProxy(t *testing.T) {
+	tr, ok := upnpClient.Transport.(*http.Transport)
+	if !ok || tr.Proxy != nil {
+		t.Error("upnpClient must not use a proxy")
+	}
+	if upnpClient.Timeout <= 0 {
+		t.Error("upnpClient has no timeout")
+	}
+}
+
+func TestNATPMP(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  pmpServer
+		want    string
+		wantErr string
+	}{
+		{"address", pmpServer{external: net.IPv4(203, 0, 113, 9)}, "203.0.113.9", ""},
+		{"refused", pmpServer{external: net.IPv4zero, resultCode: 2}, "", "not authorized"},
+		{"unknown result code", pmpServer{external: net.IPv4zero, resultCode: 99}, "", "result code 99"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := prober("", tt.server.start(t), "")
+			ip, err := p.natPMP(context.Background(), net.IPv4(127, 0, 0, 1))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ip.String() != tt.want {
+				t.Errorf("address = %s, want %s", ip, tt.want)
+			}
+		})
+	}
+}
+
+func TestPCP(t *testing.T) {
+	s := &pmpServer{external: net.IPv4(203, 0, 113, 9)}
+	p := prober("", s.start(t), "")
+	ip, err := p.pcp(context.Background(), net.IPv4(127, 0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ip.Equal(s.external) {
+		t.Errorf("address = %s, want %s", ip, s.external)
+	}
+
+	s = &pmpServer{external: net.IPv4(203, 0, 113, 9), natpmpOnly: true}
+	p = prober("", s.start(t), "")
+	if _, err := p.pcp(context.Background(), net.IPv4(127, 0, 0, 1)); err == nil || !strings.Contains(err.Error(), "only supports NAT-PMP") {
+		t.Errorf("NAT-PMP only gateway: err = %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		upnp    string // UPnP 报告的地址，空表示没有 IGD
+		pmp     *pmpServer
+		public  string
+		wan     string
+		cgnat   bool
+		double  bool
+		egress  bool
+		verdict string
+	}{
+		{"router holds public IP", "203.0.113.9", nil, "203.0.113.9",
+			"203.0.113.9", false, false, false, "router holds the public IP"},
+		{"CGNAT", "100.64.3.4", nil, "203.0.113.9",
+			"100.64.3.4", true, false, false, "carrier-grade NAT"},
+		{"double NAT", "", &pmpServer{external: net.IPv4(192, 168, 0, 2)}, "203.0.113.9",
+			"192.168.0.2", false, true, false, "double NAT"},
+		{"egress differs", "", &pmpServer{external: net.IPv4(203, 0, 113, 9)}, "198.18.0.1",
+			"203.0.113.9", false, false, true, "VPN or proxy"},
+		{"public IP unknown", "203.0.113.9", nil, "",
+			"203.0.113.9", false, false, false, "public IP unknown"},
+		{"no answer", "", nil, "203.0.113.9",
+			"", false, false, false, "did not answer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ssdp string
+			if tt.upnp != "" {
+				ssdp = ssdpServer(t, igdServer(t, tt.upnp, ""))
+			}
+			// 没有 NAT-PMP 替身时用一个已关闭的端口
+			port := 9
+			if tt.pmp != nil {
+				port = tt.pmp.start(t)
+			}
+			r := prober(ssdp, port, tt.public).Run(context.Background())
+
+			if r.WAN != tt.wan {
+				t.Errorf("WAN = %q, want %q (answers %+v)", r.WAN, tt.wan, r.Answers)
+			}
+			if r.CGNAT != tt.cgnat || r.DoubleNAT != tt.double || r.EgressDiffers != tt.egress {
+				t.Errorf("cgnat=%v double_nat=%v egress_differs=%v, want %v %v %v",
+					r.CGNAT, r.DoubleNAT, r.EgressDiffers, tt.cgnat, tt.double, tt.egress)
+			}
+			if !strings.Contains(r.Verdict, tt.verdict) {
+				t.Errorf("verdict = %q, want it to mention %q", r.Verdict, tt.verdict)
+			}
+		})
+	}
+}
+
+func TestRunUPnPDevice(t *testing.T) {
+	ssdp := ssdpServer(t, igdServer(t, "203.0.113.9", ""))
+	r := prober(ssdp, 9, "203.0.113.9").Run(context.Background())
+	if a := r.Answers[0]; a.Protocol != ProtocolUPnP || a.Address != "203.0.113.9" || a.Device != "Test Router" {
+		t.Errorf("UPnP answer = %+v", a)
+	}
+}
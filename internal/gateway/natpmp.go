@@ -0,0 +1,205 @@
+/*
+NAT-PMP (RFC 6886) 与 PCP (RFC 6887)
+
+两者都向默认网关的 UDP 5351 端口发请求。
+
+NAT-PMP 有专门的外部地址请求:
+
+	请求: | 版本 0 | 操作码 0 |
+	响应: | 版本 0 | 操作码 128 | 结果码 (16) | 启动时间 (32) | 外部 IPv4 (32) |
+
+PCP 没有单独查询地址的操作，只能通过 MAP 请求得到分配的外部地址。
+这里为自己的临时套接字申请一个短期映射，拿到地址后立即删除 (lifetime 0)。
+
+	MAP 请求: 24 字节公共头 (版本 2、操作码 1、lifetime、客户端地址)
+	          + 36 字节 MAP 数据 (nonce、协议、内部端口、建议的外部端口与地址)
+	MAP 响应: 24 字节公共头 (操作码 0x81、结果码、lifetime、启动时间)
+	          + 36 字节 MAP 数据 (分配的外部端口与地址位于最后 18 字节)
+*/
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// 协议常量
+const (
+	natpmpVersion   = 0
+	natpmpOpAddress = 0
+
+	pcpVersion  = 2
+	pcpOpMap    = 1
+	pcpResponse = 0x80
+	pcpLifetime = 30 // 临时映射的秒数，删除失败时也会很快过期
+)
+
+// RFC 6886 第 3.5 节 结果码
+var natpmpResults = map[uint16]string{
+	1: "unsupported version",
+	2: "not authorized / refused",
+	3: "network failure",
+	4: "out of resources",
+	5: "unsupported opcode",
+}
+
+// RFC 6887 第 7.4 节 结果码
+var pcpResults = map[byte]string{
+	1:  "UNSUPP_VERSION",
+	2:  "NOT_AUTHORIZED",
+	3:  "MALFORMED_REQUEST",
+	4:  "UNSUPP_OPCODE",
+	5:  "UNSUPP_OPTION",
+	6:  "MALFORMED_OPTION",
+	7:  "NETWORK_FAILURE",
+	8:  "NO_RESOURCES",
+	9:  "UNSUPP_PROTOCOL",
+	10: "USER_EX_QUOTA",
+	11: "CANNOT_PROVIDE_EXTERNAL",
+	12: "ADDRESS_MISMATCH",
+	13: "EXCESSIVE_REMOTE_PEERS",
+}
+
+// natPMP 通过 NAT-PMP 查询外部地址
+func (p *Prober) natPMP(ctx context.Context, gw net.IP) (net.IP, error) {
+	conn, err := p.dialGateway(gw)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	resp, err := exchange(ctx, conn, []byte{natpmpVersion, natpmpOpAddress}, func(b []byte) bool {
+		return len(b) >= 12 && b[0] == natpmpVersion && b[1] == 128+natpmpOpAddress
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if code := binary.BigEndian.Uint16(resp[2:4]); code != 0 {
+		return nil, resultError(natpmpResults[code], int(code))
+	}
+	return net.IP(resp[8:12]), nil
+}
+
+// pcp 通过 PCP MAP 请求查询外部地址
+func (p *Prober) pcp(ctx context.Context, gw net.IP) (net.IP, error) {
+	conn, err := p.dialGateway(gw)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	local := conn.LocalAddr().(*net.UDPAddr)
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	match := func(b []byte) bool {
+		return len(b) >= 60 && b[0] == pcpVersion && b[1] == pcpResponse|pcpOpMap && bytes.Equal(b[24:36], nonce)
+	}
+	resp, err := exchange(ctx, conn, pcpMapRequest(local, nonce, pcpLifetime), func(b []byte) bool {
+		// 只支持 NAT-PMP 的网关以版本 0 回复 "unsupported version"
+		return match(b) || (len(b) >= 4 && b[0] == natpmpVersion)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp[0] != pcpVersion {
+		return nil, errors.New("gateway only supports NAT-PMP")
+	}
+	if code := resp[3]; code != 0 {
+		return nil, resultError(pcpResults[code], int(code))
+	}
+	external := net.IP(append([]byte(nil), resp[44:60]...))
+
+	// 删除临时映射，失败也无妨: 30 秒后自动过期
+	delCtx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	exchange(delCtx, conn, pcpMapRequest(local, nonce, 0), match)
+
+	return external, nil
+}
+
+// pcpMapRequest 生成 UDP 的 MAP 请求，内部端口为本地套接字的端口
+func pcpMapRequest(local *net.UDPAddr, nonce []byte, lifetime uint32) []byte {
+	req := make([]byte, 60)
+	req[0] = pcpVersion
+	req[1] = pcpOpMap
+	binary.BigEndian.PutUint32(req[4:8], lifetime)
+	copy(req[8:24], local.IP.To16())
+
+	copy(req[24:36], nonce)
+	req[36] = 17 // UDP
+	binary.BigEndian.PutUint16(req[40:42], uint16(local.Port))
+	// 建议的外部端口为 0，建议的外部地址为 ::ffff:0.0.0.0 (任意 IPv4)
+	copy(req[44:60], net.IPv4zero.To16())
+	return req
+}
+
+// dialGateway 连接网关的 NAT-PMP/PCP 端口
+func (p *Prober) dialGateway(gw net.IP) (*net.UDPConn, error) {
+	if gw.To4() == nil {
+		return nil, errors.New("no IPv4 gateway")
+	}
+	return net.DialUDP("udp4", nil, &net.UDPAddr{IP: gw, Port: p.NATPMPPort})
+}
+
+// exchange 发送请求，按 RFC 6886 从 250ms 开始翻倍重传，直到 match 的响应或 ctx 结束
+func exchange(ctx context.Context, conn *net.UDPConn, req []byte, match func([]byte) bool) ([]byte, error) {
+	stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	buf := make([]byte, 1100) // PCP 消息最大 1100 字节
+	rto := 250 * time.Millisecond
+	for {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+
+		deadline := time.Now().Add(rto)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		conn.SetReadDeadline(deadline)
+
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				var ne net.Error
+				if errors.As(err, &ne) && ne.Timeout() {
+					break // 重传
+				}
+				// 网关没有监听时会收到 ICMP port unreachable
+				if errors.Is(err, syscall.ECONNREFUSED) {
+					return nil, errors.New("not supported by the gateway (port closed)")
+				}
+				return nil, err
+			}
+			if match(buf[:n]) {
+				return buf[:n], nil
+			}
+		}
+
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("timeout")
+		}
+		rto *= 2
+	}
+}
+
+// resultError 非零结果码
+func resultError(name string, code int) error {
+	if name == "" {
+		name = "result code " + strconv.Itoa(code)
+	}
+	return errors.New("gateway refused: " + name)
+}
@@ -0,0 +1,288 @@
+/*
+UPnP Internet Gateway Device
+
+1. SSDP: 向 239.255.255.250:1900 组播 M-SEARCH，路由器回复 LOCATION (设备描述 URL)
+2. 下载设备描述 XML，找到 WANIPConnection 或 WANPPPConnection 服务的 controlURL
+3. 向 controlURL 发 SOAP 请求 GetExternalIPAddress，响应中 NewExternalIPAddress 即 WAN 地址
+*/
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// searchTargets M-SEARCH 的 ST 值
+//
+// 有些路由器只回应服务类型，有些只回应设备类型
+var searchTargets = []string{
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:1",
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:2",
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANIPConnection:2",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+// wanServices 提供 GetExternalIPAddress 的服务类型前缀
+var wanServices = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:",
+	"urn:schemas-upnp-org:service:WANPPPConnection:",
+}
+
+// queryUPnP 发现 IGD 并查询外部地址
+//
+// 有多个 IGD 时优先选择默认网关上的
+func (p *Prober) queryUPnP(ctx context.Context, gw net.IP) Answer {
+	a := Answer{Protocol: ProtocolUPnP}
+
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	locations, err := p.discover(ctx)
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	if len(locations) == 0 {
+		a.Error = "no UPnP gateway found"
+		return a
+	}
+	preferGateway(locations, gw)
+
+	var errs []string
+	for _, loc := range locations {
+		name, addr, err := externalAddress(ctx, loc)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		a.Device, a.Address = name, addr
+		return a
+	}
+	a.Error = strings.Join(errs, "; ")
+	return a
+}
+
+// discover 发送 M-SEARCH 并收集 LOCATION，直到 ctx 结束
+func (p *Prober) discover(ctx context.Context) ([]string, error) {
+	dest, err := net.ResolveUDPAddr("udp4", p.SSDPAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	for _, st := range searchTargets {
+		msg := "M-SEARCH * HTTP/1.1\r\n" +
+			"HOST: 239.255.255.250:1900\r\n" +
+			"MAN: \"ssdp:discover\"\r\n" +
+			"MX: 2\r\n" +
+			"ST: " + st + "\r\n\r\n"
+		if _, err := conn.WriteToUDP([]byte(msg), dest); err != nil {
+			return nil, err
+		}
+	}
+
+	// 留一半时间给设备描述和 SOAP 请求
+	deadline, _ := ctx.Deadline()
+	conn.SetReadDeadline(time.Now().Add(time.Until(deadline) / 2))
+
+	var locations []string
+	seen := make(map[string]bool)
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			break // 超时: 收集结束
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		loc := resp.Header.Get("Location")
+		if loc != "" && !seen[loc] {
+			seen[loc] = true
+			locations = append(locations, loc)
+		}
+	}
+	return locations, nil
+}
+
+// preferGateway 把位于默认网关上的设备排到前面
+func preferGateway(locations []string, gw net.IP) {
+	if gw == nil {
+		return
+	}
+	for i, loc := range locations {
+		u, err := url.Parse(loc)
+		if err == nil && net.ParseIP(u.Hostname()).Equal(gw) {
+			locations[0], locations[i] = locations[i], locations[0]
+			return
+		}
+	}
+}
+
+// upnpRoot 设备描述 XML
+type upnpRoot struct {
+	URLBase string     `xml:"URLBase"`
+	Device  upnpDevice `xml:"device"`
+}
+
+type upnpDevice struct {
+	FriendlyName string        `xml:"friendlyName"`
+	Services     []upnpService `xml:"serviceList>service"`
+	Devices      []upnpDevice  `xml:"deviceList>device"`
+}
+
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+// findWAN 在设备树中查找 WAN 连接服务
+func (d upnpDevice) findWAN() (upnpService, bool) {
+	for _, s := range d.Services {
+		for _, prefix := range wanServices {
+			if strings.HasPrefix(s.ServiceType, prefix) {
+				return s, true
+			}
+		}
+	}
+	for _, child := range d.Devices {
+		if s, ok := child.findWAN(); ok {
+			return s, true
+		}
+	}
+	return upnpService{}, false
+}
+
+// externalAddress 读取设备描述并调用 GetExternalIPAddress
+func externalAddress(ctx context.Context, location string) (name, addr string, err error) {
+	body, err := httpDo(ctx, "GET", location, nil, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("device description: %w", err)
+	}
+	var root upnpRoot
+	if err := xml.Unmarshal(body, &root); err != nil {
+		return "", "", fmt.Errorf("device description: %w", err)
+	}
+
+	svc, ok := root.Device.findWAN()
+	if !ok {
+		return "", "", fmt.Errorf("%s has no WAN connection service", location)
+	}
+
+	base := location
+	if root.URLBase != "" {
+		base = root.URLBase
+	}
+	control, err := resolveURL(base, svc.ControlURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	envelope := `<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:GetExternalIPAddress xmlns:u="` + svc.ServiceType + `"/></s:Body></s:Envelope>`
+	headers := map[string]string{
+		"Content-Type": `text/xml; charset="utf-8"`,
+		"SOAPAction":   `"` + svc.ServiceType + `#GetExternalIPAddress"`,
+	}
+	body, err = httpDo(ctx, "POST", control, []byte(envelope), headers)
+	if err != nil {
+		return "", "", fmt.Errorf("GetExternalIPAddress: %w", err)
+	}
+
+	addr = xmlElement(body, "NewExternalIPAddress")
+	if net.ParseIP(addr) == nil {
+		return "", "", fmt.Errorf("GetExternalIPAddress: invalid address %q", addr)
+	}
+	return root.Device.FriendlyName, addr, nil
+}
+
+// resolveURL 相对 URL 转为绝对 URL
+func resolveURL(base, ref string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return b.ResolveReference(r).String(), nil
+}
+
+// upnpClient 访问局域网内的路由器
+//
+// 不使用 http.DefaultClient: 它读取 HTTP_PROXY 等环境变量，
+// 会把发往路由器的请求交给代理，而代理无法访问用户的局域网
+var upnpClient = &http.Client{
+	Timeout:   defaultTimeout,
+	Transport: &http.Transport{Proxy: nil},
+}
+
+// httpDo 发送请求并读取响应体 (限制 1 MiB)
+func httpDo(ctx context.Context, method, target string, body []byte, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := upnpClient.Do(req)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("timeout")
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// UPnP 错误以 SOAP Fault 返回，errorDescription 说明原因
+		if desc := xmlElement(data, "errorDescription"); desc != "" {
+			return nil, fmt.Errorf("%s (%d)", desc, resp.StatusCode)
+		}
+		return nil, fmt.Errorf("device returned %d", resp.StatusCode)
+	}
+	return data, nil
+}
+
+// xmlElement 返回第一个本地名为 name 的元素的文本 (忽略命名空间)
+func xmlElement(data []byte, name string) string {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == name {
+			var text string
+			if err := dec.DecodeElement(&text, &start); err != nil {
+				return ""
+			}
+			return strings.TrimSpace(text)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+/*
+历史记录过滤
+
+支持的条件 (全部可选，同时指定时取交集):
+- 时间范围: Since / Until
+- 国家: 不区分大小写的完全匹配
+- IP 类型: 不区分大小写的完全匹配 (Public、Private ...)
+- 关键字: 在目标、原始输入、IP、国家、城市、ISP 中做子串匹配
+*/
+package history
+
+import (
+	"strings"
+	"time"
+)
+
+// Filter 过滤条件
+type Filter struct {
+	Since   time.Time
+	Until   time.Time
+	Country string
+	Type    string
+	Query   string
+}
+
+// Match 检查记录是否满足条件
+func (f Filter) Match(e Entry) bool {
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	if f.Country != "" && !strings.EqualFold(e.Country, f.Country) {
+		return false
+	}
+	if f.Type != "" && !strings.EqualFold(e.Type, f.Type) {
+		return false
+	}
+	if f.Query != "" {
+		query := strings.ToLower(f.Query)
+		fields := []string{e.Target, e.Input, e.IPv4, e.IPv6, e.Country, e.City, e.ISP}
+		for _, field := range fields {
+			if strings.Contains(strings.ToLower(field), query) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Apply 返回满足条件的记录，保持原有顺序
+func (f Filter) Apply(entries []Entry) []Entry {
+	var matched []Entry
+	for _, e := range entries {
+		if f.Match(e) {
+			matched = append(matched, e)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,400 @@
+/*
+Package history 提供查询历史的持久化存储
+
+这是底层包，不依赖任何其他 internal 包。
+
+CLI Guidelines 原则 - Configuration:
+- 遵循 XDG 基础目录规范，历史属于 "状态" 而非配置或缓存
+- 允许用户通过配置关闭 (history: false)
+- 有保留上限，避免文件无限增长
+
+存储位置:
+1. $XDG_STATE_HOME/ipq/history.jsonl
+2. ~/.local/state/ipq/history.jsonl (XDG 默认值)
+
+存储格式: JSON Lines，每行一条记录，只追加写入。
+超过保留上限一定比例后整体重写，只保留最新的记录。
+
+多个 ipq 进程可能同时写入 (如两个终端各跑一个批量查询)。
+写入时持有 history.jsonl.lock 上的文件锁，并在锁内从文件末尾重新读取最大 ID，
+所以 ID 在进程之间也不会重复。
+*/
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLimit 默认保留的记录数
+const DefaultLimit = 1000
+
+// 输入来源
+const (
+	SourceArg       = "arg"       // 命令行参数
+	SourceClipboard = "clipboard" // 剪贴板
+	SourceStdin     = "stdin"     // 标准输入
+	SourceFile      = "file"      // 批量文件
+	SourceBatch     = "batch"     // 批量列表视图
+	SourceTUI       = "tui"       // TUI 输入框
+	SourceHistory   = "history"   // ipq history rerun
+	SourceDefault   = "default"   // 无输入，查询本机
+)
+
+// ErrNotFound 指定 ID 的记录不存在
+var ErrNotFound = errors.New("history entry not found")
+
+// Entry 一条查询记录
+type Entry struct {
+	ID      int64     `json:"id" yaml:"id"`
+	Time    time.Time `json:"time" yaml:"time"`
+	Target  string    `json:"target" yaml:"target"`
+	Input   string    `json:"input,omitempty" yaml:"input,omitempty"` // 原始输入 (如粘贴的 URL)
+	Source  string    `json:"source" yaml:"source"`
+	IPv4    string    `json:"ipv4,omitempty" yaml:"ipv4,omitempty"`
+	IPv6    string    `json:"ipv6,omitempty" yaml:"ipv6,omitempty"`
+	Type    string    `json:"type,omitempty" yaml:"type,omitempty"`
+	Country string    `json:"country,omitempty" yaml:"country,omitempty"`
+	City    string    `json:"city,omitempty" yaml:"city,omitempty"`
+	ISP     string    `json:"isp,omitempty" yaml:"isp,omitempty"`
+}
+
+// Store 历史存储
+//
+// 并发安全: TUI 列表视图会同时记录多条结果
+type Store struct {
+	path     string
+	limit    int
+	disabled bool
+
+	mu     sync.Mutex
+	loaded bool  // count 是否已从文件读取
+	count  int   // 文件中的记录数 (其他进程的写入不计，只用于决定何时裁剪)
+	lastID int64 // 本进程分配过的最大 ID (清空后文件中已没有)
+}
+
+// New 创建历史存储
+func New(path string, limit int) *Store {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	return &Store{path: path, limit: limit}
+}
+
+var defaultStore = New(DefaultPath(), DefaultLimit)
+
+// Default 返回全局历史存储
+func Default() *Store {
+	return defaultStore
+}
+
+// Configure 根据配置调整全局历史存储
+func Configure(enabled bool, limit int) {
+	defaultStore.mu.Lock()
+	defer defaultStore.mu.Unlock()
+
+	defaultStore.disabled = !enabled
+	if limit > 0 {
+		defaultStore.limit = limit
+	}
+}
+
+// DefaultPath 返回默认的历史文件路径
+func DefaultPath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "ipq", "history.jsonl")
+}
+
+// Path 返回历史文件路径
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Enabled 是否记录历史
+func (s *Store) Enabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.disabled && s.path != ""
+}
+
+// Record 追加一条记录
+//
+// 自动分配 ID 和时间戳。历史关闭时静默忽略。
+func (s *Store) Record(e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.disabled || s.path == "" {
+		return nil
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if !s.loaded {
+		entries, err := s.read()
+		if err != nil {
+			return err
+		}
+		s.count = len(entries)
+		s.loaded = true
+	}
+
+	// 其他进程可能已写入更大的 ID
+	last, err := s.tailID()
+	if err != nil {
+		return err
+	}
+	s.lastID = max(s.lastID, last) + 1
+	e.ID = s.lastID
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	if err := s.append(e); err != nil {
+		return err
+	}
+	s.count++
+
+	// 超出上限 25% 后再裁剪，避免每次写入都重写整个文件
+	if s.count > s.limit+s.limit/4 {
+		return s.prune()
+	}
+	return nil
+}
+
+// lock 获取跨进程的写入锁
+func (s *Store) lock() (unlock func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return nil, fmt.Errorf("cannot create history directory: %w", err)
+	}
+	unlock, err = lockFile(s.path + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("cannot lock history: %w", err)
+	}
+	return unlock, nil
+}
+
+// tailID 文件中最后一条记录的 ID，只读取文件末尾
+//
+// 记录按 ID 递增追加，最后一条有效记录的 ID 就是最大 ID
+func (s *Store) tailID() (int64, error) {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("cannot open history: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("cannot read history: %w", err)
+	}
+	const tail = 64 << 10
+	offset := max(info.Size()-tail, 0)
+	buf := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(buf, offset); err != nil {
+		return 0, fmt.Errorf("cannot read history: %w", err)
+	}
+
+	lines := strings.Split(string(buf), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		var e Entry
+		if json.Unmarshal([]byte(strings.TrimSpace(lines[i])), &e) == nil && e.ID > 0 {
+			return e.ID, nil
+		}
+	}
+	return 0, nil
+}
+
+// List 返回所有记录 (旧 → 新)，最多 limit 条
+func (s *Store) List() ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > s.limit {
+		entries = entries[len(entries)-s.limit:]
+	}
+	return entries, nil
+}
+
+// Get 按 ID 查找记录
+func (s *Store) Get(id int64) (Entry, error) {
+	entries, err := s.List()
+	if err != nil {
+		return Entry{}, err
+	}
+	for _, e := range entries {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return Entry{}, ErrNotFound
+}
+
+// Recent 返回最近查询的不重复目标 (新 → 旧)，最多 n 个
+//
+// 读取失败时返回空，调用方 (TUI) 不需要处理错误
+func (s *Store) Recent(n int) []string {
+	if !s.Enabled() {
+		return nil
+	}
+	entries, err := s.List()
+	if err != nil {
+		return nil
+	}
+
+	var targets []string
+	seen := make(map[string]bool)
+	for i := len(entries) - 1; i >= 0 && len(targets) < n; i-- {
+		t := entries[i].Target
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+// Clear 删除所有记录，返回删除的条数
+func (s *Store) Clear() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	entries, err := s.read()
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	// ID 不重置，避免与用户记住的旧 ID 混淆
+	s.count = 0
+	s.loaded = true
+	if len(entries) > 0 && entries[len(entries)-1].ID > s.lastID {
+		s.lastID = entries[len(entries)-1].ID
+	}
+	return len(entries), nil
+}
+
+// read 读取全部记录，跳过损坏的行
+func (s *Store) read() ([]Entry, error) {
+	if s.path == "" {
+		return nil, nil
+	}
+
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot open history: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read history: %w", err)
+	}
+	return entries, nil
+}
+
+// append 追加一行 (调用方持有锁)
+func (s *Store) append(e Entry) error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("cannot open history: %w", err)
+	}
+	defer file.Close()
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// prune 只保留最新的 limit 条记录 (调用方持有锁)
+//
+// 先写临时文件再重命名，避免中断时丢失历史
+func (s *Store) prune() error {
+	entries, err := s.read()
+	if err != nil {
+		return err
+	}
+	if len(entries) > s.limit {
+		entries = entries[len(entries)-s.limit:]
+	}
+
+	tmp := s.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("cannot write history: %w", err)
+	}
+
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("cannot write history: %w", err)
+	}
+	s.count = len(entries)
+	return nil
+}
@@ -0,0 +1,103 @@
+package history
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// 两个 Store 共用一个文件，相当于两个 ipq 进程同时写入
+func TestRecordConcurrentStoresUniqueIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	stores := []*Store{New(path, 10000), New(path, 10000)}
+
+	const perStore = 100
+	var wg sync.WaitGroup
+	for _, s := range stores {
+		wg.Add(1)
+		go func(s *Store) {
+			defer wg.Done()
+			for i := 0; i < perStore; i++ {
+				if err := s.Record(Entry{Target: "8.8.8.8", Source: SourceArg}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(s)
+	}
+	wg.Wait()
+
+	entries, err := New(path, 10000).List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2*perStore {
+		t.Fatalf("got %d entries, want %d", len(entries), 2*perStore)
+	}
+	seen := make(map[int64]bool)
+	for i, e := range entries {
+		if seen[e.ID] {
+			t.Fatalf("duplicate ID %d", e.ID)
+		}
+		seen[e.ID] = true
+		if i > 0 && e.ID <= entries[i-1].ID {
+			t.Fatalf("IDs not increasing: %d after %d", e.ID, entries[i-1].ID)
+		}
+	}
+}
+
+// 两个 Store 交替写入: 每次都要看到对方写入的 ID
+func TestRecordInterleavedStores(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	a, b := New(path, 100), New(path, 100)
+	for i := 0; i < 3; i++ {
+		for _, s := range []*Store{a, b} {
+			if err := s.Record(Entry{Target: "1.1.1.1"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	entries, err := a.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range entries {
+		if e.ID != int64(i+1) {
+			t.Fatalf("entry %d has ID %d, want %d", i, e.ID, i+1)
+		}
+	}
+}
+
+// 清空后 ID 继续增长，不与旧 ID 混淆
+func TestClearKeepsIDs(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "history.jsonl"), 100)
+	for i := 0; i < 3; i++ {
+		if err := s.Record(Entry{Target: "1.1.1.1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := s.Clear(); err != nil || n != 3 {
+		t.Fatalf("Clear() = %d, %v", n, err)
+	}
+	if err := s.Record(Entry{Target: "1.1.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := s.List()
+	if len(entries) != 1 || entries[0].ID != 4 {
+		t.Fatalf("after clear got %+v, want one entry with ID 4", entries)
+	}
+}
+
+// 超过上限后裁剪，只保留最新的记录
+func TestRecordPrunes(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "history.jsonl"), 4)
+	for i := 0; i < 10; i++ {
+		if err := s.Record(Entry{Target: "1.1.1.1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, _ := s.List()
+	if len(entries) != 4 || entries[len(entries)-1].ID != 10 {
+		t.Fatalf("got %d entries ending at %d, want 4 ending at 10", len(entries), entries[len(entries)-1].ID)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package history
+
+// lockFile 其他系统不支持文件锁，只有进程内的互斥
+func lockFile(path string) (unlock func(), err error) {
+	return func() {}, nil
+}
@@ -0,0 +1,66 @@
+/*
+IPv6 地址标志
+
+标准库不提供地址标志，Linux 通过 /proc/net/if_inet6 暴露:
+
+	fd000000000000000000000000000002 04 40 00 82     eth0
+	地址 (32 位十六进制)             索引 前缀 范围 标志 接口名
+
+其他系统没有此文件，地址不标记临时/弃用状态。
+*/
+package local
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 地址标志 (linux/if_addr.h)
+const (
+	ifaFTemporary  = 0x01 // 临时地址 (隐私扩展)
+	ifaFDeprecated = 0x20 // 已弃用
+)
+
+// inet6Path Linux IPv6 地址表
+const inet6Path = "/proc/net/if_inet6"
+
+// inet6Key 地址标志表的键
+func inet6Key(iface string, addr net.IP) string {
+	return iface + "|" + addr.String()
+}
+
+// readInet6Flags 读取所有 IPv6 地址的标志
+//
+// 文件不存在或格式不符时返回空表
+func readInet6Flags() map[string]uint64 {
+	flags := make(map[string]uint64)
+
+	file, err := os.Open(inet6Path)
+	if err != nil {
+		return flags
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+
+		raw, err := hex.DecodeString(fields[0])
+		if err != nil || len(raw) != net.IPv6len {
+			continue
+		}
+		f, err := strconv.ParseUint(fields[4], 16, 32)
+		if err != nil {
+			continue
+		}
+		flags[inet6Key(fields[5], net.IP(raw))] = f
+	}
+	return flags
+}
@@ -0,0 +1,188 @@
+/*
+Package local 提供本机网络接口和地址清单
+
+依赖: internal/ip
+
+"我的 IP 是什么" 有两个答案:
+- 公网 IP: 互联网看到的地址 (network 包负责)
+- 本机 IP: 接口上配置的地址，其中一个是访问互联网时的源地址
+
+本包回答第二个问题:
+- 列出所有接口: 地址、前缀长度、MTU、标志、MAC
+- 用 ip.Classify 分类每个地址
+- 标记 IPv6 临时地址 (隐私扩展，RFC 8981)
+- 标记出口源地址和默认路由所在的接口
+
+出口源地址的检测方式:
+对公网地址 "连接" 一个 UDP socket，内核会选择路由和源地址，
+但 UDP 的 connect 不发送任何数据包。
+*/
+package local
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github/shawn/ip-tool/internal/ip"
+)
+
+// 出口探测目标: 只用于路由选择，不会发送数据包
+const (
+	probeIPv4 = "8.8.8.8:53"
+	probeIPv6 = "[2001:4860:4860::8888]:53"
+)
+
+// Address 接口上的一个地址
+type Address struct {
+	IP         string `json:"ip" yaml:"ip"`
+	PrefixLen  int    `json:"prefix_len" yaml:"prefix_len"`
+	Family     string `json:"family" yaml:"family"`                             // "ipv4" 或 "ipv6"
+	Type       string `json:"type" yaml:"type"`                                 // ip.Classify 分类
+	Temporary  bool   `json:"temporary,omitempty" yaml:"temporary,omitempty"`   // IPv6 临时 (隐私) 地址
+	Deprecated bool   `json:"deprecated,omitempty" yaml:"deprecated,omitempty"` // 已过首选期，不再用于新连接
+	Egress     bool   `json:"egress,omitempty" yaml:"egress,omitempty"`         // 访问互联网时的源地址
+}
+
+// CIDR 返回 "地址/前缀长度" 形式
+func (a Address) CIDR() string {
+	return a.IP + "/" + strconv.Itoa(a.PrefixLen)
+}
+
+// Interface 网络接口
+type Interface struct {
+	Name         string    `json:"name" yaml:"name"`
+	Index        int       `json:"index" yaml:"index"`
+	MTU          int       `json:"mtu" yaml:"mtu"`
+	MAC          string    `json:"mac,omitempty" yaml:"mac,omitempty"`
+	Flags        []string  `json:"flags" yaml:"flags"`
+	DefaultRoute bool      `json:"default_route,omitempty" yaml:"default_route,omitempty"`
+	Addresses    []Address `json:"addresses" yaml:"addresses"`
+}
+
+// IsUp 接口是否启用
+func (i Interface) IsUp() bool {
+	for _, f := range i.Flags {
+		if f == "up" {
+			return true
+		}
+	}
+	return false
+}
+
+// Inventory 本机网络清单
+type Inventory struct {
+	Interfaces []Interface `json:"interfaces" yaml:"interfaces"`
+	EgressIPv4 string      `json:"egress_ipv4,omitempty" yaml:"egress_ipv4,omitempty"`
+	EgressIPv6 string      `json:"egress_ipv6,omitempty" yaml:"egress_ipv6,omitempty"`
+}
+
+// Collect 收集本机网络清单
+func Collect() (*Inventory, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	inv := &Inventory{
+		Interfaces: make([]Interface, 0, len(ifaces)),
+		EgressIPv4: EgressIP(probeIPv4),
+		EgressIPv6: EgressIP(probeIPv6),
+	}
+	flags := readInet6Flags()
+
+	for _, iface := range ifaces {
+		entry := Interface{
+			Name:      iface.Name,
+			Index:     iface.Index,
+			MTU:       iface.MTU,
+			MAC:       iface.HardwareAddr.String(),
+			Flags:     flagNames(iface.Flags),
+			Addresses: []Address{},
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			inv.Interfaces = append(inv.Interfaces, entry)
+			continue
+		}
+
+		for _, a := range addrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			addr := newAddress(ipnet)
+
+			if f, ok := flags[inet6Key(iface.Name, ipnet.IP)]; ok {
+				addr.Temporary = f&ifaFTemporary != 0
+				addr.Deprecated = f&ifaFDeprecated != 0
+			}
+			if addr.IP == inv.EgressIPv4 || addr.IP == inv.EgressIPv6 {
+				addr.Egress = true
+				entry.DefaultRoute = true
+			}
+			entry.Addresses = append(entry.Addresses, addr)
+		}
+
+		inv.Interfaces = append(inv.Interfaces, entry)
+	}
+
+	sort.SliceStable(inv.Interfaces, func(i, j int) bool {
+		return inv.Interfaces[i].Index < inv.Interfaces[j].Index
+	})
+	return inv, nil
+}
+
+// newAddress 根据接口地址创建 Address
+func newAddress(ipnet *net.IPNet) Address {
+	ones, _ := ipnet.Mask.Size()
+	s := ipnet.IP.String()
+
+	family := "ipv6"
+	if ipnet.IP.To4() != nil {
+		family = "ipv4"
+	}
+	return Address{
+		IP:        s,
+		PrefixLen: ones,
+		Family:    family,
+		Type:      string(ip.Classify(s)),
+	}
+}
+
+// EgressIP 返回访问 probe 地址时内核选择的源地址
+//
+// UDP connect 只做路由选择，不发送数据包；没有路由时返回空
+func EgressIP(probe string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", probe)
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return ""
+	}
+	return addr.IP.String()
+}
+
+// flagNames 将接口标志转换为小写名称列表
+func flagNames(f net.Flags) []string {
+	names := []string{}
+	if f == 0 {
+		return names
+	}
+	for _, name := range strings.Split(f.String(), "|") {
+		names = append(names, strings.ToLower(name))
+	}
+	return names
+}
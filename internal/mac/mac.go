@@ -0,0 +1,106 @@
+/*
+Package mac 提供 MAC 地址解析、位解码和厂商 (OUI) 查询
+
+依赖: internal/network (仅 Update 下载注册表时使用)
+
+MAC 地址的前 3 字节是 IEEE 分配给厂商的 OUI，首字节的低两位有特殊含义:
+- bit 0 (I/G): 1 表示组播/广播地址
+- bit 1 (U/L): 1 表示本地管理地址 (虚拟机、容器、手机的随机 MAC)
+
+本地管理地址不是 IEEE 分配的，查不到厂商是正常的。
+*/
+package mac
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// ErrInvalid 无法解析的 MAC 地址
+var ErrInvalid = errors.New("invalid MAC address")
+
+// Info MAC 地址解码结果
+type Info struct {
+	Address             string `json:"address" yaml:"address"` // 规范形式 aa:bb:cc:dd:ee:ff (仅前缀时为 aa:bb:cc)
+	OUI                 string `json:"oui" yaml:"oui"`         // AA:BB:CC
+	Vendor              string `json:"vendor,omitempty" yaml:"vendor,omitempty"`
+	Multicast           bool   `json:"multicast" yaml:"multicast"`                       // I/G 位
+	LocallyAdministered bool   `json:"locally_administered" yaml:"locally_administered"` // U/L 位
+	Broadcast           bool   `json:"broadcast,omitempty" yaml:"broadcast,omitempty"`
+	Note                string `json:"note,omitempty" yaml:"note,omitempty"` // 已知的特殊地址说明
+}
+
+// 常见的非 IEEE 分配地址 (按前缀匹配，十六进制大写无分隔符)
+var wellKnown = []struct {
+	prefix string
+	note   string
+}{
+	{"FFFFFFFFFFFF", "Broadcast"},
+	{"01005E", "IPv4 multicast (RFC 1112)"},
+	{"3333", "IPv6 multicast (RFC 2464)"},
+	{"0180C2", "IEEE 802.1 link-local (STP, LLDP, LACP)"},
+	{"00005E0001", "VRRP virtual router (IPv4)"},
+	{"00005E0002", "VRRP virtual router (IPv6)"},
+	{"525400", "QEMU/KVM virtual NIC"},
+	{"0242", "Docker container"},
+}
+
+// Parse 解析并解码 MAC 地址
+//
+// 支持 aa:bb:cc:dd:ee:ff、aa-bb-cc-dd-ee-ff、aabb.ccdd.eeff、aabbccddeeff，
+// 以及只有 OUI 的前缀 (aa:bb:cc)
+func Parse(s string) (*Info, error) {
+	raw, err := normalize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	b, _ := hex.DecodeString(raw)
+	info := &Info{
+		Address:             colon(raw),
+		OUI:                 strings.ToUpper(colon(raw[:6])),
+		Multicast:           b[0]&0x01 != 0,
+		LocallyAdministered: b[0]&0x02 != 0,
+		Broadcast:           raw == "ffffffffffff",
+	}
+
+	upper := strings.ToUpper(raw)
+	for _, wk := range wellKnown {
+		if strings.HasPrefix(upper, wk.prefix) {
+			info.Note = wk.note
+			break
+		}
+	}
+	if info.Note == "" && info.LocallyAdministered && !info.Multicast {
+		info.Note = "Locally administered (randomized or virtual)"
+	}
+
+	// 本地管理地址的前缀不是 OUI
+	if !info.LocallyAdministered {
+		info.Vendor = Vendor(raw[:6])
+	}
+	return info, nil
+}
+
+// normalize 去掉分隔符，返回小写十六进制 (6 或 12 位)
+func normalize(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.NewReplacer(":", "", "-", "", ".", "").Replace(s)
+	if len(s) != 6 && len(s) != 12 {
+		return "", ErrInvalid
+	}
+	if _, err := hex.DecodeString(s); err != nil {
+		return "", ErrInvalid
+	}
+	return s, nil
+}
+
+// colon 十六进制字符串转为冒号分隔形式
+func colon(raw string) string {
+	parts := make([]string, 0, len(raw)/2)
+	for i := 0; i+1 < len(raw); i += 2 {
+		parts = append(parts, raw[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
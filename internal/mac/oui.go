@@ -0,0 +1,95 @@
+/*
+OUI 厂商数据库
+
+内置一份手工整理的小列表 (oui.txt，随二进制发布)，只包含常见的网络设备、
+服务器、虚拟化和消费电子厂商，不是完整的 IEEE 注册表。
+
+完整的 IEEE MA-L 注册表 (约 3 万条) 通过 Update (ipq mac --update) 下载到:
+1. $XDG_DATA_HOME/ipq/oui.txt
+2. ~/.local/share/ipq/oui.txt (XDG 默认值)
+
+下载的数据库存在时优先使用，否则回退到内置列表。
+
+文件格式: 每行 "AABBCC<TAB>厂商名"，# 开头为注释。
+*/
+package mac
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+//go:embed oui.txt
+var embedded string
+
+var (
+	dbOnce sync.Once
+	db     map[string]string
+	dbFrom string // 数据库来源: 文件路径或 "embedded"
+)
+
+// DefaultPath 返回下载的数据库路径
+func DefaultPath() string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "ipq", "oui.txt")
+}
+
+// Vendor 查询 OUI 对应的厂商，未知时返回空
+//
+// oui 为 6 位十六进制 (大小写均可，不含分隔符)
+func Vendor(oui string) string {
+	load()
+	return db[strings.ToUpper(oui)]
+}
+
+// Source 返回正在使用的数据库来源和条目数
+func Source() (string, int) {
+	load()
+	return dbFrom, len(db)
+}
+
+// load 加载数据库 (只加载一次)
+func load() {
+	dbOnce.Do(func() {
+		if path := DefaultPath(); path != "" {
+			if file, err := os.Open(path); err == nil {
+				defer file.Close()
+				if m := parseDB(file); len(m) > 0 {
+					db, dbFrom = m, path
+					return
+				}
+			}
+		}
+		db, dbFrom = parseDB(strings.NewReader(embedded)), "embedded"
+	})
+}
+
+// parseDB 解析 "AABBCC<TAB>厂商名" 格式
+func parseDB(r io.Reader) map[string]string {
+	m := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		oui, vendor, ok := strings.Cut(line, "\t")
+		if !ok || len(oui) != 6 {
+			continue
+		}
+		m[strings.ToUpper(oui)] = strings.TrimSpace(vendor)
+	}
+	return m
+}
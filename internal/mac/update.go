@@ -0,0 +1,127 @@
+/*
+OUI 数据库更新
+
+从 IEEE 注册机构下载 MA-L (24 位 OUI) 注册表 CSV:
+
+	Registry,Assignment,Organization Name,Organization Address
+	MA-L,00000C,"Cisco Systems, Inc",170 WEST TASMAN DRIVE ...
+
+转换为本包的文件格式后原子替换 DefaultPath。
+*/
+package mac
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github/shawn/ip-tool/internal/network"
+)
+
+// RegistryURL IEEE MA-L 注册表
+const RegistryURL = "https://standards-oui.ieee.org/oui/oui.csv"
+
+// updateTimeout 下载超时 (文件约 3MB)
+const updateTimeout = 60 * time.Second
+
+// Update 用 client 下载 IEEE 注册表并写入 DefaultPath，返回条目数
+func Update(ctx context.Context, client *http.Client) (int, error) {
+	path := DefaultPath()
+	if path == "" {
+		return 0, fmt.Errorf("cannot determine data directory")
+	}
+
+	entries, err := Download(ctx, client)
+	if err != nil {
+		return 0, err
+	}
+	if err := WriteDB(path, entries); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// Download 用 client 下载并解析 IEEE 注册表，返回 OUI -> 厂商
+func Download(ctx context.Context, client *http.Client) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", RegistryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("download oui registry: %w", network.ErrTimeout)
+		}
+		return nil, fmt.Errorf("network error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %d", resp.StatusCode)
+	}
+	return parseRegistry(resp.Body)
+}
+
+// parseRegistry 解析 IEEE CSV，返回 OUI -> 厂商
+func parseRegistry(r io.Reader) (map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	entries := make(map[string]string)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse registry: %w", err)
+		}
+		if len(record) < 3 || record[0] != "MA-L" || len(record[1]) != 6 {
+			continue // 表头或其他注册类型
+		}
+		entries[strings.ToUpper(record[1])] = strings.TrimSpace(record[2])
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("registry contains no MA-L entries")
+	}
+	return entries, nil
+}
+
+// WriteDB 按本包的文件格式写入 path
+//
+// 先写临时文件再重命名，避免读到写了一半的数据库
+func WriteDB(path string, entries map[string]string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# IEEE MA-L registry, downloaded %s from %s\n",
+		time.Now().UTC().Format(time.RFC3339), RegistryURL)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s\t%s\n", k, entries[k])
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,137 @@
+//go:build linux
+
+/*
+IPv6 邻居表 (Linux)
+
+/proc 下没有 IPv6 邻居表，通过 netlink RTM_GETNEIGH 获取。
+每条消息是一个 ndmsg 头加若干属性:
+
+	struct ndmsg { u8 family; u8 pad1; u16 pad2; s32 ifindex; u16 state; u8 flags; u8 type; }
+	NDA_DST (1): 邻居地址    NDA_LLADDR (2): 链路层地址
+*/
+package neigh
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// netlink 常量 (linux/neighbour.h)
+const (
+	sizeofNdMsg = 12
+	ndaDst      = 1
+	ndaLLAddr   = 2
+	ntfRouter   = 0x80
+)
+
+// 邻居状态 (NUD_*)
+var nudStates = []struct {
+	bit  uint16
+	name string
+}{
+	{0x01, "incomplete"},
+	{0x02, "reachable"},
+	{0x04, "stale"},
+	{0x08, "delay"},
+	{0x10, "probe"},
+	{0x20, "failed"},
+	{0x40, "noarp"},
+	{0x80, "permanent"},
+}
+
+// readIPv6 通过 netlink 读取 IPv6 邻居表
+func readIPv6() ([]Neighbor, error) {
+	buf, err := syscall.NetlinkRIB(syscall.RTM_GETNEIGH, syscall.AF_INET6)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := syscall.ParseNetlinkMessage(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Neighbor
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWNEIGH || len(m.Data) < sizeofNdMsg {
+			continue
+		}
+		if m.Data[0] != syscall.AF_INET6 {
+			continue
+		}
+
+		ifindex := int(int32(binary.NativeEndian.Uint32(m.Data[4:8])))
+		state := binary.NativeEndian.Uint16(m.Data[8:10])
+		flags := m.Data[10]
+
+		var dst net.IP
+		var lladdr net.HardwareAddr
+		for _, attr := range parseAttrs(m.Data[sizeofNdMsg:]) {
+			switch attr.typ {
+			case ndaDst:
+				dst = net.IP(attr.value)
+			case ndaLLAddr:
+				lladdr = net.HardwareAddr(attr.value)
+			}
+		}
+		if dst == nil {
+			continue
+		}
+
+		iface := ""
+		if ifi, err := net.InterfaceByIndex(ifindex); err == nil {
+			iface = ifi.Name
+		}
+
+		n := newNeighbor(dst.String(), iface, stateName(state))
+		n.Router = flags&ntfRouter != 0
+		if len(lladdr) > 0 {
+			annotate(&n, lladdr.String())
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+// attr netlink 路由属性
+type attr struct {
+	typ   uint16
+	value []byte
+}
+
+// parseAttrs 解析 rtattr 序列 (每个属性按 4 字节对齐)
+func parseAttrs(b []byte) []attr {
+	var attrs []attr
+	for len(b) >= 4 {
+		l := int(binary.NativeEndian.Uint16(b[0:2]))
+		if l < 4 || l > len(b) {
+			break
+		}
+		attrs = append(attrs, attr{
+			typ:   binary.NativeEndian.Uint16(b[2:4]),
+			value: b[4:l],
+		})
+
+		aligned := (l + 3) &^ 3
+		if aligned > len(b) {
+			break
+		}
+		b = b[aligned:]
+	}
+	return attrs
+}
+
+// stateName NUD 状态名称 (可能同时有多个位)
+func stateName(state uint16) string {
+	var names []string
+	for _, s := range nudStates {
+		if state&s.bit != 0 {
+			names = append(names, s.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
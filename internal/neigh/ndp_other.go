@@ -0,0 +1,8 @@
+//go:build !linux
+
+package neigh
+
+// readIPv6 非 Linux 系统暂不支持读取 IPv6 邻居表
+func readIPv6() ([]Neighbor, error) {
+	return nil, ErrUnavailable
+}
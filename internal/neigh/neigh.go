@@ -0,0 +1,144 @@
+/*
+Package neigh 读取邻居表 (ARP / IPv6 NDP) 并标注 MAC 厂商
+
+依赖: internal/ip, internal/mac
+
+分支机构局域网里出现陌生设备时，第一步通常是 "这个 IP 的 MAC 是谁家的"。
+本包列出内核邻居缓存中的每个条目，用 ip.Classify 分类地址，
+用 OUI 数据库查出网卡厂商。
+
+数据来源 (Linux):
+- /proc/net/arp: IPv4 ARP 缓存
+- netlink RTM_GETNEIGH: IPv6 邻居表 (没有对应的 /proc 文件)
+
+邻居表只包含最近通信过的主机，不是网段扫描。
+*/
+package neigh
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github/shawn/ip-tool/internal/ip"
+	"github/shawn/ip-tool/internal/mac"
+)
+
+// arpPath Linux IPv4 ARP 缓存
+var arpPath = "/proc/net/arp"
+
+// ErrUnavailable 系统不提供可读的邻居表
+var ErrUnavailable = errors.New("neighbor table not available on this system")
+
+// ARP 条目标志 (linux/if_arp.h)
+const (
+	atfCom  = 0x02 // 已解析
+	atfPerm = 0x04 // 静态条目
+)
+
+// Neighbor 邻居表的一个条目
+type Neighbor struct {
+	IP        string `json:"ip" yaml:"ip"`
+	Family    string `json:"family" yaml:"family"` // "ipv4" 或 "ipv6"
+	Type      string `json:"type" yaml:"type"`     // ip.Classify 分类
+	MAC       string `json:"mac,omitempty" yaml:"mac,omitempty"`
+	Vendor    string `json:"vendor,omitempty" yaml:"vendor,omitempty"`
+	Note      string `json:"note,omitempty" yaml:"note,omitempty"` // 本地管理、组播等 MAC 说明
+	Interface string `json:"interface" yaml:"interface"`
+	State     string `json:"state" yaml:"state"`
+	Router    bool   `json:"router,omitempty" yaml:"router,omitempty"` // IPv6 邻居声明了路由器标志
+}
+
+// List 读取 IPv4 和 IPv6 邻居表
+//
+// 两者都不可用时返回 ErrUnavailable；只有一个可用时正常返回
+func List() ([]Neighbor, error) {
+	v4, err4 := readARP(arpPath)
+	v6, err6 := readIPv6()
+	if err4 != nil && err6 != nil {
+		if os.IsNotExist(err4) {
+			return nil, ErrUnavailable
+		}
+		return nil, err4
+	}
+
+	list := append(v4, v6...)
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Family != list[j].Family {
+			return list[i].Family < list[j].Family
+		}
+		if list[i].Interface != list[j].Interface {
+			return list[i].Interface < list[j].Interface
+		}
+		return list[i].IP < list[j].IP
+	})
+	return list, nil
+}
+
+// readARP 解析 /proc/net/arp
+//
+//	IP address       HW type     Flags       HW address            Mask     Device
+//	192.0.2.1        0x1         0x2         02:fc:00:00:00:05     *        eth0
+func readARP(path string) ([]Neighbor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Neighbor
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 6 {
+			continue // 表头
+		}
+
+		flags, err := strconv.ParseUint(strings.TrimPrefix(fields[2], "0x"), 16, 32)
+		if err != nil {
+			continue
+		}
+
+		state := "incomplete"
+		switch {
+		case flags&atfPerm != 0:
+			state = "permanent"
+		case flags&atfCom != 0:
+			state = "reachable"
+		}
+
+		n := newNeighbor(fields[0], fields[5], state)
+		if flags&atfCom != 0 {
+			annotate(&n, fields[3])
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+// newNeighbor 构造 Neighbor
+func newNeighbor(addr, iface, state string) Neighbor {
+	family := "ipv6"
+	if !strings.Contains(addr, ":") {
+		family = "ipv4"
+	}
+	return Neighbor{
+		IP:        addr,
+		Family:    family,
+		Type:      string(ip.Classify(addr)),
+		Interface: iface,
+		State:     state,
+	}
+}
+
+// annotate 填充 MAC 和厂商信息
+func annotate(n *Neighbor, hw string) {
+	info, err := mac.Parse(hw)
+	if err != nil {
+		n.MAC = hw
+		return
+	}
+	n.MAC = info.Address
+	n.Vendor = info.Vendor
+	n.Note = info.Note
+}
@@ -0,0 +1,89 @@
+/*
+共享 HTTP 客户端
+
+所有出站 HTTP 请求共用少数几个 Transport (不限地址族的一个，加上固定 IPv4、IPv6 的各一个)，
+连接用完后保持空闲 (keep-alive)，批量查询时复用已建立的 TCP/TLS 连接，
+HTTPS 服务支持时使用 HTTP/2 在一个连接上并发请求，而不是每个请求都重新握手。
+
+连接数有上限: ip-api.com 等免费服务按来源 IP 限流，并发连接过多会被拒绝。
+代理设置变化时 (SetProxy) 关闭旧连接并重建客户端。
+*/
+package network
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// 连接池参数
+const (
+	maxIdleConns        = 64               // 所有主机的空闲连接总数
+	maxIdleConnsPerHost = 8                // 每个主机保留的空闲连接 (默认只有 2，批量查询会反复握手)
+	maxConnsPerHost     = 16               // 每个主机的连接上限
+	idleConnTimeout     = 90 * time.Second // 空闲连接保留时间
+)
+
+// HTTPClient 所有出站 HTTP 请求使用的客户端 (遵守代理设置，共享连接池)
+func HTTPClient() *http.Client {
+	return client("")
+}
+
+// client 地址族对应的共享客户端，不存在时创建
+func client(family string) *http.Client {
+	proxyConfig.RLock()
+	c := proxyConfig.clients[family]
+	proxyConfig.RUnlock()
+	if c != nil {
+		return c
+	}
+
+	proxyConfig.Lock()
+	defer proxyConfig.Unlock()
+	if c := proxyConfig.clients[family]; c != nil {
+		return c
+	}
+	if proxyConfig.clients == nil {
+		proxyConfig.clients = make(map[string]*http.Client)
+	}
+	c = &http.Client{Transport: newTransport(proxyConfig.url, proxyConfig.socks, family)}
+	proxyConfig.clients[family] = c
+	return c
+}
+
+// resetClients 丢弃共享客户端并关闭其空闲连接 (调用方持有 proxyConfig 写锁)
+func resetClients() {
+	for _, c := range proxyConfig.clients {
+		c.CloseIdleConnections()
+	}
+	proxyConfig.clients = nil
+}
+
+// newTransport 按代理设置创建 Transport
+//
+// family 非空且没有代理时，连接固定使用该地址族 (tcp4/tcp6)；
+// 有代理时地址族由代理的出口决定
+func newTransport(u *url.URL, socks *socksDialer, family string) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = maxIdleConns
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	transport.MaxConnsPerHost = maxConnsPerHost
+	transport.IdleConnTimeout = idleConnTimeout
+	transport.ForceAttemptHTTP2 = true // 自定义 DialContext 时仍然协商 HTTP/2
+
+	switch {
+	case socks != nil:
+		transport.Proxy = nil
+		transport.DialContext = socks.DialContext
+	case u != nil:
+		transport.Proxy = http.ProxyURL(u)
+	case family != "":
+		dialer := &net.Dialer{Timeout: defaultTimeout, KeepAlive: 30 * time.Second}
+		transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, dialNetwork("tcp", family), addr)
+		}
+	}
+	return transport
+}
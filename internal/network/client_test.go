@@ -0,0 +1,91 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// geoServer 本地的 ip-api.com 替身，每个请求模拟 1ms 的服务端延迟
+//
+// 返回服务收到的请求数和新建的连接数
+func geoServer(b *testing.B) (requests, conns *atomic.Int64) {
+	b.Helper()
+	requests, conns = new(atomic.Int64), new(atomic.Int64)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		time.Sleep(time.Millisecond)
+		ip := strings.TrimPrefix(r.URL.Path, "/json/")
+		fmt.Fprintf(w, `{"status":"success","query":%q,"country":"Testland","isp":"Test ISP"}`, ip)
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	b.Cleanup(srv.Close)
+
+	orig := geoAPI
+	geoAPI = srv.URL
+	b.Cleanup(func() { geoAPI = orig })
+	return requests, conns
+}
+
+// useTransport 让 HTTPClient() 返回使用 tr 的客户端
+func useTransport(b *testing.B, tr *http.Transport) {
+	b.Helper()
+	proxyConfig.Lock()
+	resetClients()
+	proxyConfig.clients = map[string]*http.Client{"": {Transport: tr}}
+	proxyConfig.Unlock()
+	b.Cleanup(func() {
+		proxyConfig.Lock()
+		resetClients()
+		proxyConfig.Unlock()
+	})
+}
+
+// benchmarkLookup 并发查询地理位置，每连续 16 次查询同一个 IP (如批量文件中的重复行)
+//
+// shared 为 false 时每个请求新建连接 (关闭 keep-alive)；
+// flights 为 false 时绕过 flights，相同 IP 的并发查询各自发出请求
+func benchmarkLookup(b *testing.B, shared, flights bool) {
+	requests, conns := geoServer(b)
+	tr := newTransport(nil, nil, "")
+	tr.DisableKeepAlives = !shared
+	useTransport(b, tr)
+
+	lookup := fetchGeoInfo
+	if flights {
+		lookup = FetchGeoInfo
+	}
+
+	ctx := context.Background()
+	var n atomic.Int64
+	b.SetParallelism(4)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			ip := fmt.Sprintf("203.0.113.%d", n.Add(1)/16%256)
+			if _, err := lookup(ctx, ip); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+	b.StopTimer()
+	b.ReportMetric(float64(requests.Load())/float64(b.N), "requests/op")
+	b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+}
+
+func BenchmarkLookupShared(b *testing.B)          { benchmarkLookup(b, true, true) }
+func BenchmarkLookupSharedNoFlights(b *testing.B) { benchmarkLookup(b, true, false) }
+func BenchmarkLookupFresh(b *testing.B)           { benchmarkLookup(b, false, true) }
+func BenchmarkLookupFreshNoFlights(b *testing.B)  { benchmarkLookup(b, false, false) }
@@ -0,0 +1,497 @@
+/*
+公网 IP 共识检测
+
+单一回显服务可能返回错误的内容 (门户页面、代理的地址、故障)。
+这里并发查询多个不同类型的来源，校验每个答案，取多数结果:
+
+- HTTP 回显: icanhazip、ipify、ident.me、AWS checkip
+- DNS: OpenDNS 的 myip.opendns.com、Google 的 o-o.myaddr TXT 记录
+- STUN: Google 的公共 STUN 服务器
+
+来源之间的分歧本身就是信息:
+- HTTP 与 DNS/STUN 不一致: 可能有只代理 HTTP 的透明代理
+- 不同 HTTP 服务不一致: 可能是按目的地分流的 VPN (split tunnel)
+
+所有来源都固定使用请求的地址族 (tcp4/udp4 或 tcp6/udp6)，
+双栈服务不会用 IPv4 回答 IPv6 查询。
+
+每个答案都要能解析为该地址族的 IP: 门户页面 (重定向、HTML) 会被识别为 ErrBadResponse，
+不参与投票。超时和连不上的来源在检测期限内重试 (retry.go)。
+
+本机没有 IPv6 默认路由或全局 IPv6 地址时，IPv6 检测立即失败，
+不必等待每个来源超时。
+*/
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github/shawn/ip-tool/internal/ip"
+	"github/shawn/ip-tool/internal/stun"
+)
+
+// Family 地址族
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// Source 一个公网 IP 来源
+type Source struct {
+	Name string // 配置中使用的名称
+	Kind string // "http"、"dns" 或 "stun"
+
+	// 每个地址族的查询地址，为空表示不支持该地址族
+	IPv4 string
+	IPv6 string
+
+	query func(ctx context.Context, family, addr string) (string, error)
+}
+
+// sources 全部内置来源 (顺序即平票时的优先级)
+var sources = []Source{
+	{Name: "icanhazip", Kind: "http", IPv4: "https://ipv4.icanhazip.com", IPv6: "https://ipv6.icanhazip.com", query: queryHTTP},
+	{Name: "ipify", Kind: "http", IPv4: "https://api.ipify.org", IPv6: "https://api6.ipify.org", query: queryHTTP},
+	{Name: "identme", Kind: "http", IPv4: "https://v4.ident.me", IPv6: "https://v6.ident.me", query: queryHTTP},
+	{Name: "aws", Kind: "http", IPv4: "https://checkip.amazonaws.com", query: queryHTTP},
+	{Name: "opendns", Kind: "dns", IPv4: "208.67.222.222:53", IPv6: "[2620:119:35::35]:53", query: queryOpenDNS},
+	{Name: "google-dns", Kind: "dns", IPv4: "216.239.32.10:53", IPv6: "[2001:4860:4802:32::a]:53", query: queryGoogleDNS},
+	{Name: "stun", Kind: "stun", IPv4: "stun.l.google.com:19302", IPv6: "stun.l.google.com:19302", query: querySTUN},
+}
+
+// ipv6Probe 检查 IPv6 路由时 "连接" 的地址 (不发送数据)
+const ipv6Probe = "[2001:4860:4860::8888]:53"
+
+// DefaultSources 默认启用的来源
+var DefaultSources = []string{"icanhazip", "ipify", "identme", "opendns", "google-dns", "stun"}
+
+// active 当前启用的来源
+var (
+	activeMu sync.Mutex
+	active   = mustSources(DefaultSources)
+)
+
+// Answer 一个来源的回答
+type Answer struct {
+	Source   string `json:"source" yaml:"source"`
+	Kind     string `json:"kind" yaml:"kind"`
+	IP       string `json:"ip,omitempty" yaml:"ip,omitempty"`
+	Error    string `json:"error,omitempty" yaml:"error,omitempty"`
+	Duration int64  `json:"duration_ms" yaml:"duration_ms"`             // 毫秒
+	Skipped  bool   `json:"skipped,omitempty" yaml:"skipped,omitempty"` // 经代理时未查询
+}
+
+// Consensus 共识结果
+type Consensus struct {
+	Family   string   `json:"family" yaml:"family"`
+	IP       string   `json:"ip,omitempty" yaml:"ip,omitempty"` // 多数结果
+	Votes    int      `json:"votes" yaml:"votes"`               // 支持多数结果的来源数
+	Valid    int      `json:"valid" yaml:"valid"`               // 给出有效答案的来源数
+	Disagree bool     `json:"disagree" yaml:"disagree"`         // 有效答案是否不一致
+	Answers  []Answer `json:"answers" yaml:"answers"`
+}
+
+// Dissenters 与多数结果不一致的来源
+func (c *Consensus) Dissenters() []Answer {
+	var out []Answer
+	for _, a := range c.Answers {
+		if a.IP != "" && a.IP != c.IP {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// Voters 给出多数结果的来源
+func (c *Consensus) Voters() []string {
+	var out []string
+	for _, a := range c.Answers {
+		if a.IP != "" && a.IP == c.IP {
+			out = append(out, a.Source)
+		}
+	}
+	return out
+}
+
+// SourceNames 全部内置来源的名称
+func SourceNames() []string {
+	names := make([]string, len(sources))
+	for i, s := range sources {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// ConfigureSources 设置启用的来源 (配置文件 ip_sources)
+//
+// 空列表恢复默认；有未知名称时返回错误，设置不变
+func ConfigureSources(names []string) error {
+	if len(names) == 0 {
+		names = DefaultSources
+	}
+	list, err := lookupSources(names)
+	if err != nil {
+		return err
+	}
+	activeMu.Lock()
+	active = list
+	activeMu.Unlock()
+	return nil
+}
+
+// lookupSources 按名称查找来源
+func lookupSources(names []string) ([]Source, error) {
+	var list []Source
+	for _, name := range names {
+		found := false
+		for _, s := range sources {
+			if strings.EqualFold(s.Name, strings.TrimSpace(name)) {
+				list = append(list, s)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown IP source %q (available: %s)", name, strings.Join(SourceNames(), ", "))
+		}
+	}
+	return list, nil
+}
+
+// mustSources 用于初始化内置列表
+func mustSources(names []string) []Source {
+	list, err := lookupSources(names)
+	if err != nil {
+		panic(err)
+	}
+	return list
+}
+
+// errMajority 结果已定时取消其余查询的原因
+var errMajority = errors.New("skipped (majority reached)")
+
+// errNotProxied 经代理时跳过的来源 (STUN、没有代理 DNS 时的 DNS)
+var errNotProxied = errors.New("skipped (cannot go through the proxy)")
+
+// DetectPublicIP 并发查询所有启用的来源，返回多数结果
+//
+// wait 为 false 时，一旦领先的 IP 不可能再被超过就立即返回，
+// 其余查询被取消；为 true 时等待所有来源 (用于报告分歧)
+//
+// 同时进行的检测 (如批量文件中多次查询本机) 共享一次结果 (flight.go)
+func DetectPublicIP(ctx context.Context, family string, wait bool) (*Consensus, error) {
+	key := "public:" + family
+	if wait {
+		key += ":all"
+	}
+	v, err := flights.do(ctx, key, func(ctx context.Context) (any, error) {
+		return detectPublicIP(ctx, family, wait)
+	})
+	c, ok := v.(*Consensus)
+	if !ok {
+		c = &Consensus{Family: family}
+	}
+	record(ctx, ProviderConsensus, "")
+	if t := traceFrom(ctx); t != nil {
+		t.Sources = c.Voters()
+	}
+	return c, err
+}
+
+// detectPublicIP 执行一次多来源检测
+func detectPublicIP(ctx context.Context, family string, wait bool) (*Consensus, error) {
+	activeMu.Lock()
+	list := active
+	activeMu.Unlock()
+
+	// 不能经代理的来源不查询，但在结果中注明
+	var usable []Source
+	var skipped []Answer
+	proxy := proxied()
+	for _, s := range list {
+		switch {
+		case s.addr(family) == "":
+		case proxy && !s.viaProxy():
+			skipped = append(skipped, Answer{Source: s.Name, Kind: s.Kind, Error: errNotProxied.Error(), Skipped: true})
+		default:
+			usable = append(usable, s)
+		}
+	}
+	if len(usable) == 0 {
+		detail := "no " + family + " source configured"
+		if len(skipped) > 0 {
+			detail = "no " + family + " source can be sent through the proxy"
+		}
+		return &Consensus{Family: family, Answers: skipped}, &ProviderError{Kind: ErrLookupFailed, Detail: detail}
+	}
+	if family == FamilyIPv6 && !proxy {
+		if err := checkIPv6(); err != nil {
+			return &Consensus{Family: family}, err
+		}
+	}
+
+	ctx, cancelTimeout := context.WithTimeout(ctx, defaultTimeout)
+	defer cancelTimeout()
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	answers := make(chan answer, len(usable))
+	for _, s := range usable {
+		go func(s Source) {
+			start := time.Now()
+			var addr string
+			err := withRetry(ctx, func() error {
+				var err error
+				if addr, err = s.query(ctx, family, s.addr(family)); err == nil {
+					addr, err = validate(addr, family)
+				}
+				return err
+			})
+			a := Answer{Source: s.Name, Kind: s.Kind, Duration: time.Since(start).Milliseconds()}
+			if err != nil {
+				if context.Cause(ctx) == errMajority {
+					err = errMajority
+				}
+				a.Error = reason(err)
+			} else {
+				a.IP = addr
+			}
+			answers <- answer{a, err}
+		}(s)
+	}
+
+	c := &Consensus{Family: family}
+	votes := make(map[string]int)
+	var errs []error
+	for i := range usable {
+		a := <-answers
+		c.Answers = append(c.Answers, a.Answer)
+		if a.err != nil {
+			errs = append(errs, a.err)
+		}
+		if a.IP == "" {
+			continue
+		}
+		votes[a.IP]++
+		if !wait && decided(votes, len(usable)-i-1) {
+			cancel(errMajority) // 结果已定，不再等待
+		}
+	}
+
+	c.tally(votes, usable)
+	c.Answers = append(c.Answers, skipped...)
+	if c.IP == "" {
+		if ctx.Err() != nil && errors.Is(context.Cause(ctx), context.Canceled) {
+			return c, context.Canceled
+		}
+		return c, &ProviderError{Kind: failureKind(errs), Detail: "no source returned a valid " + family + " address"}
+	}
+	return c, nil
+}
+
+// answer 来源的回答及其错误 (用于归类整体失败)
+type answer struct {
+	Answer
+	err error
+}
+
+// failureKind 所有来源都失败时的整体类别
+//
+// 全部超时才算超时，有来源连不上算连不上，其余 (门户页面等) 算响应错误
+func failureKind(errs []error) error {
+	timeouts, unreachable := 0, 0
+	for _, err := range errs {
+		switch {
+		case errors.Is(err, ErrTimeout):
+			timeouts++
+		case errors.Is(err, ErrUnreachable), errors.Is(err, ErrRateLimited):
+			unreachable++
+		}
+	}
+	switch {
+	case len(errs) > 0 && timeouts == len(errs):
+		return ErrTimeout
+	case timeouts+unreachable > 0:
+		return ErrUnreachable
+	default:
+		return ErrBadResponse
+	}
+}
+
+// decided 剩余 pending 个来源全部投给第二名也无法反超时返回 true
+func decided(votes map[string]int, pending int) bool {
+	first, second := 0, 0
+	for _, n := range votes {
+		switch {
+		case n > first:
+			first, second = n, first
+		case n > second:
+			second = n
+		}
+	}
+	return first > second+pending
+}
+
+// tally 统计票数，平票时按来源顺序选择
+func (c *Consensus) tally(votes map[string]int, order []Source) {
+	rank := make(map[string]int, len(order))
+	for i, s := range order {
+		rank[s.Name] = i
+	}
+	sort.SliceStable(c.Answers, func(i, j int) bool {
+		return rank[c.Answers[i].Source] < rank[c.Answers[j].Source]
+	})
+
+	for _, a := range c.Answers {
+		if a.IP == "" {
+			continue
+		}
+		c.Valid++
+		if votes[a.IP] > c.Votes {
+			c.IP, c.Votes = a.IP, votes[a.IP]
+		}
+	}
+	c.Disagree = len(votes) > 1
+}
+
+// addr 来源在指定地址族下的查询地址
+func (s Source) addr(family string) string {
+	if family == FamilyIPv6 {
+		return s.IPv6
+	}
+	return s.IPv4
+}
+
+// viaProxy 来源能否经代理查询
+//
+// 不能经代理的来源会暴露本机出口，经代理时跳过
+func (s Source) viaProxy() bool {
+	switch s.Kind {
+	case "http":
+		return true
+	case "dns":
+		return dnsViaProxy()
+	}
+	return false
+}
+
+// validate 校验答案是指定地址族的 IP
+//
+// 以 "<" 开头的答案是 HTML (没有 Content-Type 的门户页面)
+func validate(s, family string) (string, error) {
+	s = strings.TrimSpace(s)
+	addr := net.ParseIP(s)
+	if addr == nil {
+		if strings.HasPrefix(s, "<") {
+			return "", &ProviderError{Kind: ErrBadResponse, Detail: "HTML page, captive portal?"}
+		}
+		if len(s) > 40 {
+			s = s[:40] + "…"
+		}
+		return "", &ProviderError{Kind: ErrBadResponse, Detail: fmt.Sprintf("not an IP address: %q", s)}
+	}
+	if (addr.To4() != nil) != (family == FamilyIPv4) {
+		return "", &ProviderError{Kind: ErrBadResponse, Detail: "wrong address family: " + s}
+	}
+	return addr.String(), nil
+}
+
+// checkIPv6 本机能否访问 IPv6 互联网
+//
+// UDP connect 只做路由选择，不发送数据包: 没有 IPv6 默认路由时立即失败，
+// 内核选出的源地址不是全局地址 (只有 fe80::/ULA) 时同样不可能成功。
+// 设置了代理时由代理访问，不做检查
+func checkIPv6() error {
+	conn, err := net.Dial("udp6", ipv6Probe)
+	if err != nil {
+		return &ProviderError{Kind: ErrUnreachable, Detail: "no IPv6 default route", Err: err}
+	}
+	defer conn.Close()
+
+	source := conn.LocalAddr().(*net.UDPAddr).IP.String()
+	if ip.Classify(source) != ip.TypePublic {
+		return &ProviderError{Kind: ErrUnreachable, Detail: "no global IPv6 address, source would be " + source}
+	}
+	return nil
+}
+
+// dialNetwork 地址族对应的网络名，如 ("tcp", ipv6) -> "tcp6"
+func dialNetwork(proto, family string) string {
+	if family == FamilyIPv6 {
+		return proto + "6"
+	}
+	return proto + "4"
+}
+
+// queryHTTP 从 HTTP 回显服务获取 IP (纯文本响应)
+func queryHTTP(ctx context.Context, family, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := send(client(family), req.URL.Host, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// 回显服务只返回一个 IP，限制读取长度防止门户页面
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return "", requestError(ctx, req.URL.Host, err)
+	}
+	return string(body), nil
+}
+
+// queryOpenDNS 向 OpenDNS 查询 myip.opendns.com
+//
+// OpenDNS 对这个名称返回查询者的地址 (A 或 AAAA 取决于查询类型)
+func queryOpenDNS(ctx context.Context, family, server string) (string, error) {
+	network := "ip4"
+	if family == FamilyIPv6 {
+		network = "ip6"
+	}
+	ips, err := resolverFor(server).LookupIP(ctx, network, "myip.opendns.com.")
+	if err != nil {
+		return "", dnsError(ctx, err)
+	}
+	if len(ips) == 0 {
+		return "", &ProviderError{Provider: "DNS", Kind: ErrBadResponse, Detail: "no answer"}
+	}
+	return ips[0].String(), nil
+}
+
+// queryGoogleDNS 向 Google 权威服务器查询 o-o.myaddr.l.google.com TXT
+//
+// 可能同时返回 "edns0-client-subnet ..." 记录，只取能解析为 IP 的那条
+func queryGoogleDNS(ctx context.Context, family, server string) (string, error) {
+	txts, err := resolverFor(server).LookupTXT(ctx, "o-o.myaddr.l.google.com.")
+	if err != nil {
+		return "", dnsError(ctx, err)
+	}
+	for _, txt := range txts {
+		if net.ParseIP(strings.TrimSpace(txt)) != nil {
+			return txt, nil
+		}
+	}
+	return "", &ProviderError{Provider: "DNS", Kind: ErrBadResponse, Detail: "no address in TXT answer"}
+}
+
+// querySTUN 通过 STUN Binding 获取映射地址
+func querySTUN(ctx context.Context, family, server string) (string, error) {
+	addr, err := stun.Binding(ctx, server, dialNetwork("udp", family))
+	if err != nil {
+		return "", requestError(ctx, "STUN", err)
+	}
+	return addr.IP.String(), nil
+}
@@ -5,29 +5,29 @@ DNS 解析模块
 
 CLI Guidelines 原则 - 超时控制:
 - 所有 DNS 查询都有 5 秒超时
+- 超时和服务器故障 (SERVFAIL 等) 重试，NXDOMAIN 不重试
+- 调用方的 ctx 可以提前取消 (Ctrl+C) 或设置更短的期限 (--deadline)
 - 避免慢速 DNS 服务器导致程序挂起
 */
 package network
 
 import (
 	"context"
-	"fmt"
-	"net"
 	"strings"
 	"time"
 )
 
-// defaultTimeout 所有网络操作的默认超时
+// defaultTimeout 单个网络操作的超时上限，ctx 的期限更早时以 ctx 为准
 const defaultTimeout = 5 * time.Second
 
 // LookupIPv4 查询域名的 IPv4 地址 (A 记录)
-func LookupIPv4(host string) (string, error) {
-	return lookupIP(host, "ip4")
+func LookupIPv4(ctx context.Context, host string) (string, error) {
+	return lookupIP(ctx, host, "ip4")
 }
 
 // LookupIPv6 查询域名的 IPv6 地址 (AAAA 记录)
-func LookupIPv6(host string) (string, error) {
-	return lookupIP(host, "ip6")
+func LookupIPv6(ctx context.Context, host string) (string, error) {
+	return lookupIP(ctx, host, "ip6")
 }
 
 // lookupIP 执行 DNS 查询
@@ -39,20 +39,43 @@ func LookupIPv6(host string) (string, error) {
 // 返回:
 //   - 成功: 第一个 IP 地址
 //   - 失败: 错误信息
-func lookupIP(host, network string) (string, error) {
+//
+// 同一域名的并发查询只发出一次 (flight.go)
+func lookupIP(ctx context.Context, host, network string) (string, error) {
+	v, err := flights.do(ctx, "dns:"+network+":"+host, func(ctx context.Context) (any, error) {
+		return queryIP(ctx, host, network)
+	})
+	record(ctx, ProviderDNS, resolverName())
+	ip, _ := v.(string)
+	return ip, err
+}
+
+// queryIP 向解析器查询，返回第一个地址
+func queryIP(ctx context.Context, host, network string) (string, error) {
+	var ip string
+	err := withRetry(ctx, func() error {
+		var err error
+		ip, err = queryIPOnce(ctx, host, network)
+		return err
+	})
+	return ip, err
+}
+
+// queryIPOnce 一次 A/AAAA 查询
+func queryIPOnce(ctx context.Context, host, network string) (string, error) {
 	// 创建带超时的 context
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
-	// 使用系统默认 DNS 解析器
-	ips, err := net.DefaultResolver.LookupIP(ctx, network, host)
+	// 系统解析器，开启代理 DNS 时经代理查询
+	ips, err := resolver().LookupIP(ctx, network, host)
 	if err != nil {
-		return "", fmt.Errorf("DNS lookup failed: %w", err)
+		return "", dnsError(ctx, err)
 	}
 
 	// 检查是否有结果
 	if len(ips) == 0 {
-		return "", fmt.Errorf("no %s address found", strings.ToUpper(network))
+		return "", &ProviderError{Provider: "DNS", Kind: ErrLookupFailed, Detail: "no " + strings.ToUpper(network) + " address found"}
 	}
 
 	// 返回第一个结果
@@ -60,13 +83,51 @@ func lookupIP(host, network string) (string, error) {
 }
 
 // LookupCNAME 查询 CNAME 记录
-func LookupCNAME(host string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+func LookupCNAME(ctx context.Context, host string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
-	cname, err := net.DefaultResolver.LookupCNAME(ctx, host)
+	cname, err := resolver().LookupCNAME(ctx, host)
 	if err != nil {
-		return "", fmt.Errorf("CNAME lookup failed: %w", err)
+		return "", dnsError(ctx, err)
 	}
 	return cname, nil
 }
+
+// LookupPTR 反向解析 IP 地址的主机名 (PTR 记录)
+//
+// 返回第一个名称，去掉末尾的点；同一地址的并发查询只发出一次
+func LookupPTR(ctx context.Context, addr string) (string, error) {
+	v, err := flights.do(ctx, "ptr:"+addr, func(ctx context.Context) (any, error) {
+		return queryPTR(ctx, addr)
+	})
+	record(ctx, ProviderDNS, resolverName())
+	name, _ := v.(string)
+	return name, err
+}
+
+// queryPTR 向解析器查询 PTR 记录
+func queryPTR(ctx context.Context, addr string) (string, error) {
+	var name string
+	err := withRetry(ctx, func() error {
+		var err error
+		name, err = queryPTROnce(ctx, addr)
+		return err
+	})
+	return name, err
+}
+
+// queryPTROnce 一次 PTR 查询
+func queryPTROnce(ctx context.Context, addr string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	names, err := resolver().LookupAddr(ctx, addr)
+	if err != nil {
+		return "", dnsError(ctx, err)
+	}
+	if len(names) == 0 {
+		return "", &ProviderError{Provider: "DNS", Kind: ErrLookupFailed, Detail: "no PTR record found"}
+	}
+	return strings.TrimSuffix(names[0], "."), nil
+}
@@ -0,0 +1,181 @@
+/*
+网络错误分类
+
+所有查询函数的错误都能用 errors.Is 归入以下类别之一，
+调用方据此决定重试、退出码和提示，而不必匹配错误字符串:
+
+	ErrTimeout       超时 (单个操作超时或 ctx 到期)
+	ErrRateLimited   服务限流 (HTTP 429)
+	ErrUnreachable   连不上服务 (拒绝连接、无路由、5xx、DNS 服务器故障)
+	ErrBadResponse   服务有响应但内容不对 (门户页面、格式错误、非预期状态码)
+	ErrLookupFailed  服务明确回答 "查不到" (NXDOMAIN、私网地址没有地理位置)
+
+具体的服务名和原因放在 ProviderError 里。
+调用方按 Ctrl+C 取消时返回 context.Canceled，不归入任何类别。
+*/
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 错误类别
+var (
+	ErrTimeout      = errors.New("timeout")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrUnreachable  = errors.New("unreachable")
+	ErrBadResponse  = errors.New("bad response")
+	ErrLookupFailed = errors.New("lookup failed")
+)
+
+// ProviderError 某个服务的一次失败
+//
+// errors.Is(err, ErrTimeout) 等匹配 Kind，errors.Unwrap 链上还有底层错误 Err
+type ProviderError struct {
+	Provider string // 服务名，如 "ip-api.com"、"api.ipify.org"、"DNS"
+	Kind     error  // 错误类别 (ErrTimeout 等)
+	Detail   string // 补充说明，如 "status 503"
+	Err      error  // 底层错误，可能为 nil
+
+	RetryAfter time.Duration // 限流时服务要求的等待时间，0 表示未说明
+}
+
+// Error 形如 "ip-api.com: rate limited (status 429)"
+func (e *ProviderError) Error() string {
+	msg := e.Reason()
+	if e.Provider != "" {
+		msg = e.Provider + ": " + msg
+	}
+	return msg
+}
+
+// Reason 不含服务名的描述 (服务名已在别处显示时使用)
+func (e *ProviderError) Reason() string {
+	if e.Detail == "" {
+		return e.Kind.Error()
+	}
+	return e.Kind.Error() + " (" + e.Detail + ")"
+}
+
+// Unwrap 同时暴露类别和底层错误
+func (e *ProviderError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// reason 错误的描述，ProviderError 省略服务名
+func reason(err error) string {
+	var pe *ProviderError
+	if errors.As(err, &pe) {
+		return pe.Reason()
+	}
+	return err.Error()
+}
+
+// retryable 是否值得重试: 超时、连不上、限流
+//
+// 内容错误和 "查不到" 重试也不会变，服务的域名不存在 (NXDOMAIN) 同理
+func retryable(err error) bool {
+	var de *net.DNSError
+	if errors.As(err, &de) && de.IsNotFound {
+		return false
+	}
+	return errors.Is(err, ErrTimeout) || errors.Is(err, ErrUnreachable) || errors.Is(err, ErrRateLimited)
+}
+
+// requestError 分类 HTTP 请求 (Do) 的错误
+//
+// 调用方取消时原样返回 ctx.Err()
+func requestError(ctx context.Context, provider string, err error) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return ctx.Err()
+	}
+	var ne net.Error
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+		return &ProviderError{Provider: provider, Kind: ErrTimeout, Err: err}
+	}
+	return &ProviderError{Provider: provider, Kind: ErrUnreachable, Detail: rootCause(err), Err: err}
+}
+
+// statusError 分类非 2xx 状态码，2xx 返回 nil
+func statusError(provider string, resp *http.Response) error {
+	code := resp.StatusCode
+	detail := "status " + strconv.Itoa(code)
+	switch {
+	case code >= 200 && code <= 299:
+		return nil
+	case code == http.StatusTooManyRequests:
+		return &ProviderError{Provider: provider, Kind: ErrRateLimited, Detail: detail, RetryAfter: retryAfter(resp.Header)}
+	case code >= 500:
+		return &ProviderError{Provider: provider, Kind: ErrUnreachable, Detail: detail}
+	default:
+		return &ProviderError{Provider: provider, Kind: ErrBadResponse, Detail: detail}
+	}
+}
+
+// retryAfter 限流响应要求的等待时间
+//
+// 标准的 Retry-After (秒)，或 ip-api.com 的 X-Ttl (距离配额重置的秒数)
+func retryAfter(h http.Header) time.Duration {
+	for _, name := range []string{"Retry-After", "X-Ttl"} {
+		if n, err := strconv.Atoi(h.Get(name)); err == nil && n >= 0 {
+			return time.Duration(n) * time.Second
+		}
+	}
+	return 0
+}
+
+// portalError 响应看起来是强制门户 (captive portal) 的页面时返回 ErrBadResponse
+//
+// 酒店、机场等网络的门户把请求重定向到登录页，或者直接以 200 返回 HTML，
+// 此时回显服务的 "答案" 和地理位置 API 的 "JSON" 都不可信
+func portalError(provider string, req *http.Request, resp *http.Response) error {
+	if resp.Request != nil && resp.Request.URL.Host != req.URL.Host {
+		return &ProviderError{Provider: provider, Kind: ErrBadResponse, Detail: "redirected to " + resp.Request.URL.Host + ", captive portal?"}
+	}
+	if strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		return &ProviderError{Provider: provider, Kind: ErrBadResponse, Detail: "HTML page, captive portal?"}
+	}
+	return nil
+}
+
+// dnsError 分类 DNS 查询的错误
+func dnsError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return ctx.Err()
+	}
+	pe := &ProviderError{Provider: "DNS", Kind: ErrUnreachable, Detail: rootCause(err), Err: err}
+	var de *net.DNSError
+	if errors.As(err, &de) {
+		pe.Detail = de.Err
+		switch {
+		case de.IsNotFound:
+			pe.Kind = ErrLookupFailed
+		case de.IsTimeout:
+			pe.Kind = ErrTimeout
+		}
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		pe.Kind = ErrTimeout
+	}
+	return pe
+}
+
+// rootCause 错误链最里层的描述，如 "connection refused"
+func rootCause(err error) string {
+	for {
+		next := errors.Unwrap(err)
+		if next == nil {
+			return err.Error()
+		}
+		err = next
+	}
+}
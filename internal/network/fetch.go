@@ -5,117 +5,189 @@ HTTP 请求模块
 
 CLI Guidelines 原则 - 健壮性:
 - 所有请求有超时控制
-- 区分不同类型的错误 (超时、网络错误、API 错误)
+- 区分不同类型的错误 (超时、限流、连不上、响应错误、查不到，见 errors.go)
+- 超时、连不上和限流自动重试 (retry.go)
 - 错误信息用户友好
 
 数据源:
-- 公网 IP: icanhazip.com (简单可靠)
+- 公网 IP: 多个回显来源投票 (consensus.go)
 - 地理位置: ip-api.com (免费，无需 API 密钥)
 */
 package network
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
 )
 
 // FetchPublicIPv4 获取本机公网 IPv4
-func FetchPublicIPv4() (string, error) {
-	return fetchIP("https://ipv4.icanhazip.com")
-}
-
-// FetchPublicIPv6 获取本机公网 IPv6
-func FetchPublicIPv6() (string, error) {
-	return fetchIP("https://ipv6.icanhazip.com")
-}
-
-// fetchIP 从指定 URL 获取 IP 地址
 //
-// 这些服务返回纯文本格式的 IP 地址
-func fetchIP(url string) (string, error) {
-	// 创建带超时的 context
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
-
-	// 创建请求
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+// 多个来源投票决定，见 DetectPublicIP
+func FetchPublicIPv4(ctx context.Context) (string, error) {
+	c, err := DetectPublicIP(ctx, FamilyIPv4, false)
 	if err != nil {
 		return "", err
 	}
+	return c.IP, nil
+}
 
-	// 执行请求
-	resp, err := http.DefaultClient.Do(req)
+// FetchPublicIPv6 获取本机公网 IPv6
+func FetchPublicIPv6(ctx context.Context) (string, error) {
+	c, err := DetectPublicIP(ctx, FamilyIPv6, false)
 	if err != nil {
-		// 区分超时和其他网络错误
-		if ctx.Err() == context.DeadlineExceeded {
-			return "", fmt.Errorf("timeout")
-		}
-		return "", fmt.Errorf("network error: %w", err)
+		return "", err
 	}
-	defer resp.Body.Close()
+	return c.IP, nil
+}
 
-	// 检查 HTTP 状态码
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("server returned %d", resp.StatusCode)
-	}
+// geoAPI ip-api.com 的地址，变量便于测试时指向本地服务
+var geoAPI = "http://ip-api.com"
 
-	// 读取响应体
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
+// geoFields ip-api.com 字段掩码 (见 GeoInfo)
+const geoFields = 18610713
 
-	return strings.TrimSpace(string(body)), nil
-}
+// geoBatchSize ip-api.com 批量接口单次最多 100 个 IP
+const geoBatchSize = 100
 
 // FetchGeoInfo 查询 IP 地理位置
 //
 // 使用 ip-api.com 服务
 // 限制: 每分钟 45 次请求 (非商业用途足够)
-func FetchGeoInfo(ip string) (*GeoInfo, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+// 同一 IP 的并发查询只发出一次 (flight.go)
+func FetchGeoInfo(ctx context.Context, ip string) (*GeoInfo, error) {
+	v, err := flights.do(ctx, "geo:"+strings.TrimSpace(ip), func(ctx context.Context) (any, error) {
+		return fetchGeoInfo(ctx, ip)
+	})
+	record(ctx, ProviderGeo, "")
+	info, _ := v.(*GeoInfo)
+	return info, err
+}
+
+// fetchGeoInfo 请求 ip-api.com 单个查询接口，失败时按 withRetry 重试
+func fetchGeoInfo(ctx context.Context, ip string) (*GeoInfo, error) {
+	var info *GeoInfo
+	err := withRetry(ctx, func() error {
+		var err error
+		info, err = fetchGeoOnce(ctx, ip)
+		return err
+	})
+	return info, err
+}
+
+// fetchGeoOnce 一次单个查询
+func fetchGeoOnce(ctx context.Context, ip string) (*GeoInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
 	// 构造 API URL
-	url := fmt.Sprintf("http://ip-api.com/json/%s?fields=18600473", strings.TrimSpace(ip))
+	url := fmt.Sprintf("%s/json/%s?fields=%d", geoAPI, strings.TrimSpace(ip), geoFields)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := send(HTTPClient(), ProviderGeo, req)
 	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			return nil, fmt.Errorf("timeout (>5s)")
-		}
-		return nil, fmt.Errorf("API unreachable: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error (status %d)", resp.StatusCode)
-	}
-
 	// 解析 JSON 响应
 	var info GeoInfo
 	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
-		return nil, fmt.Errorf("invalid response: %w", err)
+		return nil, &ProviderError{Provider: ProviderGeo, Kind: ErrBadResponse, Detail: "invalid JSON", Err: err}
 	}
 
 	// 检查 API 级别的错误
 	if info.IsFailed() {
 		info.Message = friendlyError(info.Message)
-		return &info, fmt.Errorf("lookup failed: %s", info.Message)
+		return &info, &ProviderError{Provider: ProviderGeo, Kind: ErrLookupFailed, Detail: info.Message}
 	}
 
 	return &info, nil
 }
 
+// FetchGeoBatch 批量查询 IP 地理位置，返回 IP -> 结果
+//
+// 使用 ip-api.com 的批量接口，每次最多 100 个 IP
+// 限制: 每分钟 15 次请求，适合连接列表这类一次查很多 IP 的场景
+// 查询失败的 IP (如私网地址) 也会返回，Status 为 "fail"
+func FetchGeoBatch(ctx context.Context, ips []string) (map[string]*GeoInfo, error) {
+	results := make(map[string]*GeoInfo, len(ips))
+	for start := 0; start < len(ips); start += geoBatchSize {
+		end := min(start+geoBatchSize, len(ips))
+		chunk := ips[start:end]
+		err := withRetry(ctx, func() error {
+			return fetchGeoChunk(ctx, chunk, results)
+		})
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// fetchGeoChunk 执行一次批量查询
+func fetchGeoChunk(ctx context.Context, ips []string, results map[string]*GeoInfo) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	body, err := json.Marshal(ips)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/batch?fields=%d", geoAPI, geoFields)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := send(HTTPClient(), ProviderGeo, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var infos []GeoInfo
+	if err := json.NewDecoder(resp.Body).Decode(&infos); err != nil {
+		return &ProviderError{Provider: ProviderGeo, Kind: ErrBadResponse, Detail: "invalid JSON", Err: err}
+	}
+	for i := range infos {
+		info := &infos[i]
+		if info.IsFailed() {
+			info.Message = friendlyError(info.Message)
+		}
+		results[info.Query] = info
+	}
+	return nil
+}
+
+// send 发送请求，把传输错误、非 2xx 状态码和门户页面转换为 ProviderError
+//
+// 成功时调用方负责关闭 resp.Body
+func send(c *http.Client, provider string, req *http.Request) (*http.Response, error) {
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, requestError(req.Context(), provider, err)
+	}
+	if err := statusError(provider, resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if err := portalError(provider, req, resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
 // friendlyError 将 API 错误转换为用户友好的描述
 func friendlyError(msg string) string {
 	switch msg {
@@ -0,0 +1,88 @@
+/*
+合并重复请求 (singleflight)
+
+TUI 列表、批量查询和连接视图可能同时查询同一个 IP 或域名。
+相同 key 的并发调用共享一次进行中的请求，结果分发给所有等待者:
+
+	geo:<ip>               地理位置
+	dns:<ip4|ip6>:<host>   A/AAAA 解析
+	ptr:<ip>               反向解析
+	public:<family>[:all]  本机公网 IP (:all 表示等待所有来源)
+
+请求使用不随调用方取消的 context，一个调用方按 Ctrl+C 只会让它自己停止等待，
+不会让其他等待者失败。发起请求的调用方的期限 (--deadline) 仍然保留:
+期限过后请求不再重试，后台不会留下继续运行的请求 (单个操作另有 defaultTimeout 上限)。
+请求结束后立即移除，不缓存结果。
+*/
+package network
+
+import (
+	"context"
+	"sync"
+)
+
+// call 一次进行中的请求
+type call struct {
+	done chan struct{} // 请求结束时关闭
+	val  any
+	err  error
+}
+
+// group 按 key 合并并发请求
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// flights 本包所有可合并的请求共用
+var flights group
+
+// do 执行 fn，相同 key 的并发调用共享同一次执行
+//
+// 调用方的 ctx 结束时立即返回 ctx.Err()，fn 继续为其他等待者运行。
+// 加入已有请求的调用方在 ctx 的 Trace 中记为 Shared
+func (g *group) do(ctx context.Context, key string, fn func(ctx context.Context) (any, error)) (any, error) {
+	g.mu.Lock()
+	c, ok := g.calls[key]
+	if ok {
+		if t := traceFrom(ctx); t != nil {
+			t.Shared = true
+		}
+	} else {
+		if g.calls == nil {
+			g.calls = make(map[string]*call)
+		}
+		c = &call{done: make(chan struct{})}
+		g.calls[key] = c
+		fctx, cancel := flightContext(ctx)
+		go g.run(fctx, cancel, key, c, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// flightContext 请求使用的 context: 不随调用方取消，但保留调用方的期限
+func flightContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	fctx := context.WithoutCancel(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(fctx, deadline)
+	}
+	return fctx, func() {}
+}
+
+// run 执行请求，结束后移除 key 并通知等待者
+func (g *group) run(ctx context.Context, cancel context.CancelFunc, key string, c *call, fn func(ctx context.Context) (any, error)) {
+	defer cancel()
+	c.val, c.err = fn(ctx)
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(c.done)
+}
@@ -0,0 +1,77 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// 调用方取消不影响请求，但请求在调用方的期限结束
+func TestFlightKeepsCallerDeadline(t *testing.T) {
+	var g group
+	deadline := time.Now().Add(200 * time.Millisecond)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	ended := make(chan error, 1)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	_, err := g.do(ctx, "k", func(ctx context.Context) (any, error) {
+		if d, ok := ctx.Deadline(); !ok || !d.Equal(deadline) {
+			t.Errorf("flight deadline = %v (%v), want %v", d, ok, deadline)
+		}
+		<-ctx.Done()
+		ended <- context.Cause(ctx)
+		return nil, ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("caller err = %v, want context.Canceled", err)
+	}
+
+	select {
+	case err := <-ended:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("flight ended with %v, want the caller's deadline", err)
+		}
+		if time.Now().Before(deadline) {
+			t.Error("flight stopped before the deadline")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("flight still running after the caller's deadline")
+	}
+}
+
+// 期限过后请求不再重试
+func TestFlightRetryStopsAtDeadline(t *testing.T) {
+	orig := retries.Load()
+	retries.Store(10)
+	t.Cleanup(func() { retries.Store(orig) })
+
+	var g group
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	finished := make(chan int, 1)
+	g.do(ctx, "k", func(ctx context.Context) (any, error) {
+		attempts := 0
+		err := withRetry(ctx, func() error {
+			attempts++
+			return &ProviderError{Kind: ErrTimeout}
+		})
+		finished <- attempts
+		return nil, err
+	})
+
+	select {
+	case attempts := <-finished:
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("retried for %v (%d attempts) past a 300ms deadline", elapsed, attempts)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("flight still retrying after the caller's deadline")
+	}
+}
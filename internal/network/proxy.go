@@ -0,0 +1,208 @@
+/*
+代理支持
+
+所有出站 HTTP 请求 (公网 IP、地理位置、Webhook、OUI 下载) 都经过 HTTPClient()。
+
+代理地址:
+
+	http://[user:pass@]host:port      HTTP CONNECT 代理
+	https://[user:pass@]host:port
+	socks5://[user:pass@]host:port    SOCKS5，目标主机名交给代理解析
+	socks5h://[user:pass@]host:port   同上，并且 DNS 查询也经代理发送
+
+未设置时沿用 HTTP_PROXY / HTTPS_PROXY / NO_PROXY 环境变量；这些都没有时使用 ALL_PROXY
+(Go 的 HTTP 客户端本身不读取 ALL_PROXY，这里按 curl 的习惯补上)。
+
+DNS 经代理 (远程解析) 只支持 SOCKS5: 查询以 TCP 发往 proxyDNS 服务器。
+代理所在网络通常访问不到本机 resolv.conf 里的内网解析器，所以使用单独的服务器。
+
+HTTP 请求经过代理时 (无论来自 --proxy、配置文件还是环境变量)，无法经代理发送的
+公网 IP 来源 (STUN，以及未启用代理 DNS 时的 DNS 来源) 会被跳过并在结果中注明，
+否则它们会暴露本机的出口地址。
+*/
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// DefaultProxyDNS 经代理查询 DNS 时默认使用的服务器
+const DefaultProxyDNS = "1.1.1.1:53"
+
+// proxyConfig 当前的代理设置
+var proxyConfig struct {
+	sync.RWMutex
+	url   *url.URL     // nil 表示使用环境变量
+	socks *socksDialer // SOCKS5 代理时非 nil
+	dns   string       // 经代理查询 DNS 的服务器，空表示本地解析
+
+	clients map[string]*http.Client // 按地址族共享的客户端 (client.go)，代理变化时重建
+}
+
+// 环境变量中的代理，变量便于测试时替换
+var (
+	// envProxy HTTP 客户端使用的 HTTPS_PROXY/HTTP_PROXY/NO_PROXY
+	envProxy = http.ProxyFromEnvironment
+	getenv   = os.Getenv
+)
+
+// sourceProbe 判断环境变量代理是否生效的请求: HTTP 来源都是 https
+var sourceProbe = &http.Request{URL: &url.URL{Scheme: "https", Host: "api.ipify.org"}}
+
+// SetProxy 设置所有出站请求使用的代理，空字符串恢复为环境变量
+//
+// socks5h:// 同时开启 DNS 经代理 (DefaultProxyDNS)
+func SetProxy(raw string) error {
+	proxyConfig.Lock()
+	defer proxyConfig.Unlock()
+
+	if raw == "" {
+		raw = allProxy()
+	}
+	if raw == "" {
+		proxyConfig.url, proxyConfig.socks, proxyConfig.dns = nil, nil, ""
+		resetClients()
+		return nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid proxy URL %q", raw)
+	}
+
+	var socks *socksDialer
+	dns := ""
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+	case "socks5", "socks5h":
+		socks = &socksDialer{proxy: hostPort(u, "1080")}
+		if u.User != nil {
+			socks.user = u.User.Username()
+			socks.password, _ = u.User.Password()
+		}
+		if strings.EqualFold(u.Scheme, "socks5h") {
+			dns = DefaultProxyDNS
+		}
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q (use http, https, socks5 or socks5h)", u.Scheme)
+	}
+
+	proxyConfig.url, proxyConfig.socks, proxyConfig.dns = u, socks, dns
+	resetClients()
+	return nil
+}
+
+// SetProxyDNS 经 SOCKS5 代理向 server 发送 DNS 查询，空字符串关闭
+//
+// 需要先用 SetProxy 设置 SOCKS5 代理
+func SetProxyDNS(server string) error {
+	proxyConfig.Lock()
+	defer proxyConfig.Unlock()
+
+	if server == "" {
+		proxyConfig.dns = ""
+		return nil
+	}
+	if proxyConfig.socks == nil {
+		return fmt.Errorf("DNS through the proxy needs a socks5:// proxy")
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+	}
+	proxyConfig.dns = server
+	return nil
+}
+
+// ProxyString 当前代理 (隐去密码)，未设置时为空
+func ProxyString() string {
+	proxyConfig.RLock()
+	defer proxyConfig.RUnlock()
+	if proxyConfig.url == nil {
+		return ""
+	}
+	return proxyConfig.url.Redacted()
+}
+
+// allProxy HTTPS_PROXY/HTTP_PROXY 都没有生效时的 ALL_PROXY
+func allProxy() string {
+	if u, err := envProxy(sourceProbe); err == nil && u != nil {
+		return ""
+	}
+	if v := getenv("ALL_PROXY"); v != "" {
+		return v
+	}
+	return getenv("all_proxy")
+}
+
+// proxied HTTP 来源的请求是否经过代理 (--proxy、配置文件或环境变量)
+func proxied() bool {
+	proxyConfig.RLock()
+	explicit := proxyConfig.url != nil
+	proxyConfig.RUnlock()
+	if explicit {
+		return true
+	}
+	u, err := envProxy(sourceProbe)
+	return err == nil && u != nil
+}
+
+// dnsViaProxy 是否经代理查询 DNS
+func dnsViaProxy() bool {
+	proxyConfig.RLock()
+	defer proxyConfig.RUnlock()
+	return proxyConfig.dns != ""
+}
+
+// resolver 域名解析使用的解析器
+//
+// 开启代理 DNS 时，所有查询以 TCP 经 SOCKS5 代理发往代理 DNS 服务器
+func resolver() *net.Resolver {
+	proxyConfig.RLock()
+	socks, server := proxyConfig.socks, proxyConfig.dns
+	proxyConfig.RUnlock()
+
+	if server == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return socks.DialContext(ctx, "tcp", server)
+		},
+	}
+}
+
+// resolverFor 直接查询指定 DNS 服务器的解析器
+//
+// 开启代理 DNS 时同样经 SOCKS5 代理 (TCP)
+func resolverFor(server string) *net.Resolver {
+	proxyConfig.RLock()
+	socks, viaProxy := proxyConfig.socks, proxyConfig.dns != ""
+	proxyConfig.RUnlock()
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, proto, _ string) (net.Conn, error) {
+			if viaProxy {
+				return socks.DialContext(ctx, "tcp", server)
+			}
+			var d net.Dialer
+			return d.DialContext(ctx, proto, server)
+		},
+	}
+}
+
+// hostPort URL 的 host:port，没有端口时使用 port
+func hostPort(u *url.URL, port string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
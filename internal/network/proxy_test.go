@@ -0,0 +1,126 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// setEnv 替换环境变量代理: proxy 为 HTTPS_PROXY，env 为其余变量
+func setEnv(t *testing.T, proxy string, env map[string]string) {
+	t.Helper()
+	origProxy, origGetenv := envProxy, getenv
+	t.Cleanup(func() {
+		envProxy, getenv = origProxy, origGetenv
+		SetProxy("")
+	})
+
+	envProxy = func(*http.Request) (*url.URL, error) {
+		if proxy == "" {
+			return nil, nil
+		}
+		return url.Parse(proxy)
+	}
+	getenv = func(key string) string { return env[key] }
+}
+
+func TestProxied(t *testing.T) {
+	tests := []struct {
+		name  string
+		flag  string
+		proxy string
+		env   map[string]string
+		want  bool
+	}{
+		{"direct", "", "", nil, false},
+		{"--proxy", "http://proxy.example:3128", "", nil, true},
+		{"HTTPS_PROXY", "", "http://proxy.example:3128", nil, true},
+		{"ALL_PROXY", "", "", map[string]string{"ALL_PROXY": "socks5://proxy.example:1080"}, true},
+		{"all_proxy", "", "", map[string]string{"all_proxy": "socks5://proxy.example:1080"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.proxy, tt.env)
+			if err := SetProxy(tt.flag); err != nil {
+				t.Fatal(err)
+			}
+			if got := proxied(); got != tt.want {
+				t.Errorf("proxied() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// HTTPS_PROXY 优先于 ALL_PROXY: 此时 ALL_PROXY 不生效
+func TestAllProxyPrecedence(t *testing.T) {
+	setEnv(t, "http://proxy.example:3128", map[string]string{"ALL_PROXY": "socks5://other.example:1080"})
+	if err := SetProxy(""); err != nil {
+		t.Fatal(err)
+	}
+	if got := ProxyString(); got != "" {
+		t.Errorf("ALL_PROXY applied over HTTPS_PROXY: %s", got)
+	}
+
+	setEnv(t, "", map[string]string{"ALL_PROXY": "socks5://other.example:1080"})
+	if err := SetProxy(""); err != nil {
+		t.Fatal(err)
+	}
+	if got := ProxyString(); got != "socks5://other.example:1080" {
+		t.Errorf("ProxyString() = %q, want the ALL_PROXY value", got)
+	}
+}
+
+// 经代理时 STUN 和 DNS 来源不查询，在结果中标为跳过
+func TestDetectSkipsUnproxiedSources(t *testing.T) {
+	answer := func(ip string) func(context.Context, string, string) (string, error) {
+		return func(context.Context, string, string) (string, error) { return ip, nil }
+	}
+	leak := func(context.Context, string, string) (string, error) {
+		t.Error("source queried outside the proxy")
+		return "", errors.New("leak")
+	}
+
+	activeMu.Lock()
+	orig := active
+	active = []Source{
+		{Name: "http-a", Kind: "http", IPv4: "https://a.example", query: answer("203.0.113.7")},
+		{Name: "http-b", Kind: "http", IPv4: "https://b.example", query: answer("203.0.113.7")},
+		{Name: "dns", Kind: "dns", IPv4: "192.0.2.53:53", query: leak},
+		{Name: "stun", Kind: "stun", IPv4: "192.0.2.3:3478", query: leak},
+	}
+	activeMu.Unlock()
+	t.Cleanup(func() {
+		activeMu.Lock()
+		active = orig
+		activeMu.Unlock()
+	})
+
+	setEnv(t, "http://proxy.example:3128", nil)
+	c, err := detectPublicIP(context.Background(), FamilyIPv4, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.IP != "203.0.113.7" || c.Votes != 2 {
+		t.Errorf("ip = %s with %d votes, want 203.0.113.7 with 2", c.IP, c.Votes)
+	}
+	skipped := map[string]bool{}
+	for _, a := range c.Answers {
+		if a.Skipped {
+			skipped[a.Source] = true
+		}
+	}
+	if len(c.Answers) != 4 || !skipped["dns"] || !skipped["stun"] {
+		t.Errorf("answers = %+v, want dns and stun marked skipped", c.Answers)
+	}
+
+	// 只剩不能经代理的来源
+	activeMu.Lock()
+	active = active[2:]
+	activeMu.Unlock()
+	c, err = detectPublicIP(context.Background(), FamilyIPv4, true)
+	if !errors.Is(err, ErrLookupFailed) || len(c.Answers) != 2 {
+		t.Errorf("err = %v, answers = %+v", err, c.Answers)
+	}
+}
@@ -6,11 +6,14 @@
 设计决策:
 - 返回 "Not Detected" 而非空字符串，便于 UI 显示
 - 返回 "Not Applicable" 表示不适用 (如 IPv4 地址查询 IPv6)
-- 隐藏错误细节，简化调用方代码
+- 显示值总是可用；"Not Detected" 时同时返回原因，调用方据此决定退出码
 */
 package network
 
-import "net"
+import (
+	"context"
+	"net"
+)
 
 // ResolveIPv4 获取目标的 IPv4 地址
 //
@@ -19,30 +22,22 @@ import "net"
 // - target 是 IPv4: 直接返回
 // - target 是 IPv6: 返回 "Not Applicable"
 // - target 是域名: DNS 解析
-func ResolveIPv4(target string) string {
+func ResolveIPv4(ctx context.Context, target string) (string, error) {
 	// 空目标 = 查询本机
 	if target == "" {
-		ip, err := FetchPublicIPv4()
-		if err != nil {
-			return "Not Detected"
-		}
-		return ip
+		return detected(FetchPublicIPv4(ctx))
 	}
 
 	// 检查是否为 IP 地址
 	if ip := net.ParseIP(target); ip != nil {
 		if ip.To4() != nil {
-			return target // 是 IPv4，直接返回
+			return target, nil // 是 IPv4，直接返回
 		}
-		return "Not Applicable" // 是 IPv6，不适用
+		return "Not Applicable", nil // 是 IPv6，不适用
 	}
 
 	// 是域名，DNS 解析
-	ip, err := LookupIPv4(target)
-	if err != nil {
-		return "Not Detected"
-	}
-	return ip
+	return detected(LookupIPv4(ctx, target))
 }
 
 // ResolveIPv6 获取目标的 IPv6 地址
@@ -52,25 +47,25 @@ func ResolveIPv4(target string) string {
 // - target 是 IPv6: 直接返回
 // - target 是 IPv4: 返回 "Not Applicable"
 // - target 是域名: DNS 解析
-func ResolveIPv6(target string) string {
+func ResolveIPv6(ctx context.Context, target string) (string, error) {
 	if target == "" {
-		ip, err := FetchPublicIPv6()
-		if err != nil {
-			return "Not Detected"
-		}
-		return ip
+		return detected(FetchPublicIPv6(ctx))
 	}
 
 	if ip := net.ParseIP(target); ip != nil {
 		if ip.To4() == nil {
-			return target // 是 IPv6，直接返回
+			return target, nil // 是 IPv6，直接返回
 		}
-		return "Not Applicable" // 是 IPv4，不适用
+		return "Not Applicable", nil // 是 IPv4，不适用
 	}
 
-	ip, err := LookupIPv6(target)
+	return detected(LookupIPv6(ctx, target))
+}
+
+// detected 查询失败时显示值为 "Not Detected"
+func detected(ip string, err error) (string, error) {
 	if err != nil {
-		return "Not Detected"
+		return "Not Detected", err
 	}
-	return ip
+	return ip, nil
 }
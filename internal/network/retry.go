@@ -0,0 +1,93 @@
+/*
+重试
+
+超时、连不上和限流 (retryable) 会重试，间隔指数增长并加随机抖动，
+避免批量查询里的多个请求在同一时刻一起重试:
+
+	第 n 次重试前等待 [d/2, d)，d = retryBase * 2^(n-1)，不超过 retryMax
+
+限流响应带有等待时间 (Retry-After / X-Ttl) 时按它等待；
+要求的时间超过 retryMax 或 ctx 的剩余时间时不再重试，直接返回限流错误。
+*/
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRetries 默认的重试次数 (不含第一次尝试)
+const DefaultRetries = 2
+
+// 重试间隔
+const (
+	retryBase = 250 * time.Millisecond
+	retryMax  = 4 * time.Second
+)
+
+// retries 当前的重试次数
+var retries atomic.Int32
+
+func init() {
+	retries.Store(DefaultRetries)
+}
+
+// SetRetries 设置可重试错误的重试次数，0 表示不重试
+func SetRetries(n int) error {
+	if n < 0 || n > 10 {
+		return fmt.Errorf("retries must be between 0 and 10, got %d", n)
+	}
+	retries.Store(int32(n))
+	return nil
+}
+
+// withRetry 执行 fn，可重试的错误按退避间隔重试
+//
+// 返回最后一次尝试的错误 (等待期间 ctx 结束时也是)
+func withRetry(ctx context.Context, fn func() error) error {
+	n := int(retries.Load())
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= n || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		wait := backoff(attempt + 1)
+		if hint := retryHint(err); hint > 0 {
+			wait = hint
+		}
+		if wait > retryMax {
+			return err
+		}
+		if end, ok := ctx.Deadline(); ok && time.Until(end) < wait {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff 第 n 次重试前的等待时间
+func backoff(n int) time.Duration {
+	d := min(retryBase<<(n-1), retryMax)
+	return d/2 + rand.N(d/2)
+}
+
+// retryHint 限流响应要求的等待时间
+func retryHint(err error) time.Duration {
+	var pe *ProviderError
+	if errors.As(err, &pe) {
+		return pe.RetryAfter
+	}
+	return 0
+}
@@ -0,0 +1,209 @@
+/*
+SOCKS5 客户端 (RFC 1928，用户名密码认证 RFC 1929)
+
+只实现 CONNECT。目标是主机名时原样交给代理 (ATYP 0x03)，
+由代理解析，本机不发出 DNS 查询。
+
+	问候: | 05 | 方法数 | 方法... |         -> | 05 | 选中的方法 |
+	认证: | 01 | 用户名长度 | 用户名 | 密码长度 | 密码 | -> | 01 | 状态 |
+	连接: | 05 | 01 | 00 | ATYP | 地址 | 端口 | -> | 05 | 结果 | 00 | ATYP | 绑定地址 | 端口 |
+*/
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// SOCKS5 常量
+const (
+	socksVersion     = 5
+	socksAuthNone    = 0x00
+	socksAuthPass    = 0x02
+	socksAuthNoMatch = 0xFF
+	socksCmdConnect  = 0x01
+	socksAtypIPv4    = 0x01
+	socksAtypDomain  = 0x03
+	socksAtypIPv6    = 0x04
+)
+
+// RFC 1928 第 6 节 结果码
+var socksReplies = map[byte]string{
+	1: "general SOCKS server failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// socksDialer 经 SOCKS5 代理建立 TCP 连接
+type socksDialer struct {
+	proxy    string // 代理地址 host:port
+	user     string
+	password string
+}
+
+// DialContext 连接代理并请求 CONNECT 到 target (host:port)
+func (d *socksDialer) DialContext(ctx context.Context, network, target string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("SOCKS5 proxy: network %s not supported", network)
+	}
+
+	var nd net.Dialer
+	conn, err := nd.DialContext(ctx, "tcp", d.proxy)
+	if err != nil {
+		return nil, fmt.Errorf("SOCKS5 proxy: %w", err)
+	}
+
+	// 握手期间遵守 ctx 的截止时间和取消
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	err = d.handshake(conn, target)
+	stop()
+	conn.SetDeadline(time.Time{})
+
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("SOCKS5 proxy: %w", err)
+	}
+	return conn, nil
+}
+
+// handshake 协商认证方法并发送 CONNECT
+func (d *socksDialer) handshake(conn net.Conn, target string) error {
+	methods := []byte{socksAuthNone}
+	if d.user != "" {
+		methods = append(methods, socksAuthPass)
+	}
+	greeting := append([]byte{socksVersion, byte(len(methods))}, methods...)
+	if _, err := conn.Write(greeting); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != socksVersion {
+		return errors.New("not a SOCKS5 server")
+	}
+	switch reply[1] {
+	case socksAuthNone:
+	case socksAuthPass:
+		if err := d.authenticate(conn); err != nil {
+			return err
+		}
+	case socksAuthNoMatch:
+		return errors.New("no acceptable authentication method (credentials required?)")
+	default:
+		return fmt.Errorf("unsupported authentication method %d", reply[1])
+	}
+
+	req, err := connectRequest(target)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	return readConnectReply(conn)
+}
+
+// authenticate 用户名密码认证 (RFC 1929)
+func (d *socksDialer) authenticate(conn net.Conn) error {
+	if len(d.user) > 255 || len(d.password) > 255 {
+		return errors.New("username or password too long")
+	}
+	req := []byte{1, byte(len(d.user))}
+	req = append(req, d.user...)
+	req = append(req, byte(len(d.password)))
+	req = append(req, d.password...)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[1] != 0 {
+		return errors.New("authentication failed")
+	}
+	return nil
+}
+
+// connectRequest 生成 CONNECT 请求，主机名不在本地解析
+func connectRequest(target string) ([]byte, error) {
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid port %q", portStr)
+	}
+
+	req := []byte{socksVersion, socksCmdConnect, 0}
+	if addr := net.ParseIP(host); addr != nil {
+		if v4 := addr.To4(); v4 != nil {
+			req = append(append(req, socksAtypIPv4), v4...)
+		} else {
+			req = append(append(req, socksAtypIPv6), addr.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return nil, errors.New("host name too long")
+		}
+		req = append(req, socksAtypDomain, byte(len(host)))
+		req = append(req, host...)
+	}
+	return binary.BigEndian.AppendUint16(req, uint16(port)), nil
+}
+
+// readConnectReply 读取 CONNECT 响应，跳过绑定地址
+func readConnectReply(conn net.Conn) error {
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return err
+	}
+	if head[1] != 0 {
+		if msg, ok := socksReplies[head[1]]; ok {
+			return errors.New(msg)
+		}
+		return fmt.Errorf("connect failed (reply %d)", head[1])
+	}
+
+	var skip int
+	switch head[3] {
+	case socksAtypIPv4:
+		skip = net.IPv4len
+	case socksAtypIPv6:
+		skip = net.IPv6len
+	case socksAtypDomain:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return err
+		}
+		skip = int(n[0])
+	default:
+		return fmt.Errorf("invalid address type %d in reply", head[3])
+	}
+	_, err := io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
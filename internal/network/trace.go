@@ -0,0 +1,61 @@
+/*
+查询来源追踪 (provenance)
+
+调用方用 WithTrace 把 *Trace 放进 ctx，查询函数返回前在其中记录由谁回答:
+
+	var t network.Trace
+	ip, err := network.LookupIPv4(network.WithTrace(ctx, &t), "example.com")
+	// t.Provider == "dns", t.Resolver == "system"
+
+与 net/http/httptrace 的做法相同，不改变函数签名。
+记录发生在调用方所在的 goroutine，合并的请求 (flight.go) 也能正确记录 Shared。
+*/
+package network
+
+import "context"
+
+// Provider 名称
+const (
+	ProviderDNS       = "dns"        // 域名解析
+	ProviderConsensus = "consensus"  // 公网 IP 多来源投票
+	ProviderGeo       = "ip-api.com" // 地理位置
+)
+
+// Trace 一次查询的来源
+type Trace struct {
+	Provider string   // ProviderDNS 等
+	Resolver string   // DNS 解析器: "system" (resolv.conf) 或经代理的服务器
+	Sources  []string // 公网 IP: 投票给结果的来源
+	Shared   bool     // 与同时进行的相同查询共享了一次请求，没有单独发出
+}
+
+// traceKey ctx 中 *Trace 的 key
+type traceKey struct{}
+
+// WithTrace 返回记录到 t 的 ctx
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// traceFrom ctx 中的 *Trace，没有时为 nil
+func traceFrom(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
+
+// record 在 ctx 的 Trace 中记录 provider 和 resolver
+func record(ctx context.Context, provider, resolver string) {
+	if t := traceFrom(ctx); t != nil {
+		t.Provider, t.Resolver = provider, resolver
+	}
+}
+
+// resolverName 当前域名解析使用的解析器 (见 resolver)
+func resolverName() string {
+	proxyConfig.RLock()
+	defer proxyConfig.RUnlock()
+	if proxyConfig.dns != "" {
+		return proxyConfig.dns + " via proxy"
+	}
+	return "system"
+}
@@ -1,15 +1,17 @@
 /*
 Package network 提供网络请求功能
 
-依赖: internal/ip (仅此一个)
+依赖: internal/ip, internal/stun
 
 本包负责所有网络 I/O 操作:
 - DNS 解析
-- HTTP 请求获取公网 IP
+- 多来源 (HTTP/DNS/STUN) 投票获取公网 IP
 - 调用地理位置 API
+- 出站代理 (HTTP/SOCKS5) 与经代理的 DNS
 
 CLI Guidelines 原则 - 超时控制:
 - 所有网络请求必须有超时
+- 所有函数接受 ctx，调用方可以随时取消 (Ctrl+C) 或设置总期限
 - 避免程序在网络问题时无限挂起
 - 给用户明确的反馈
 */
@@ -18,7 +20,7 @@ package network
 // GeoInfo 地理位置信息
 //
 // 对应 ip-api.com 的 JSON 响应
-// fields=18600473 返回以下字段
+// fields=18610713 返回以下字段
 type GeoInfo struct {
 	Status     string `json:"status"`     // "success" 或 "fail"
 	Message    string `json:"message"`    // 失败时的错误信息
@@ -26,6 +28,8 @@ type GeoInfo struct {
 	RegionName string `json:"regionName"` // 地区/省份
 	City       string `json:"city"`       // 城市
 	ISP        string `json:"isp"`        // 互联网服务提供商
+	AS         string `json:"as"`         // 自治系统，如 "AS15169 Google LLC"
+	Query      string `json:"query"`      // 查询的 IP (批量查询时用于对应结果)
 	Mobile     bool   `json:"mobile"`     // 是否为移动网络
 	Proxy      bool   `json:"proxy"`      // 是否为代理/VPN
 	Hosting    bool   `json:"hosting"`    // 是否为数据中心
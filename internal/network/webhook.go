@@ -0,0 +1,51 @@
+/*
+Webhook 模块
+
+以 JSON 格式 POST 事件到用户配置的 URL (ipq watch 使用)。
+
+设计决策:
+- 超时与其他网络操作一致
+- 任何 2xx 状态码视为成功
+- 连不上、5xx 和 429 按 withRetry 重试 (事件内容相同，接收方重复收到无害)
+*/
+package network
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// PostJSON 将 payload 编码为 JSON 并 POST 到 url
+func PostJSON(ctx context.Context, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return withRetry(ctx, func() error {
+		return post(ctx, url, body)
+	})
+}
+
+// post 发送一次请求
+func post(ctx context.Context, url string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ipq")
+
+	resp, err := HTTPClient().Do(req)
+	if err != nil {
+		return requestError(ctx, "webhook", err)
+	}
+	defer resp.Body.Close()
+
+	return statusError("webhook", resp)
+}
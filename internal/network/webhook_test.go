@@ -0,0 +1,102 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// receiver 记录收到的请求，前 fail 次返回 status
+type receiver struct {
+	calls  atomic.Int32
+	fail   int32
+	status int
+
+	contentType string
+	body        map[string]any
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	n := r.calls.Add(1)
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	r.contentType = req.Header.Get("Content-Type")
+	r.body = nil
+	json.NewDecoder(req.Body).Decode(&r.body)
+	if n <= r.fail {
+		w.WriteHeader(r.status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type testEvent struct {
+	Event string `json:"event"`
+	IPv4  string `json:"ipv4"`
+}
+
+func TestPostJSONPayload(t *testing.T) {
+	r := &receiver{}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	if err := PostJSON(context.Background(), srv.URL, testEvent{"ip_changed", "203.0.113.7"}); err != nil {
+		t.Fatal(err)
+	}
+	if r.calls.Load() != 1 {
+		t.Errorf("got %d requests, want 1", r.calls.Load())
+	}
+	if r.contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", r.contentType)
+	}
+	if r.body["event"] != "ip_changed" || r.body["ipv4"] != "203.0.113.7" {
+		t.Errorf("payload = %v", r.body)
+	}
+}
+
+func TestPostJSONRetries5xx(t *testing.T) {
+	r := &receiver{fail: 2, status: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	if err := PostJSON(context.Background(), srv.URL, testEvent{Event: "ip_changed"}); err != nil {
+		t.Fatalf("want success after retries, got %v", err)
+	}
+	if got := r.calls.Load(); got != 3 {
+		t.Errorf("got %d requests, want 3", got)
+	}
+}
+
+func TestPostJSONGivesUpAfterRetries(t *testing.T) {
+	r := &receiver{fail: 100, status: http.StatusBadGateway}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	err := PostJSON(context.Background(), srv.URL, testEvent{Event: "ip_changed"})
+	if !errors.Is(err, ErrUnreachable) {
+		t.Fatalf("want ErrUnreachable, got %v", err)
+	}
+	if got := r.calls.Load(); got != DefaultRetries+1 {
+		t.Errorf("got %d requests, want %d", got, DefaultRetries+1)
+	}
+}
+
+func TestPostJSONNoRetry4xx(t *testing.T) {
+	r := &receiver{fail: 100, status: http.StatusBadRequest}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	err := PostJSON(context.Background(), srv.URL, testEvent{Event: "ip_changed"})
+	if !errors.Is(err, ErrBadResponse) {
+		t.Fatalf("want ErrBadResponse, got %v", err)
+	}
+	if got := r.calls.Load(); got != 1 {
+		t.Errorf("got %d requests, want 1 (no retry on 4xx)", got)
+	}
+}
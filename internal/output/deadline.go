@@ -0,0 +1,45 @@
+/*
+查询期限 (--deadline)
+
+期限是一次查询 (FetchResult) 的总预算，依次分给各阶段:
+
+	解析 (IPv4 与 IPv6 并发，DNS 或公网 IP) -> 地理位置 (-d)
+
+每个阶段分到 "剩余时间 / 剩余阶段数"，提前完成的阶段把省下的时间留给后面的阶段，
+所以 IPv6 不通时也不会把地理位置查询的时间全部耗掉。
+
+未设置期限时只有单个网络操作的超时 (5 秒)。
+*/
+package output
+
+import (
+	"context"
+	"time"
+)
+
+// deadline 每次查询的总期限，0 表示不限制
+var deadline time.Duration
+
+// SetDeadline 设置每次查询的总期限 (--deadline)，0 表示不限制
+func SetDeadline(d time.Duration) {
+	deadline = d
+}
+
+// withDeadline 为一次查询加上总期限
+func withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, deadline)
+}
+
+// stage 为剩余 n 个阶段中的当前阶段分配期限
+//
+// ctx 没有期限或只剩最后一个阶段时，不再缩短
+func stage(ctx context.Context, n int) (context.Context, context.CancelFunc) {
+	end, ok := ctx.Deadline()
+	if !ok || n <= 1 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Until(end)/time.Duration(n))
+}
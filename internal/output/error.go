@@ -14,42 +14,276 @@ CLI Guidelines 原则 - Human-first Design:
 	  Content: some random text
 
 	  → Copy a valid IP (8.8.8.8) or domain (google.com)
+
+CLI Guidelines 原则 - 机器可读:
+- -o json/yaml 时错误同样是结构化的 (PrintError)，输出到 stderr，stdout 保持为数据:
+
+	{"error": {"code": 4, "title": "Could not detect IP address", "reason": "...", "suggestion": "..."}}
+
+- 样式只在输出时添加，Error() 是纯文本，可以写入日志或再包装
 */
 package output
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"reflect"
 	"strings"
+
+	"github/shawn/ip-tool/internal/network"
+
+	"gopkg.in/yaml.v3"
 )
 
-// NewError 创建格式化的错误信息
+// Error 面向用户的错误
+//
+// 可用 errors.As 取出，Unwrap 返回 Cause (如 network.ErrTimeout)
+type Error struct {
+	Code       int    // 退出码，0 表示由调用方按 Cause 决定 (cli.ExitCode)
+	Title      string // 简洁描述问题 (必填)
+	Reason     string // 为什么出错，可为空
+	Suggestion string // 如何解决，可为空
+	Cause      error  // 底层错误，可为 nil
+}
+
+// NewError 创建面向用户的错误
 //
 // 参数:
 //   - title: 错误标题 (必填)
 //   - reason: 错误原因/详情 (可选，空则不显示)
 //   - suggestion: 解决建议 (可选，空则不显示)
-func NewError(title, reason, suggestion string) error {
+func NewError(title, reason, suggestion string) *Error {
+	return &Error{Title: title, Reason: reason, Suggestion: suggestion}
+}
+
+// WithCode 指定退出码
+func (e *Error) WithCode(code int) *Error {
+	e.Code = code
+	return e
+}
+
+// WithCause 记录底层错误，用于 errors.Is 和退出码
+func (e *Error) WithCause(err error) *Error {
+	e.Cause = err
+	return e
+}
+
+// Error 纯文本，形如 "Invalid clipboard content: Content: some random text"
+func (e *Error) Error() string {
+	if e.Reason == "" {
+		return e.Title
+	}
+	return e.Title + ": " + e.Reason
+}
+
+// Unwrap 返回底层错误
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// ExitCode 指定的退出码，0 表示未指定
+func (e *Error) ExitCode() int {
+	return e.Code
+}
+
+// Render 带样式的多行格式 (见文件头示例)
+func (e *Error) Render() string {
 	var b strings.Builder
 
 	// 换行开头，与上文分隔
 	b.WriteString("\n")
 
 	// 错误标题 (红色加粗，带 ✗ 前缀)
-	b.WriteString(StyleError.Render("✗ " + title))
+	b.WriteString(StyleError.Render("✗ " + e.Title))
 	b.WriteString("\n")
 
 	// 错误原因 (灰色斜体，缩进显示)
-	if reason != "" {
-		b.WriteString(StyleHint.Render("  " + reason))
+	if e.Reason != "" {
+		b.WriteString(StyleHint.Render("  " + e.Reason))
 		b.WriteString("\n")
 	}
 
 	// 解决建议 (亮蓝色，带 → 前缀)
-	if suggestion != "" {
+	if e.Suggestion != "" {
 		b.WriteString("\n")
-		b.WriteString(StyleSuggestion.Render("  → " + suggestion))
+		b.WriteString(StyleSuggestion.Render("  → " + e.Suggestion))
 		b.WriteString("\n")
 	}
 
-	return fmt.Errorf("%s", b.String())
+	return b.String()
+}
+
+// ErrorOutput JSON/YAML 格式的错误输出 (stderr)
+type ErrorOutput struct {
+	Error ErrorBody `json:"error" yaml:"error"`
+}
+
+// ErrorBody ErrorOutput 中的 "error" 对象
+type ErrorBody struct {
+	Code       int    `json:"code" yaml:"code"`
+	Title      string `json:"title" yaml:"title"`
+	Reason     string `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Suggestion string `json:"suggestion,omitempty" yaml:"suggestion,omitempty"`
+	Cause      string `json:"cause,omitempty" yaml:"cause,omitempty"`
+}
+
+// PrintError 把错误输出到 stderr
+//
+// code 为最终的退出码；JSON/YAML 格式输出 {"error": {...}}，其他格式输出带样式的文本
+func PrintError(err error, code int, format Format) {
+	e := AsError(err)
+	if format.IsMachine() {
+		body := ErrorBody{Code: code, Title: e.Title, Reason: e.Reason, Suggestion: e.Suggestion}
+		// AsError 包装的错误 (Cause 就是 err) 内容已在 title/reason 中
+		if e.Cause != nil && e.Cause != err && e.Cause.Error() != e.Reason {
+			body.Cause = e.Cause.Error()
+		}
+		encodeTo(os.Stderr, ErrorOutput{Error: body}, format)
+		return
+	}
+	fmt.Fprintln(os.Stderr, e.Render())
+}
+
+// encodeTo 以 JSON、NDJSON 或 YAML 格式写入 w
+//
+// NDJSON 每个值一行，切片的每个元素各占一行
+func encodeTo(w io.Writer, v any, format Format) error {
+	switch format {
+	case FormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		return enc.Encode(v)
+	case FormatNDJSON:
+		enc := json.NewEncoder(w)
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice {
+			for i := 0; i < rv.Len(); i++ {
+				if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return enc.Encode(v)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ") // 缩进便于人类阅读
+	return enc.Encode(v)
+}
+
+// AsError 将任意错误转换为 *Error
+//
+// 查询失败和批量失败带上原因和建议，其他错误以其文本为标题
+func AsError(err error) *Error {
+	var (
+		e     *Error
+		le    *LookupError
+		batch *BatchError
+	)
+	switch {
+	case errors.As(err, &e):
+		return e
+	case errors.As(err, &batch):
+		return &Error{Title: batch.Error(), Reason: firstReason(batch.Errs), Suggestion: suggestion(err), Cause: err}
+	case errors.As(err, &le):
+		return &Error{Title: le.Reason, Reason: le.detail(), Suggestion: suggestion(err), Cause: err}
+	}
+	return &Error{Title: err.Error(), Cause: err}
+}
+
+// firstReason 第一个失败目标的描述，其余以数量表示
+func firstReason(errs []error) string {
+	if len(errs) == 0 {
+		return ""
+	}
+	msg := errs[0].Error()
+	if len(errs) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(errs)-1)
+	}
+	return msg
+}
+
+// suggestion 按失败类别给出建议
+func suggestion(err error) string {
+	switch {
+	case errors.Is(err, network.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return "Allow more time (--deadline 10s) or more attempts (--retries 4)"
+	case errors.Is(err, network.ErrUnreachable), errors.Is(err, network.ErrBadResponse):
+		return "Check the network connection: ipq doctor"
+	case errors.Is(err, network.ErrRateLimited):
+		return "The service limits requests per minute, wait and try again"
+	case errors.Is(err, network.ErrLookupFailed):
+		return "Check the spelling of the target"
+	}
+	return ""
+}
+
+// LookupError 一次查询失败
+//
+// Errs 保留各地址族的原因，可用 errors.Is 匹配 network.ErrTimeout 等 (决定退出码)
+type LookupError struct {
+	Target string
+	Reason string  // 与 Result.Error 相同，如 "Could not detect IP address"
+	Errs   []error // 原因，相同的只保留一个
+}
+
+// Error 形如 "example.invalid: Could not detect IP address: DNS: lookup failed (no such host)"
+func (e *LookupError) Error() string {
+	return e.Target + ": " + e.Reason + strings.TrimPrefix(e.detail(), e.Target)
+}
+
+// detail 目标及原因，形如 "example.invalid: DNS: lookup failed (no such host)"
+func (e *LookupError) detail() string {
+	msg := e.Target
+	for _, err := range e.Errs {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			msg += ": " + err.Error()
+		}
+	}
+	return msg
+}
+
+// Unwrap 返回各地址族的原因
+func (e *LookupError) Unwrap() []error {
+	return e.Errs
+}
+
+// NewLookupError 创建查询失败错误
+//
+// 去掉 nil 和描述相同的原因 (IPv4、IPv6 通常因为同一个原因失败)
+func NewLookupError(target, reason string, errs ...error) *LookupError {
+	e := &LookupError{Target: target, Reason: reason}
+	seen := make(map[string]bool)
+	for _, err := range errs {
+		if err != nil && !seen[err.Error()] {
+			seen[err.Error()] = true
+			e.Errs = append(e.Errs, err)
+		}
+	}
+	return e
+}
+
+// BatchError 批量查询中有目标失败
+type BatchError struct {
+	Failed  int     // 失败的目标数
+	Total   int     // 已完成的目标数
+	Stopped bool    // 失败数达到上限后停止 (--fail-fast / --max-failures)
+	Errs    []error // 每个失败目标的 *LookupError
+}
+
+// Error 形如 "2 of 10 lookups failed"
+func (e *BatchError) Error() string {
+	msg := fmt.Sprintf("%d of %d lookups failed", e.Failed, e.Total)
+	if e.Stopped {
+		msg += ", stopped"
+	}
+	return msg
+}
+
+// Unwrap 返回每个失败目标的错误
+func (e *BatchError) Unwrap() []error {
+	return e.Errs
 }
@@ -5,7 +5,9 @@ CLI Guidelines 原则 - 多种输出格式:
 - TUI: 交互式界面，适合人类用户
 - Text: 简单文本，适合非交互式环境
 - JSON: 机器可读，便于程序解析
+- NDJSON: 每行一个 JSON 对象，批量查询边查边输出
 - YAML: 机器可读，更易人类阅读
+- CSV/TSV: 表格，直接导入电子表格
 - Quiet: 最小输出，便于管道处理
 
 CLI Guidelines 原则 - 可组合性:
@@ -15,29 +17,36 @@ CLI Guidelines 原则 - 可组合性:
 package output
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github/shawn/ip-tool/internal/ip"
 	"github/shawn/ip-tool/internal/network"
-
-	"gopkg.in/yaml.v3"
 )
 
 // Format 输出格式类型
 type Format string
 
 const (
-	FormatTUI   Format = "tui"   // 交互式 TUI
-	FormatText  Format = "text"  // 人类可读文本
-	FormatJSON  Format = "json"  // 机器可读 JSON
-	FormatYAML  Format = "yaml"  // 机器可读 YAML
-	FormatQuiet Format = "quiet" // 最小输出
+	FormatTUI    Format = "tui"    // 交互式 TUI
+	FormatText   Format = "text"   // 人类可读文本
+	FormatJSON   Format = "json"   // 机器可读 JSON
+	FormatYAML   Format = "yaml"   // 机器可读 YAML
+	FormatNDJSON Format = "ndjson" // 每行一个 JSON (别名 jsonl)
+	FormatCSV    Format = "csv"    // 表格 (RFC 4180)
+	FormatTSV    Format = "tsv"    // 制表符分隔的表格
+	FormatQuiet  Format = "quiet"  // 最小输出
 )
 
 // Result 查询结果
 type Result struct {
+	SchemaVersion int `json:"schema_version" yaml:"schema_version"`
+
 	Target  string  `json:"target" yaml:"target"`
 	IPv4    string  `json:"ipv4" yaml:"ipv4"`
 	IPv6    string  `json:"ipv6" yaml:"ipv6"`
@@ -45,6 +54,30 @@ type Result struct {
 	Detail  *Detail `json:"detail,omitempty" yaml:"detail,omitempty"`
 	Success bool    `json:"success" yaml:"success"`
 	Error   string  `json:"error,omitempty" yaml:"error,omitempty"`
+
+	Durations Durations              `json:"duration_ms" yaml:"duration_ms"`
+	Errors    map[string]*StageError `json:"errors,omitempty" yaml:"errors,omitempty"`   // 失败的阶段 (见 stage.go)
+	Sources   map[string]*Source     `json:"sources,omitempty" yaml:"sources,omitempty"` // 各阶段的来源
+
+	causes []error // 失败时各地址族的原因 (见 Err)
+}
+
+// Err 查询失败时返回 *LookupError，成功时为 nil
+func (r *Result) Err() error {
+	if r.Success {
+		return nil
+	}
+	return NewLookupError(r.Target, r.Error, r.causes...)
+}
+
+// Durations 各阶段耗时 (毫秒)，未执行的阶段为 0
+//
+// IPv4、IPv6 与地理位置部分重叠，Total 是整次查询的墙钟时间
+type Durations struct {
+	IPv4  int64 `json:"ipv4" yaml:"ipv4"`
+	IPv6  int64 `json:"ipv6" yaml:"ipv6"`
+	Geo   int64 `json:"geo,omitempty" yaml:"geo,omitempty"`
+	Total int64 `json:"total" yaml:"total"`
 }
 
 // Detail 详细信息
@@ -59,10 +92,15 @@ type Detail struct {
 }
 
 // FetchResult 获取查询结果
-func FetchResult(target string, withDetail bool) *Result {
+//
+// IPv4 和 IPv6 并发解析；地理位置优先查询 IPv4，IPv4 有结果就开始，不等 IPv6。
+// ctx 取消时进行中的请求立即停止；设置了期限 (SetDeadline) 时按阶段分配，见 deadline.go
+func FetchResult(ctx context.Context, target string, withDetail bool) *Result {
+	begin := time.Now()
 	result := &Result{
-		Target:  target,
-		Success: true,
+		SchemaVersion: SchemaVersion,
+		Target:        target,
+		Success:       true,
 	}
 
 	// 空目标表示查询本机
@@ -70,9 +108,57 @@ func FetchResult(target string, withDetail bool) *Result {
 		result.Target = "(localhost)"
 	}
 
-	// 获取 IP
-	result.IPv4 = network.ResolveIPv4(target)
-	result.IPv6 = network.ResolveIPv6(target)
+	ctx, cancel := withDeadline(ctx)
+	defer cancel()
+	stages := 1
+	if withDetail {
+		stages = 2
+	}
+
+	// 获取 IP: 两个地址族并发
+	rctx, cancelResolve := stage(ctx, stages)
+	defer cancelResolve()
+	var log stageLog
+	stage4, stage6 := StageDNSv4, StageDNSv6
+	if target == "" {
+		stage4, stage6 = StagePublicIPv4, StagePublicIPv6
+	}
+	v4, v6 := make(chan struct{}), make(chan struct{})
+	var err4, err6 error
+	go func() {
+		defer close(v4)
+		result.Durations.IPv4 = log.run(rctx, stage4, func(ctx context.Context) error {
+			result.IPv4, err4 = network.ResolveIPv4(ctx, target)
+			return err4
+		})
+	}()
+	go func() {
+		defer close(v6)
+		result.Durations.IPv6 = log.run(rctx, stage6, func(ctx context.Context) error {
+			result.IPv6, err6 = network.ResolveIPv6(ctx, target)
+			return err6
+		})
+	}()
+
+	// 获取详情: 与 IPv6 解析重叠进行
+	var geo sync.WaitGroup
+	detail := func(addr string) {
+		geo.Add(1)
+		go func() {
+			defer geo.Done()
+			result.Durations.Geo = log.run(ctx, StageGeo, func(ctx context.Context) error {
+				return result.fetchDetail(ctx, addr)
+			})
+		}()
+	}
+	<-v4
+	if withDetail && isValidIP(result.IPv4) {
+		detail(result.IPv4)
+	}
+	<-v6
+	if withDetail && !isValidIP(result.IPv4) && isValidIP(result.IPv6) {
+		detail(result.IPv6)
+	}
 
 	// 检测IP类型
 	switch {
@@ -80,33 +166,43 @@ func FetchResult(target string, withDetail bool) *Result {
 		result.Type = string(ip.Classify(result.IPv4))
 	case isValidIP(result.IPv6):
 		result.Type = string(ip.Classify(result.IPv6))
+	case errors.Is(rctx.Err(), context.DeadlineExceeded):
+		result.Success = false
+		result.Error = fmt.Sprintf("Deadline exceeded (%s)", deadline)
+		result.causes = []error{context.DeadlineExceeded}
 	default:
 		result.Success = false
 		result.Error = "Could not detect IP address"
+		result.causes = []error{err4, err6}
 	}
 
-	// 获取详情
-	if withDetail && result.Success {
-		targetIP := result.IPv4
-		if !isValidIP(targetIP) {
-			targetIP = result.IPv6
-		}
-		if isValidIP(targetIP) {
-			if info, err := network.FetchGeoInfo(targetIP); err == nil {
-				result.Detail = &Detail{
-					ISP:     info.ISP,
-					Country: info.Country,
-					Region:  info.RegionName,
-					City:    info.City,
-					Mobile:  info.Mobile,
-					Proxy:   info.Proxy,
-					Hosting: info.Hosting,
-				}
-			}
-		}
+	geo.Wait()
+	result.Errors, result.Sources = log.errors, log.sources
+	result.Durations.Total = time.Since(begin).Milliseconds()
+	return result
+}
+
+// fetchDetail 查询 addr 的地理位置，失败时 Detail 为空并返回原因
+func (r *Result) fetchDetail(ctx context.Context, addr string) error {
+	info, err := network.FetchGeoInfo(ctx, addr)
+	if err != nil {
+		return err
 	}
+	r.Detail = NewDetail(info)
+	return nil
+}
 
-	return result
+// NewDetail 由地理位置查询结果构造 Detail
+func NewDetail(info *network.GeoInfo) *Detail {
+	return &Detail{
+		ISP:     info.ISP,
+		Country: info.Country,
+		Region:  info.RegionName,
+		City:    info.City,
+		Mobile:  info.Mobile,
+		Proxy:   info.Proxy,
+		Hosting: info.Hosting,
+	}
 }
 
 // isValidIP 检查是否为有效 IP 值
@@ -114,15 +210,22 @@ func isValidIP(s string) bool {
 	return s != "" && s != "Not Detected" && s != "Not Applicable"
 }
 
-// Print 输出结果
-func Print(target string, detail bool, format Format) error {
-	result := FetchResult(target, detail)
+// Print 查询并输出结果
+func Print(ctx context.Context, target string, detail bool, format Format) error {
+	return PrintResult(FetchResult(ctx, target, detail), detail, format)
+}
 
+// PrintResult 输出已获取的结果
+func PrintResult(result *Result, detail bool, format Format) error {
 	switch format {
 	case FormatJSON:
 		return printJSON(result)
 	case FormatYAML:
 		return printYAML(result)
+	case FormatNDJSON:
+		return Encode(result, FormatNDJSON)
+	case FormatCSV, FormatTSV:
+		return printTable(result, format)
 	case FormatQuiet:
 		return printQuiet(result)
 	default:
@@ -132,47 +235,61 @@ func Print(target string, detail bool, format Format) error {
 
 // printJSON 输出 JSON 格式
 func printJSON(r *Result) error {
-	enc := json.NewEncoder(os.Stdout)
-	enc.SetIndent("", "  ") // 缩进便于人类阅读
-	return enc.Encode(r)
+	return Encode(r, FormatJSON)
 }
 
 // printYAML 输出 YAML 格式
 func printYAML(r *Result) error {
-	enc := yaml.NewEncoder(os.Stdout)
-	enc.SetIndent(2)
-	return enc.Encode(r)
+	return Encode(r, FormatYAML)
+}
+
+// Encode 以 JSON 或 YAML 格式输出任意值到 stdout
+//
+// 供子命令 (history 等) 输出机器可读结果，其他格式按 JSON 处理
+func Encode(v any, format Format) error {
+	return encodeTo(os.Stdout, v, format)
+}
+
+// IsMachine 是否为机器可读格式 (JSON/NDJSON/YAML)
+func (f Format) IsMachine() bool {
+	return f == FormatJSON || f == FormatNDJSON || f == FormatYAML
 }
 
 // printQuiet 静默输出
 //
 // 例如: ipq google.com -q | xargs ping
 func printQuiet(r *Result) error {
+	var b strings.Builder
 	if isValidIP(r.IPv4) {
-		fmt.Println(r.IPv4)
+		fmt.Fprintln(&b, r.IPv4)
 	}
 	if isValidIP(r.IPv6) {
-		fmt.Println(r.IPv6)
+		fmt.Fprintln(&b, r.IPv6)
 	}
-	return nil
+	_, err := os.Stdout.WriteString(b.String())
+	return err
 }
 
 // printText 输出文本格式
+//
+// 整个结果一次写入 stdout，写入失败 (如管道已关闭) 时返回错误
 func printText(r *Result, detail bool) error {
-	fmt.Printf("Target: %s\n", r.Target)
-	fmt.Printf("IPv4: %s\n", formatIP(r.IPv4))
-	fmt.Printf("IPv6: %s\n", formatIP(r.IPv6))
+	var b strings.Builder
+	fmt.Fprintf(&b, "Target: %s\n", r.Target)
+	fmt.Fprintf(&b, "IPv4: %s\n", formatIP(r.IPv4))
+	fmt.Fprintf(&b, "IPv6: %s\n", formatIP(r.IPv6))
 
 	if detail && r.Detail != nil {
-		fmt.Println("---")
-		fmt.Printf("ISP: %s\n", r.Detail.ISP)
-		fmt.Printf("Location: %s, %s, %s\n",
+		fmt.Fprintln(&b, "---")
+		fmt.Fprintf(&b, "ISP: %s\n", r.Detail.ISP)
+		fmt.Fprintf(&b, "Location: %s, %s, %s\n",
 			r.Detail.City, r.Detail.Region, r.Detail.Country)
-		fmt.Printf("Mobile: %v | Proxy: %v | Hosting: %v\n",
+		fmt.Fprintf(&b, "Mobile: %v | Proxy: %v | Hosting: %v\n",
 			r.Detail.Mobile, r.Detail.Proxy, r.Detail.Hosting)
 	}
 
-	return nil
+	_, err := os.Stdout.WriteString(b.String())
+	return err
 }
 
 // formatIP 格式化 IP 地址输出
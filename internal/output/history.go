@@ -0,0 +1,33 @@
+/*
+查询历史记录
+
+将查询结果转换为历史记录并写入全局历史存储。
+放在 output 包中，使 cli 批量处理、cmd 和 tui 共用同一转换逻辑。
+*/
+package output
+
+import "github/shawn/ip-tool/internal/history"
+
+// Record 记录一次查询结果
+//
+// 历史是辅助功能，写入失败不影响查询本身，因此忽略错误
+func Record(r *Result, input, source string) {
+	e := history.Entry{
+		Target: r.Target,
+		Input:  input,
+		Source: source,
+		Type:   r.Type,
+	}
+	if isValidIP(r.IPv4) {
+		e.IPv4 = r.IPv4
+	}
+	if isValidIP(r.IPv6) {
+		e.IPv6 = r.IPv6
+	}
+	if r.Detail != nil {
+		e.Country = r.Detail.Country
+		e.City = r.Detail.City
+		e.ISP = r.Detail.ISP
+	}
+	_ = history.Default().Record(e)
+}
@@ -0,0 +1,114 @@
+/*
+查询阶段的错误与来源
+
+Result.Errors 和 Result.Sources 以阶段为 key，JSON 使用者可以区分:
+
+	没有请求 (没有 -d)   errors 和 sources 中都没有 geo
+	查询失败             errors.geo.kind 为 timeout、unreachable 等
+	私网地址             errors.geo.kind 为 lookup_failed，message 说明原因
+
+阶段:
+
+	dns_v4, dns_v6              域名的 A/AAAA 解析
+	public_ip_v4, public_ip_v6  本机公网 IP (多来源投票)
+	geo                         地理位置
+
+目标本身是 IP 时没有解析阶段。
+*/
+package output
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github/shawn/ip-tool/internal/network"
+)
+
+// SchemaVersion Result 的 JSON/YAML 结构版本，字段含义变化或删除字段时增加
+const SchemaVersion = 1
+
+// 阶段名称
+const (
+	StageDNSv4      = "dns_v4"
+	StageDNSv6      = "dns_v6"
+	StagePublicIPv4 = "public_ip_v4"
+	StagePublicIPv6 = "public_ip_v6"
+	StageGeo        = "geo"
+)
+
+// StageError 一个阶段的失败
+type StageError struct {
+	Kind    string `json:"kind" yaml:"kind"`       // timeout、rate_limited、unreachable、bad_response、lookup_failed、canceled
+	Message string `json:"message" yaml:"message"` // 详细描述
+}
+
+// Source 一个阶段的结果由谁给出
+type Source struct {
+	Provider string    `json:"provider" yaml:"provider"`                     // dns、consensus、ip-api.com
+	Resolver string    `json:"resolver,omitempty" yaml:"resolver,omitempty"` // DNS 解析器
+	Voters   []string  `json:"voters,omitempty" yaml:"voters,omitempty"`     // 公网 IP: 投票给结果的来源
+	Shared   bool      `json:"shared" yaml:"shared"`                         // 与同时进行的相同查询共享了结果
+	Started  time.Time `json:"started_at" yaml:"started_at"`
+	Duration int64     `json:"duration_ms" yaml:"duration_ms"`
+}
+
+// stageLog 收集各阶段的错误和来源 (各阶段在不同 goroutine 中完成)
+type stageLog struct {
+	mu      sync.Mutex
+	errors  map[string]*StageError
+	sources map[string]*Source
+}
+
+// run 执行一个阶段并记录来源、耗时和错误
+//
+// fn 收到带 network.Trace 的 ctx；返回耗时 (毫秒)
+func (s *stageLog) run(ctx context.Context, stage string, fn func(ctx context.Context) error) int64 {
+	var trace network.Trace
+	start := time.Now()
+	err := fn(network.WithTrace(ctx, &trace))
+	elapsed := time.Since(start).Milliseconds()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if trace.Provider != "" {
+		if s.sources == nil {
+			s.sources = make(map[string]*Source)
+		}
+		s.sources[stage] = &Source{
+			Provider: trace.Provider,
+			Resolver: trace.Resolver,
+			Voters:   trace.Sources,
+			Shared:   trace.Shared,
+			Started:  start.UTC(),
+			Duration: elapsed,
+		}
+	}
+	if err != nil {
+		if s.errors == nil {
+			s.errors = make(map[string]*StageError)
+		}
+		s.errors[stage] = &StageError{Kind: errorKind(err), Message: err.Error()}
+	}
+	return elapsed
+}
+
+// errorKind 错误类别的名称 (见 network/errors.go)
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, network.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, network.ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, network.ErrUnreachable):
+		return "unreachable"
+	case errors.Is(err, network.ErrBadResponse):
+		return "bad_response"
+	case errors.Is(err, network.ErrLookupFailed):
+		return "lookup_failed"
+	}
+	return "error"
+}
@@ -1,7 +1,7 @@
 /*
 Package output 提供输出格式化功能
 
-依赖: internal/ip, internal/network
+依赖: internal/ip, internal/network, internal/history
 
 CLI Guidelines 原则 - NO_COLOR:
 - 尊重 NO_COLOR 环境变量 (https://no-color.org/)
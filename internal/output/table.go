@@ -0,0 +1,190 @@
+/*
+CSV/TSV 输出 (-o csv, -o tsv)
+
+Result 和 Detail 展开为固定顺序的列，表头稳定: 没有 -d 或查询失败时对应的列为空，
+不会少列。--fields 选择并排序列:
+
+	ipq -f ips.txt -d -o csv --fields target,ipv4,country,isp,proxy
+
+转义遵循 RFC 4180 (encoding/csv): 含分隔符、引号或换行的值加双引号，引号写成两个。
+TSV 使用同样的规则，只是分隔符为制表符。行尾为 LF。
+批量查询只输出一次表头，之后每完成一个结果写一行。
+*/
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// column 一列: 名称和取值
+type column struct {
+	name  string
+	value func(r *Result) string
+}
+
+// columns 全部列，顺序即默认顺序；只能在末尾追加，保持表头稳定
+var columns = []column{
+	{"schema_version", func(r *Result) string { return strconv.Itoa(r.SchemaVersion) }},
+	{"target", func(r *Result) string { return r.Target }},
+	{"ipv4", func(r *Result) string { return r.IPv4 }},
+	{"ipv6", func(r *Result) string { return r.IPv6 }},
+	{"type", func(r *Result) string { return r.Type }},
+	{"success", func(r *Result) string { return strconv.FormatBool(r.Success) }},
+	{"error", func(r *Result) string { return r.Error }},
+	{"isp", detailValue(func(d *Detail) string { return d.ISP })},
+	{"country", detailValue(func(d *Detail) string { return d.Country })},
+	{"region", detailValue(func(d *Detail) string { return d.Region })},
+	{"city", detailValue(func(d *Detail) string { return d.City })},
+	{"mobile", detailValue(func(d *Detail) string { return strconv.FormatBool(d.Mobile) })},
+	{"proxy", detailValue(func(d *Detail) string { return strconv.FormatBool(d.Proxy) })},
+	{"hosting", detailValue(func(d *Detail) string { return strconv.FormatBool(d.Hosting) })},
+	{"duration_ipv4_ms", func(r *Result) string { return strconv.FormatInt(r.Durations.IPv4, 10) }},
+	{"duration_ipv6_ms", func(r *Result) string { return strconv.FormatInt(r.Durations.IPv6, 10) }},
+	{"duration_geo_ms", func(r *Result) string { return strconv.FormatInt(r.Durations.Geo, 10) }},
+	{"duration_total_ms", func(r *Result) string { return strconv.FormatInt(r.Durations.Total, 10) }},
+	{"error_dns_v4", stageError(StageDNSv4)},
+	{"error_dns_v6", stageError(StageDNSv6)},
+	{"error_public_ip_v4", stageError(StagePublicIPv4)},
+	{"error_public_ip_v6", stageError(StagePublicIPv6)},
+	{"error_geo", stageError(StageGeo)},
+	{"source_ipv4", stageSource(StageDNSv4, StagePublicIPv4)},
+	{"source_ipv6", stageSource(StageDNSv6, StagePublicIPv6)},
+	{"source_geo", stageSource(StageGeo)},
+	{"resolver", resolver},
+}
+
+// detailValue Detail 字段的取值，没有 Detail 时为空
+func detailValue(f func(d *Detail) string) func(r *Result) string {
+	return func(r *Result) string {
+		if r.Detail == nil {
+			return ""
+		}
+		return f(r.Detail)
+	}
+}
+
+// stageError 阶段的错误描述，成功或未执行时为空
+func stageError(stage string) func(r *Result) string {
+	return func(r *Result) string {
+		if e := r.Errors[stage]; e != nil {
+			return e.Kind + ": " + e.Message
+		}
+		return ""
+	}
+}
+
+// stageSource 给出答案的 provider (取 stages 中第一个执行了的阶段)
+func stageSource(stages ...string) func(r *Result) string {
+	return func(r *Result) string {
+		for _, stage := range stages {
+			if s := r.Sources[stage]; s != nil {
+				return s.Provider
+			}
+		}
+		return ""
+	}
+}
+
+// resolver 域名解析使用的解析器
+func resolver(r *Result) string {
+	for _, stage := range []string{StageDNSv4, StageDNSv6} {
+		if s := r.Sources[stage]; s != nil {
+			return s.Resolver
+		}
+	}
+	return ""
+}
+
+// selected --fields 选择的列，nil 表示全部
+var selected []column
+
+// Fields 全部列名 (默认顺序)
+func Fields() []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// SetFields 设置 CSV/TSV 输出的列 (--fields)，空表示全部
+//
+// names 为逗号分隔的列名，按给出的顺序输出
+func SetFields(names string) error {
+	if strings.TrimSpace(names) == "" {
+		selected = nil
+		return nil
+	}
+	var cols []column
+next:
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		for _, c := range columns {
+			if c.name == name {
+				cols = append(cols, c)
+				continue next
+			}
+		}
+		return fmt.Errorf("unknown field %q", name)
+	}
+	selected = cols
+	return nil
+}
+
+// Table CSV/TSV 写入器，第一行之前写表头
+type Table struct {
+	w       *csv.Writer
+	columns []column
+	started bool
+}
+
+// NewTable 按 format (FormatCSV 或 FormatTSV) 写入 w
+func NewTable(w io.Writer, format Format) *Table {
+	t := &Table{w: csv.NewWriter(w), columns: selected}
+	if t.columns == nil {
+		t.columns = columns
+	}
+	if format == FormatTSV {
+		t.w.Comma = '\t'
+	}
+	return t
+}
+
+// Write 写入一行并立即刷新 (批量查询边查边输出)
+func (t *Table) Write(r *Result) error {
+	if !t.started {
+		header := make([]string, len(t.columns))
+		for i, c := range t.columns {
+			header[i] = c.name
+		}
+		if err := t.w.Write(header); err != nil {
+			return err
+		}
+		t.started = true
+	}
+
+	row := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		row[i] = c.value(r)
+	}
+	if err := t.w.Write(row); err != nil {
+		return err
+	}
+	t.w.Flush()
+	return t.w.Error()
+}
+
+// IsTable 是否为 CSV/TSV 格式
+func (f Format) IsTable() bool {
+	return f == FormatCSV || f == FormatTSV
+}
+
+// printTable 单个结果: 表头和一行
+func printTable(r *Result, format Format) error {
+	return NewTable(os.Stdout, format).Write(r)
+}
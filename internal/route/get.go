@@ -0,0 +1,110 @@
+/*
+路由查询
+
+结合两种来源回答 "到达某个地址走哪条路":
+ 1. 路由表最长前缀匹配: 出接口、网关、metric
+ 2. 已连接的 UDP socket: 内核实际选择的源地址
+
+两者不一致通常意味着策略路由 (ip rule)，例如 VPN 客户端
+把流量导入单独的路由表，而 /proc/net/route 只显示主表。
+*/
+package route
+
+import (
+	"net"
+
+	"github/shawn/ip-tool/internal/ip"
+	"github/shawn/ip-tool/internal/local"
+)
+
+// Result 路由查询结果
+type Result struct {
+	Destination     string `json:"destination" yaml:"destination"`
+	Route           *Route `json:"route,omitempty" yaml:"route,omitempty"` // 最长前缀匹配的路由
+	Source          string `json:"source,omitempty" yaml:"source,omitempty"`
+	SourceType      string `json:"source_type,omitempty" yaml:"source_type,omitempty"`
+	SourceInterface string `json:"source_interface,omitempty" yaml:"source_interface,omitempty"`
+	Consistent      bool   `json:"consistent" yaml:"consistent"` // 路由表与内核选择是否一致
+	TableError      string `json:"table_error,omitempty" yaml:"table_error,omitempty"`
+}
+
+// Get 查询到达 dest 的路由
+//
+// 路由表不可用时仍返回 UDP socket 的结果，错误记录在 TableError 中
+func Get(dest net.IP) *Result {
+	res := &Result{Destination: dest.String()}
+
+	// 内核选择的源地址 (不发送数据包)
+	res.Source = local.EgressIP(net.JoinHostPort(dest.String(), "53"))
+	if res.Source != "" {
+		res.SourceType = string(ip.Classify(res.Source))
+		res.SourceInterface = interfaceOf(res.Source)
+	}
+
+	routes, err := Table()
+	if err != nil {
+		res.TableError = err.Error()
+		return res
+	}
+	res.Route = Lookup(routes, dest)
+	if r := localRoute(dest); r != nil {
+		res.Route = r
+	}
+
+	// 交叉验证: 源地址应位于路由的出接口上
+	// 回环和本机地址的路由出接口是 lo，源地址所在接口不同，不算不一致
+	switch {
+	case res.Route == nil || res.Source == "":
+		res.Consistent = false
+	case dest.IsLoopback() || containsString(res.Route.Flags, "local"):
+		res.Consistent = true
+	default:
+		res.Consistent = res.Route.Interface == res.SourceInterface
+	}
+	return res
+}
+
+// localRoute 本机 IPv4 地址的路由
+//
+// /proc/net/route 只有主表，发往本机地址的流量实际走 local 表经 lo 投递；
+// IPv6 的 local 表已包含在 /proc/net/ipv6_route 中，无需处理
+func localRoute(dest net.IP) *Route {
+	v4 := dest.To4()
+	if v4 == nil || (!dest.IsLoopback() && interfaceOf(dest.String()) == "") {
+		return nil
+	}
+	prefix := &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)}
+	r := newRoute("ipv4", prefix, nil, "lo", 0, rtfUp|rtfHost|rtfLocal)
+	return &r
+}
+
+// interfaceOf 返回持有该地址的接口名
+func interfaceOf(addr string) string {
+	target := net.ParseIP(addr)
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.Equal(target) {
+				return iface.Name
+			}
+		}
+	}
+	return ""
+}
+
+// containsString 切片中是否包含 s
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
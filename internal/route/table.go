@@ -0,0 +1,293 @@
+/*
+Package route 读取路由表并查找到达目的地址的路由
+
+依赖: internal/ip, internal/local
+
+在有 VPN 和多条上行链路的主机上，"流量从哪里出去" 并不显然。
+本包读取内核路由表，做最长前缀匹配，再用一个已连接的 UDP socket
+(不发送数据包) 让内核给出实际的源地址作为交叉验证。
+
+数据来源 (Linux):
+- /proc/net/route:      IPv4 主路由表
+- /proc/net/ipv6_route: IPv6 路由 (包含 local 表)
+
+只保留 up 的路由；down 和 reject (不可达) 的路由不会被选中，不参与匹配。
+
+其他系统没有这两个文件，只能给出 UDP socket 的结果。
+*/
+package route
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github/shawn/ip-tool/internal/ip"
+)
+
+// 路由表路径 (变量便于测试时换成 testdata 中的样例)
+var (
+	ipv4Path = "/proc/net/route"
+	ipv6Path = "/proc/net/ipv6_route"
+)
+
+// ErrUnavailable 系统不提供可读的路由表
+var ErrUnavailable = errors.New("routing table not available on this system")
+
+// 路由标志 (linux/route.h, linux/ipv6_route.h)
+const (
+	rtfUp      = 0x0001
+	rtfGateway = 0x0002
+	rtfHost    = 0x0004
+	rtfReject  = 0x0200
+	rtfLocal   = 0x80000000
+)
+
+// Route 一条路由
+type Route struct {
+	Family      string   `json:"family" yaml:"family"`           // "ipv4" 或 "ipv6"
+	Destination string   `json:"destination" yaml:"destination"` // CIDR 前缀
+	Type        string   `json:"type" yaml:"type"`               // 前缀的 ip.Classify 分类
+	Gateway     string   `json:"gateway,omitempty" yaml:"gateway,omitempty"`
+	Interface   string   `json:"interface" yaml:"interface"`
+	Metric      uint32   `json:"metric" yaml:"metric"`
+	Flags       []string `json:"flags" yaml:"flags"`
+
+	prefix *net.IPNet
+}
+
+// network 路由的前缀
+//
+// 从路由表读取的路由已解析；其他来源 (JSON、测试替身) 只有 Destination，
+// 此时按 Destination 解析，无效时返回 nil
+func (r Route) network() *net.IPNet {
+	if r.prefix != nil {
+		return r.prefix
+	}
+	_, prefix, err := net.ParseCIDR(r.Destination)
+	if err != nil {
+		return nil
+	}
+	return prefix
+}
+
+// IsDefault 是否为默认路由
+func (r Route) IsDefault() bool {
+	return r.PrefixLen() == 0
+}
+
+// PrefixLen 前缀长度，前缀无效时为 -1
+func (r Route) PrefixLen() int {
+	prefix := r.network()
+	if prefix == nil {
+		return -1
+	}
+	ones, _ := prefix.Mask.Size()
+	return ones
+}
+
+// String 返回 "ip route" 风格的描述
+func (r Route) String() string {
+	dest := r.Destination
+	if r.IsDefault() {
+		dest = "default"
+	}
+	s := dest
+	if r.Gateway != "" {
+		s += " via " + r.Gateway
+	}
+	return fmt.Sprintf("%s dev %s metric %d", s, r.Interface, r.Metric)
+}
+
+// Table 读取 IPv4 和 IPv6 路由表
+//
+// 两个文件都不存在时返回 ErrUnavailable；只有一个存在时正常返回
+func Table() ([]Route, error) {
+	v4, err4 := readIPv4(ipv4Path)
+	v6, err6 := readIPv6(ipv6Path)
+	if err4 != nil && err6 != nil {
+		switch {
+		case !os.IsNotExist(err4):
+			return nil, err4
+		case !os.IsNotExist(err6):
+			return nil, err6
+		}
+		return nil, ErrUnavailable
+	}
+
+	routes := append(v4, v6...)
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Family != routes[j].Family {
+			return routes[i].Family < routes[j].Family
+		}
+		return routes[i].PrefixLen() > routes[j].PrefixLen()
+	})
+	return routes, nil
+}
+
+// Lookup 对目的地址做最长前缀匹配
+//
+// 前缀长度相同时选择 metric 最小的路由；没有匹配时返回 nil
+func Lookup(routes []Route, dest net.IP) *Route {
+	isV4 := dest.To4() != nil
+
+	var best *Route
+	for i := range routes {
+		r := &routes[i]
+		if prefix := r.network(); (r.Family == "ipv4") != isV4 || prefix == nil || !prefix.Contains(dest) {
+			continue
+		}
+		if best == nil ||
+			r.PrefixLen() > best.PrefixLen() ||
+			(r.PrefixLen() == best.PrefixLen() && r.Metric < best.Metric) {
+			best = r
+		}
+	}
+	return best
+}
+
+// readIPv4 解析 /proc/net/route
+//
+// 格式 (地址为主机字节序的十六进制，x86 上即小端):
+//
+//	Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+//	eth0  00000000    010200C0 0003 0      0   0      00000000 0 0 0
+func readIPv4(path string) ([]Route, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []Route
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 8 {
+			continue // 表头
+		}
+
+		dest, err1 := hexIPv4(fields[1])
+		gw, err2 := hexIPv4(fields[2])
+		flags, err3 := strconv.ParseUint(fields[3], 16, 32)
+		metric, err4 := strconv.ParseUint(fields[6], 10, 32)
+		mask, err5 := hexIPv4(fields[7])
+		if err := errors.Join(err1, err2, err3, err4, err5); err != nil {
+			continue
+		}
+		if flags&rtfUp == 0 || flags&rtfReject != 0 {
+			continue
+		}
+
+		prefix := &net.IPNet{IP: dest.Mask(net.IPMask(mask)), Mask: net.IPMask(mask)}
+		routes = append(routes, newRoute("ipv4", prefix, gw, fields[0], uint32(metric), flags))
+	}
+	return routes, nil
+}
+
+// readIPv6 解析 /proc/net/ipv6_route
+//
+// 格式 (地址为 32 位十六进制，数值为十六进制):
+//
+//	dest destLen src srcLen nexthop metric refcnt use flags iface
+func readIPv6(path string) ([]Route, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []Route
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 10 {
+			continue
+		}
+
+		dest, err1 := hex.DecodeString(fields[0])
+		plen, err2 := strconv.ParseUint(fields[1], 16, 8)
+		gw, err3 := hex.DecodeString(fields[4])
+		metric, err4 := strconv.ParseUint(fields[5], 16, 32)
+		flags, err5 := strconv.ParseUint(fields[8], 16, 32)
+		if err := errors.Join(err1, err2, err3, err4, err5); err != nil {
+			continue
+		}
+		if len(dest) != net.IPv6len || len(gw) != net.IPv6len || flags&rtfUp == 0 || flags&rtfReject != 0 {
+			continue
+		}
+
+		mask := net.CIDRMask(int(plen), 128)
+		prefix := &net.IPNet{IP: net.IP(dest).Mask(mask), Mask: mask}
+		routes = append(routes, newRoute("ipv6", prefix, net.IP(gw), fields[9], uint32(metric), flags))
+	}
+	return routes, nil
+}
+
+// newRoute 构造 Route
+func newRoute(family string, prefix *net.IPNet, gw net.IP, iface string, metric uint32, flags uint64) Route {
+	r := Route{
+		Family:      family,
+		Destination: prefix.String(),
+		Type:        string(ip.Classify(prefix.IP.String())),
+		Interface:   iface,
+		Metric:      metric,
+		Flags:       flagNames(flags),
+		prefix:      prefix,
+	}
+	if flags&rtfGateway != 0 && !gw.IsUnspecified() {
+		r.Gateway = gw.String()
+	}
+	if ones, _ := prefix.Mask.Size(); ones == 0 {
+		r.Type = "Default"
+	}
+	return r
+}
+
+// flagNames 路由标志名称
+func flagNames(flags uint64) []string {
+	names := []string{}
+	if flags&rtfUp != 0 {
+		names = append(names, "up")
+	}
+	if flags&rtfGateway != 0 {
+		names = append(names, "gateway")
+	}
+	if flags&rtfHost != 0 {
+		names = append(names, "host")
+	}
+	if flags&rtfLocal != 0 {
+		names = append(names, "local")
+	}
+	return names
+}
+
+// hexIPv4 解析主机字节序十六进制的 IPv4 地址
+func hexIPv4(s string) (net.IP, error) {
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 4)
+	binary.NativeEndian.PutUint32(b, uint32(v))
+	return net.IPv4(b[0], b[1], b[2], b[3]).To4(), nil
+}
+
+// readLines 读取文件的所有行
+func readLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,176 @@
+package route
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testdata/route 中的地址按小端写 (与 x86、arm64 上的内核一致)
+func skipBigEndian(t *testing.T) {
+	t.Helper()
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		t.Skip("testdata/route is little-endian")
+	}
+}
+
+// useTestdata 让 Table 读取 testdata 中的样例
+func useTestdata(t *testing.T, v4, v6 string) {
+	t.Helper()
+	orig4, orig6 := ipv4Path, ipv6Path
+	ipv4Path, ipv6Path = v4, v6
+	t.Cleanup(func() { ipv4Path, ipv6Path = orig4, orig6 })
+}
+
+// describe 每条路由的 "ip route" 风格描述和标志
+func describe(routes []Route) [][2]string {
+	var got [][2]string
+	for _, r := range routes {
+		got = append(got, [2]string{r.String(), strings.Join(r.Flags, ",")})
+	}
+	return got
+}
+
+func TestReadIPv4(t *testing.T) {
+	skipBigEndian(t)
+	routes, err := readIPv4("testdata/route")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// down (eth1) 和 reject (100.0.0.0/8) 的路由被过滤
+	want := [][2]string{
+		{"default via 192.168.2.1 dev eth0 metric 100", "up,gateway"},
+		{"default dev wg0 metric 50", "up"},
+		{"192.168.2.0/24 dev eth0 metric 100", "up"},
+		{"10.0.0.0/8 dev wg0 metric 0", "up"},
+		{"10.0.1.0/24 dev wg0 metric 0", "up"},
+	}
+	if got := describe(routes); !reflect.DeepEqual(got, want) {
+		t.Errorf("routes:\n%q\nwant:\n%q", got, want)
+	}
+	if routes[0].Type != "Default" || routes[2].Type != "Private" {
+		t.Errorf("types = %q, %q", routes[0].Type, routes[2].Type)
+	}
+}
+
+func TestReadIPv6(t *testing.T) {
+	routes, err := readIPv6("testdata/ipv6_route")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// down (2001:db8:1::/48) 和 lo 上不可达的默认路由被过滤
+	want := [][2]string{
+		{"default via fe80::1 dev eth0 metric 1024", "up,gateway"},
+		{"2001:db8::/64 dev eth0 metric 256", "up"},
+		{"2001:db8::/32 dev wg0 metric 256", "up"},
+		{"fe80::/64 dev eth0 metric 256", "up"},
+		{"::1/128 dev lo metric 0", "up,local"},
+	}
+	if got := describe(routes); !reflect.DeepEqual(got, want) {
+		t.Errorf("routes:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	skipBigEndian(t)
+	useTestdata(t, "testdata/route", "testdata/ipv6_route")
+	routes, err := Table()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dest string
+		want string
+	}{
+		// 最长前缀
+		{"10.0.1.5", "10.0.1.0/24 dev wg0 metric 0"},
+		{"10.9.9.9", "10.0.0.0/8 dev wg0 metric 0"},
+		{"192.168.2.7", "192.168.2.0/24 dev eth0 metric 100"},
+		{"2001:db8::1", "2001:db8::/64 dev eth0 metric 256"},
+		{"::1", "::1/128 dev lo metric 0"},
+		// down 的 192.168.0.0/16 和 2001:db8:1::/48 不参与匹配
+		{"192.168.0.1", "default dev wg0 metric 50"},
+		{"2001:db8:1::1", "2001:db8::/32 dev wg0 metric 256"},
+		// 两条默认路由，metric 小的优先；reject 的 100.0.0.0/8 不参与匹配
+		{"8.8.8.8", "default dev wg0 metric 50"},
+		{"100.1.2.3", "default dev wg0 metric 50"},
+		{"2606:4700::1", "default via fe80::1 dev eth0 metric 1024"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dest, func(t *testing.T) {
+			r := Lookup(routes, net.ParseIP(tt.dest))
+			if r == nil {
+				t.Fatalf("no route, want %s", tt.want)
+			}
+			if r.String() != tt.want {
+				t.Errorf("route = %s, want %s", r, tt.want)
+			}
+		})
+	}
+}
+
+// 没有前缀的路由 (如从 JSON 读入) 按 Destination 匹配
+func TestLookupDestinationOnly(t *testing.T) {
+	routes := []Route{
+		{Family: "ipv4", Destination: "0.0.0.0/0", Interface: "eth0", Metric: 100},
+		{Family: "ipv4", Destination: "198.51.100.0/24", Interface: "eth0", Metric: 100},
+		{Family: "ipv4", Destination: "198.51.100.0/24", Interface: "wg0", Metric: 10},
+		{Family: "ipv4", Destination: "not a prefix", Interface: "bad"},
+	}
+
+	if r := Lookup(routes, net.ParseIP("198.51.100.9")); r == nil || r.Interface != "wg0" {
+		t.Errorf("198.51.100.9 -> %v, want wg0", r)
+	}
+	if r := Lookup(routes, net.ParseIP("203.0.113.1")); r == nil || !r.IsDefault() {
+		t.Errorf("203.0.113.1 -> %v, want the default route", r)
+	}
+	if r := Lookup(routes, net.ParseIP("2001:db8::1")); r != nil {
+		t.Errorf("IPv6 destination matched IPv4 route %v", r)
+	}
+}
+
+func TestTable(t *testing.T) {
+	skipBigEndian(t)
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing")
+
+	// 只有一个文件存在时正常返回
+	useTestdata(t, missing, "testdata/ipv6_route")
+	routes, err := Table()
+	if err != nil || len(routes) != 5 {
+		t.Errorf("IPv6 only: %d routes, %v", len(routes), err)
+	}
+
+	// IPv4 在前，同一族内前缀长的在前 (长度相同时保持文件中的顺序)
+	useTestdata(t, "testdata/route", "testdata/ipv6_route")
+	routes, err = Table()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first, last := routes[0], routes[len(routes)-1]; first.Destination != "192.168.2.0/24" || last.Destination != "::/0" {
+		t.Errorf("order: first %s, last %s", first.Destination, last.Destination)
+	}
+
+	// 都不存在
+	useTestdata(t, missing, missing)
+	if _, err := Table(); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("both missing: err = %v, want ErrUnavailable", err)
+	}
+
+	// 一个不存在、另一个读取失败时返回读取失败的原因
+	for _, paths := range [][2]string{{missing, dir}, {dir, missing}} {
+		useTestdata(t, paths[0], paths[1])
+		_, err := Table()
+		if err == nil || errors.Is(err, ErrUnavailable) || os.IsNotExist(err) {
+			t.Errorf("%s, %s: err = %v, want the read error", paths[0], paths[1], err)
+		}
+	}
+}
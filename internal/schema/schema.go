@@ -0,0 +1,212 @@
+/*
+JSON Schema 生成
+
+从 Go 类型反射生成 JSON Schema (draft 2020-12)，按 encoding/json 的规则:
+
+	json 标签的名称     属性名，"-" 跳过
+	omitempty          不在 required 中
+	指针 (无 omitempty) 可以为 null
+	切片 (无 omitempty) 可以为 null (nil 切片编码为 null)
+	map[string]T       object，值的 schema 为 additionalProperties
+	time.Time          string，format date-time
+	TextMarshaler      string (如 net.IP)
+	嵌入的结构体        字段展开到外层
+
+具名结构体放在 $defs 中用 $ref 引用，同一类型只生成一次。
+schema 与输出共用同一组类型，改了结构体 schema 跟着变，不会和实际输出不一致。
+不限制 additionalProperties: 增加字段不是破坏性变更。
+*/
+package schema
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Draft 生成的 schema 遵循的规范
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema 一个 JSON Schema 节点
+type Schema struct {
+	Schema      string             `json:"$schema,omitempty" yaml:"$schema,omitempty"`
+	ID          string             `json:"$id,omitempty" yaml:"$id,omitempty"`
+	Title       string             `json:"title,omitempty" yaml:"title,omitempty"`
+	Description string             `json:"description,omitempty" yaml:"description,omitempty"`
+	Ref         string             `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Type        any                `json:"type,omitempty" yaml:"type,omitempty"` // string，可为 null 时为 [string, "null"]
+	Format      string             `json:"format,omitempty" yaml:"format,omitempty"`
+	Const       any                `json:"const,omitempty" yaml:"const,omitempty"`
+	AnyOf       []*Schema          `json:"anyOf,omitempty" yaml:"anyOf,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty" yaml:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty" yaml:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty" yaml:"items,omitempty"`
+	Additional  *Schema            `json:"additionalProperties,omitempty" yaml:"additionalProperties,omitempty"`
+	Defs        map[string]*Schema `json:"$defs,omitempty" yaml:"$defs,omitempty"`
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Generate 生成 v 的类型的 schema (v 可以是零值，如 []*Result(nil))
+//
+// 顶层的结构体直接展开，不放进 $defs
+func Generate(v any) *Schema {
+	g := &generator{defs: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var s *Schema
+	if t.Kind() == reflect.Struct && t != timeType {
+		g.names[t] = "" // 引用自己时为 "#"
+		s = g.object(t)
+	} else {
+		s = g.schema(t)
+	}
+	s.Schema = Draft
+	if len(g.defs) > 0 {
+		s.Defs = g.defs
+	}
+	return s
+}
+
+// generator 生成过程中收集 $defs
+type generator struct {
+	defs  map[string]*Schema
+	names map[reflect.Type]string // 已生成的类型在 $defs 中的名称
+}
+
+// schema 类型 t 的 schema
+func (g *generator) schema(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"} // []byte 编码为 base64
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", Additional: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.name(t)
+			g.names[t] = name // 先登记，递归类型引用自己时不再展开
+			g.defs[name] = g.object(t)
+		}
+		return ref(name)
+	}
+	return &Schema{} // interface 等: 不限制
+}
+
+// ref 引用 $defs 中的 name，空名称表示顶层
+func ref(name string) *Schema {
+	if name == "" {
+		return &Schema{Ref: "#"}
+	}
+	return &Schema{Ref: "#/$defs/" + name}
+}
+
+// name 类型在 $defs 中的名称，不同包的同名类型 (如 doctor.Report、gateway.Report) 加包名区分
+func (g *generator) name(t reflect.Type) string {
+	name := t.Name()
+	for _, used := range g.names {
+		if used == name {
+			pkg := t.PkgPath()
+			return pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+		}
+	}
+	return name
+}
+
+// object 结构体的 schema
+func (g *generator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.fields(t, s)
+	return s
+}
+
+// fields 把 t 的字段加入 s，嵌入的结构体展开
+func (g *generator) fields(t reflect.Type, s *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		omitempty := strings.Contains(opts, "omitempty")
+
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, s)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fs := g.schema(ft)
+		if !omitempty {
+			s.Required = append(s.Required, name)
+			if nullable(ft) {
+				fs = orNull(fs)
+			}
+		}
+		s.Properties[name] = fs
+	}
+}
+
+// nullable 零值编码为 null 的类型
+func nullable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Interface:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Uint8
+	}
+	return false
+}
+
+// orNull 允许 s 或 null
+func orNull(s *Schema) *Schema {
+	if s.Ref != "" {
+		return &Schema{AnyOf: []*Schema{s, {Type: "null"}}}
+	}
+	if typ, ok := s.Type.(string); ok {
+		s.Type = []string{typ, "null"}
+	}
+	return s
+}
@@ -0,0 +1,255 @@
+/*
+NAT 行为探测 (RFC 5780 第 4 节)
+
+服务器主地址记为 A1:P1，备用地址 (OTHER-ADDRESS) 记为 A2:P2。
+
+映射行为 (同一个本地套接字):
+  - 测试 I:   发往 A1:P1，得到映射地址 M1
+  - 测试 II:  发往 A2:P1，得到 M2；M2 == M1 则与目的地无关 (endpoint-independent)
+  - 测试 III: 发往 A2:P2，得到 M3；M3 == M2 则只与目的 IP 有关 (address-dependent)，
+    否则与目的 IP 和端口都有关 (address-and-port-dependent，即对称型 NAT)
+
+过滤行为 (新的本地套接字，避免映射测试打开的"洞"干扰结果):
+  - 测试 I:   发往 A1:P1，建立映射
+  - 测试 II:  要求从 A2:P2 回复；收到则不过滤 (endpoint-independent)
+  - 测试 III: 要求从 A1:P2 回复；收到则只按 IP 过滤 (address-dependent)，
+    否则按 IP 和端口过滤 (address-and-port-dependent)
+
+回环 (hairpinning): 从另一个本地端口向 M1 发请求，
+第一个套接字收到这个请求即说明 NAT 支持内部主机经公网地址互访。
+*/
+package stun
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+)
+
+// Behavior 映射或过滤行为
+type Behavior string
+
+const (
+	BehaviorEndpointIndependent  Behavior = "endpoint-independent"
+	BehaviorAddressDependent     Behavior = "address-dependent"
+	BehaviorAddressPortDependent Behavior = "address-and-port-dependent"
+	BehaviorUnknown              Behavior = "unknown"
+)
+
+// testTimeout 每个探测测试的等待时间 (变量便于单元测试缩短)
+//
+// 过滤测试中"收不到响应"本身就是结果，所以不能等太久
+var testTimeout = 3 * time.Second
+
+// NATReport NAT 行为探测结果
+type NATReport struct {
+	Server      string   `json:"server" yaml:"server"`
+	Local       string   `json:"local" yaml:"local"`                                 // 本地地址
+	Mapped      string   `json:"mapped" yaml:"mapped"`                               // 公网映射地址
+	NAT         bool     `json:"nat" yaml:"nat"`                                     // 映射地址与本地地址是否不同
+	Mapping     Behavior `json:"mapping" yaml:"mapping"`                             // 映射行为
+	Filtering   Behavior `json:"filtering" yaml:"filtering"`                         // 过滤行为
+	Hairpinning *bool    `json:"hairpinning,omitempty" yaml:"hairpinning,omitempty"` // nil 表示未测试
+	Type        string   `json:"type" yaml:"type"`                                   // RFC 3489 的传统名称
+	Notes       []string `json:"notes,omitempty" yaml:"notes,omitempty"`
+}
+
+// Discover 对 server 执行映射、过滤与回环测试
+//
+// 只有测试 I 失败时返回错误；服务器不支持 RFC 5780 时
+// 相应行为为 unknown 并在 Notes 中说明
+func Discover(ctx context.Context, server, network string) (*NATReport, error) {
+	primary, err := resolve(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := listen(network, primary)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// 映射测试 I
+	first, err := test(ctx, conn, primary, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	local := conn.LocalAddr().(*net.UDPAddr)
+	report := &NATReport{
+		Server:    primary.String(),
+		Local:     local.String(),
+		Mapped:    first.Mapped.String(),
+		NAT:       !sameAddr(first.Mapped, local),
+		Mapping:   BehaviorUnknown,
+		Filtering: BehaviorUnknown,
+	}
+
+	other := first.Other
+	switch {
+	case other == nil:
+		report.Notes = append(report.Notes, "server does not support RFC 5780 (no OTHER-ADDRESS), use one that does to classify the NAT")
+	case other.IP.Equal(primary.IP) || other.Port == primary.Port:
+		report.Notes = append(report.Notes, "server's alternate address "+other.String()+" does not differ in both IP and port")
+		other = nil
+	}
+
+	if other != nil {
+		report.Mapping = mapping(ctx, conn, first.Mapped, primary, other)
+		report.Filtering, err = filtering(ctx, network, primary, other)
+		if err != nil {
+			report.Notes = append(report.Notes, "filtering test: "+err.Error())
+		}
+	}
+
+	// 没有 NAT 时回环没有意义
+	if report.NAT {
+		ok, err := hairpin(ctx, network, conn, first.Mapped)
+		if err != nil {
+			report.Notes = append(report.Notes, "hairpinning test: "+err.Error())
+		} else {
+			report.Hairpinning = &ok
+		}
+	}
+
+	report.Type = classify(report)
+	return report, nil
+}
+
+// mapping 映射测试 II 和 III
+func mapping(ctx context.Context, conn *net.UDPConn, m1, primary, other *net.UDPAddr) Behavior {
+	resp, err := test(ctx, conn, &net.UDPAddr{IP: other.IP, Port: primary.Port}, 0)
+	if err != nil {
+		return BehaviorUnknown
+	}
+	m2 := resp.Mapped
+	if sameAddr(m1, m2) {
+		return BehaviorEndpointIndependent
+	}
+
+	resp, err = test(ctx, conn, other, 0)
+	if err != nil {
+		return BehaviorUnknown
+	}
+	if sameAddr(m2, resp.Mapped) {
+		return BehaviorAddressDependent
+	}
+	return BehaviorAddressPortDependent
+}
+
+// filtering 过滤测试，使用新的套接字
+func filtering(ctx context.Context, network string, primary, other *net.UDPAddr) (Behavior, error) {
+	conn, err := listen(network, primary)
+	if err != nil {
+		return BehaviorUnknown, err
+	}
+	defer conn.Close()
+
+	if _, err := test(ctx, conn, primary, 0); err != nil {
+		return BehaviorUnknown, err
+	}
+
+	resp, err := test(ctx, conn, primary, changeIP|changePort)
+	switch {
+	case err == nil && !resp.From.IP.Equal(primary.IP):
+		return BehaviorEndpointIndependent, nil
+	case err == nil:
+		return BehaviorUnknown, errors.New("server ignored CHANGE-REQUEST")
+	case !errors.Is(err, ErrTimeout):
+		return BehaviorUnknown, err
+	}
+
+	resp, err = test(ctx, conn, primary, changePort)
+	switch {
+	case err == nil && resp.From.Port != primary.Port:
+		return BehaviorAddressDependent, nil
+	case err == nil:
+		return BehaviorUnknown, errors.New("server ignored CHANGE-REQUEST")
+	case !errors.Is(err, ErrTimeout):
+		return BehaviorUnknown, err
+	}
+	return BehaviorAddressPortDependent, nil
+}
+
+// hairpin 从新的本地端口向 mapped 发请求，看 conn 是否收到
+func hairpin(ctx context.Context, network string, conn *net.UDPConn, mapped *net.UDPAddr) (bool, error) {
+	sender, err := listen(network, mapped)
+	if err != nil {
+		return false, err
+	}
+	defer sender.Close()
+
+	txID, err := newTxID()
+	if err != nil {
+		return false, err
+	}
+	req := request(txID, 0)
+	if _, err := sender.WriteToUDP(req, mapped); err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, testTimeout)
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+	defer stop()
+	deadline, _ := ctx.Deadline()
+	conn.SetReadDeadline(deadline)
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				return false, nil
+			}
+			return false, err
+		}
+		// 收到的是我们自己发出的请求 (不是响应)
+		if n >= headerSize && binary.BigEndian.Uint16(buf[0:2]) == typeBindingRequest && bytes.Equal(buf[8:20], txID) {
+			return true, nil
+		}
+	}
+}
+
+// test 执行一个测试，限时 testTimeout
+func test(ctx context.Context, conn *net.UDPConn, server *net.UDPAddr, change uint32) (*Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, testTimeout)
+	defer cancel()
+	return roundTrip(ctx, conn, server, change)
+}
+
+// classify RFC 3489 的传统 NAT 类型名称
+func classify(r *NATReport) string {
+	switch {
+	case !r.NAT && r.Filtering == BehaviorEndpointIndependent:
+		return "open internet"
+	case !r.NAT && r.Filtering != BehaviorUnknown:
+		return "no NAT, firewalled"
+	case !r.NAT:
+		return "no NAT"
+	case r.Mapping == BehaviorAddressDependent || r.Mapping == BehaviorAddressPortDependent:
+		return "symmetric"
+	case r.Mapping == BehaviorUnknown:
+		return "unknown"
+	}
+
+	switch r.Filtering {
+	case BehaviorEndpointIndependent:
+		return "full cone"
+	case BehaviorAddressDependent:
+		return "restricted cone"
+	case BehaviorAddressPortDependent:
+		return "port-restricted cone"
+	}
+	return "cone"
+}
+
+// sameAddr IP 和端口都相同
+func sameAddr(a, b *net.UDPAddr) bool {
+	return a.IP.Equal(b.IP) && a.Port == b.Port
+}
@@ -0,0 +1,304 @@
+package stun
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer 本地的 RFC 5780 服务器，同时模拟服务器前面的 NAT
+//
+// 服务器在 127.0.0.1 和 127.0.0.2 各有两个端口 (A1:P1 A1:P2 A2:P1 A2:P2)。
+// NAT 不改写真实的数据包，而是由服务器按 mapping 报告映射地址，
+// 按 filtering 决定是否从其他地址回复:
+//   - mapping 为空: 没有 NAT，报告真实的源地址
+//   - 映射地址 M1 是一个真实的 "公网" 套接字，hairpin 为 true 时
+//     它把收到的数据包转发给第一个客户端
+type fakeServer struct {
+	mapping      Behavior // 空表示没有 NAT
+	filtering    Behavior
+	noOther      bool // 不支持 RFC 5780: 不返回 OTHER-ADDRESS
+	ignoreChange bool // 忽略 CHANGE-REQUEST，总是从收到请求的地址回复
+	hairpin      bool
+
+	socks  [2][2]*net.UDPConn // [IP][端口]
+	public *net.UDPConn       // NAT 的公网映射 M1
+
+	mu      sync.Mutex
+	client  *net.UDPAddr // 第一个客户端 (映射测试使用的套接字)
+	changes []uint32     // 收到的 CHANGE-REQUEST 标志
+}
+
+// start 打开全部套接字并开始应答，返回主地址
+func (f *fakeServer) start(t *testing.T) string {
+	t.Helper()
+	ips := []string{"127.0.0.1", "127.0.0.2"}
+	for attempt := 0; ; attempt++ {
+		if f.listenAll(ips) {
+			break
+		}
+		if attempt > 20 {
+			t.Skip("cannot bind the same ports on 127.0.0.1 and 127.0.0.2")
+		}
+	}
+	var err error
+	if f.public, err = net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 3)}); err != nil {
+		t.Skip("cannot bind 127.0.0.3:", err)
+	}
+	t.Cleanup(func() {
+		for _, row := range f.socks {
+			for _, c := range row {
+				c.Close()
+			}
+		}
+		f.public.Close()
+	})
+
+	for i := range f.socks {
+		for j := range f.socks[i] {
+			go f.serve(i, j)
+		}
+	}
+	go f.forward()
+	return f.socks[0][0].LocalAddr().String()
+}
+
+// listenAll 两个 IP 上绑定相同的两个端口，端口被占用时返回 false
+func (f *fakeServer) listenAll(ips []string) bool {
+	var opened []*net.UDPConn
+	ports := [2]int{}
+	for j := range ports {
+		for i, ip := range ips {
+			c, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP(ip), Port: ports[j]})
+			if err != nil {
+				for _, c := range opened {
+					c.Close()
+				}
+				return false
+			}
+			opened = append(opened, c)
+			ports[j] = c.LocalAddr().(*net.UDPAddr).Port
+			f.socks[i][j] = c
+		}
+	}
+	return true
+}
+
+// serve 应答发到 socks[i][j] 的请求
+func (f *fakeServer) serve(i, j int) {
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := f.socks[i][j].ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		req := buf[:n]
+		if n < headerSize || binary.BigEndian.Uint16(req[0:2]) != typeBindingRequest {
+			continue
+		}
+		txID := append([]byte(nil), req[8:20]...)
+
+		var change uint32
+		if n >= headerSize+8 && binary.BigEndian.Uint16(req[20:22]) == attrChangeRequest {
+			change = binary.BigEndian.Uint32(req[24:28])
+		}
+
+		f.mu.Lock()
+		if f.client == nil {
+			f.client = from
+		}
+		if change != 0 {
+			f.changes = append(f.changes, change)
+		}
+		f.mu.Unlock()
+
+		ri, rj := i, j
+		if !f.ignoreChange {
+			if change&changeIP != 0 {
+				ri ^= 1
+			}
+			if change&changePort != 0 {
+				rj ^= 1
+			}
+		}
+		if !f.passes(i, j, ri, rj) {
+			continue // NAT 丢弃
+		}
+
+		reply := f.socks[ri][rj]
+		attrs := [][]byte{
+			attr(attrXorMappedAddress, addrValue(f.mapped(from, i, j), txID)),
+			attr(attrResponseOrigin, addrValue(reply.LocalAddr().(*net.UDPAddr), nil)),
+		}
+		if !f.noOther {
+			attrs = append(attrs, attr(attrOtherAddress, addrValue(f.socks[1][1].LocalAddr().(*net.UDPAddr), nil)))
+		}
+		reply.WriteToUDP(message(typeBindingSuccess, txID, attrs...), from)
+	}
+}
+
+// mapped 按映射行为报告客户端的公网地址
+func (f *fakeServer) mapped(from *net.UDPAddr, i, j int) *net.UDPAddr {
+	if f.mapping == "" {
+		return from
+	}
+	m := *f.public.LocalAddr().(*net.UDPAddr)
+	switch f.mapping {
+	case BehaviorAddressDependent:
+		m.Port += i // 不同的目的 IP 使用不同的映射
+	case BehaviorAddressPortDependent:
+		m.Port += i*2 + j // 每个目的地址一个映射
+	}
+	return &m
+}
+
+// passes NAT 是否放行从 socks[ri][rj] 回复的数据包 (请求发往 socks[i][j])
+func (f *fakeServer) passes(i, j, ri, rj int) bool {
+	switch f.filtering {
+	case BehaviorAddressDependent:
+		return ri == i
+	case BehaviorAddressPortDependent:
+		return ri == i && rj == j
+	}
+	return true
+}
+
+// forward 回环: 发到 M1 的数据包转发给第一个客户端
+func (f *fakeServer) forward() {
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := f.public.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		client := f.client
+		f.mu.Unlock()
+		if f.hairpin && client != nil {
+			f.public.WriteToUDP(buf[:n], client)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	orig := testTimeout
+	testTimeout = 300 * time.Millisecond
+	t.Cleanup(func() { testTimeout = orig })
+
+	yes, no := true, false
+	tests := []struct {
+		name      string
+		server    *fakeServer
+		nat       bool
+		mapping   Behavior
+		filtering Behavior
+		hairpin   *bool
+		typ       string
+		note      string
+	}{
+		{"open internet", &fakeServer{filtering: BehaviorEndpointIndependent},
+			false, BehaviorEndpointIndependent, BehaviorEndpointIndependent, nil, "open internet", ""},
+		{"firewalled", &fakeServer{filtering: BehaviorAddressPortDependent},
+			false, BehaviorEndpointIndependent, BehaviorAddressPortDependent, nil, "no NAT, firewalled", ""},
+		{"no NAT, no RFC 5780", &fakeServer{noOther: true},
+			false, BehaviorUnknown, BehaviorUnknown, nil, "no NAT", "OTHER-ADDRESS"},
+		{"full cone", &fakeServer{mapping: BehaviorEndpointIndependent, filtering: BehaviorEndpointIndependent, hairpin: true},
+			true, BehaviorEndpointIndependent, BehaviorEndpointIndependent, &yes, "full cone", ""},
+		{"restricted cone", &fakeServer{mapping: BehaviorEndpointIndependent, filtering: BehaviorAddressDependent},
+			true, BehaviorEndpointIndependent, BehaviorAddressDependent, &no, "restricted cone", ""},
+		{"port-restricted cone", &fakeServer{mapping: BehaviorEndpointIndependent, filtering: BehaviorAddressPortDependent},
+			true, BehaviorEndpointIndependent, BehaviorAddressPortDependent, &no, "port-restricted cone", ""},
+		{"symmetric, address-dependent", &fakeServer{mapping: BehaviorAddressDependent, filtering: BehaviorAddressPortDependent},
+			true, BehaviorAddressDependent, BehaviorAddressPortDependent, &no, "symmetric", ""},
+		{"symmetric, address-and-port-dependent", &fakeServer{mapping: BehaviorAddressPortDependent, filtering: BehaviorAddressPortDependent},
+			true, BehaviorAddressPortDependent, BehaviorAddressPortDependent, &no, "symmetric", ""},
+		{"NAT, no RFC 5780", &fakeServer{mapping: BehaviorEndpointIndependent, noOther: true},
+			true, BehaviorUnknown, BehaviorUnknown, &no, "unknown", "OTHER-ADDRESS"},
+		{"server ignores CHANGE-REQUEST", &fakeServer{mapping: BehaviorEndpointIndependent, ignoreChange: true},
+			true, BehaviorEndpointIndependent, BehaviorUnknown, &no, "cone", "ignored CHANGE-REQUEST"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := tt.server.start(t)
+
+			r, err := Discover(context.Background(), addr, "udp4")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.NAT != tt.nat || r.Mapping != tt.mapping || r.Filtering != tt.filtering {
+				t.Errorf("nat=%v mapping=%s filtering=%s, want nat=%v mapping=%s filtering=%s",
+					r.NAT, r.Mapping, r.Filtering, tt.nat, tt.mapping, tt.filtering)
+			}
+			if (r.Hairpinning == nil) != (tt.hairpin == nil) || (tt.hairpin != nil && *r.Hairpinning != *tt.hairpin) {
+				t.Errorf("hairpinning = %v, want %v", fmtBool(r.Hairpinning), fmtBool(tt.hairpin))
+			}
+			if r.Type != tt.typ {
+				t.Errorf("type = %q, want %q", r.Type, tt.typ)
+			}
+			if tt.note != "" && !strings.Contains(strings.Join(r.Notes, "; "), tt.note) {
+				t.Errorf("notes %q do not mention %q", r.Notes, tt.note)
+			}
+		})
+	}
+}
+
+// 过滤测试先要求换 IP 和端口，再只换端口
+func TestDiscoverChangeRequests(t *testing.T) {
+	orig := testTimeout
+	testTimeout = 300 * time.Millisecond
+	t.Cleanup(func() { testTimeout = orig })
+
+	server := &fakeServer{mapping: BehaviorEndpointIndependent, filtering: BehaviorAddressPortDependent}
+	if _, err := Discover(context.Background(), server.start(t), "udp4"); err != nil {
+		t.Fatal(err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.changes) == 0 || server.changes[0] != changeIP|changePort {
+		t.Fatalf("CHANGE-REQUEST sequence = %v, want change IP+port first", server.changes)
+	}
+	last := server.changes[len(server.changes)-1]
+	if last != changePort {
+		t.Errorf("last CHANGE-REQUEST = %#x, want change port only", last)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	ei, ad, apd, unknown := BehaviorEndpointIndependent, BehaviorAddressDependent, BehaviorAddressPortDependent, BehaviorUnknown
+	tests := []struct {
+		nat                bool
+		mapping, filtering Behavior
+		want               string
+	}{
+		{false, ei, ei, "open internet"},
+		{false, ei, ad, "no NAT, firewalled"},
+		{false, ei, apd, "no NAT, firewalled"},
+		{false, unknown, unknown, "no NAT"},
+		{true, ad, ei, "symmetric"},
+		{true, apd, apd, "symmetric"},
+		{true, unknown, unknown, "unknown"},
+		{true, ei, ei, "full cone"},
+		{true, ei, ad, "restricted cone"},
+		{true, ei, apd, "port-restricted cone"},
+		{true, ei, unknown, "cone"},
+	}
+	for _, tt := range tests {
+		r := &NATReport{NAT: tt.nat, Mapping: tt.mapping, Filtering: tt.filtering}
+		if got := classify(r); got != tt.want {
+			t.Errorf("classify(nat=%v, %s, %s) = %q, want %q", tt.nat, tt.mapping, tt.filtering, got, tt.want)
+		}
+	}
+}
+
+func fmtBool(b *bool) string {
+	if b == nil {
+		return "nil"
+	}
+	return strconv.FormatBool(*b)
+}
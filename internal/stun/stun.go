@@ -0,0 +1,338 @@
+/*
+Package stun 实现 STUN 客户端 (RFC 5389) 与 NAT 行为探测 (RFC 5780)
+
+这是底层包，不依赖任何其他 internal 包。internal/network 的 STUN
+公网 IP 来源 (consensus.go) 调用本包的 Binding；NAT 探测要在同一个
+UDP 套接字上收发多次，与 network 的 HTTP 客户端和代理设置无关，所以单独成包。
+
+STUN 服务器把收到请求的源地址 (即 NAT 之后的公网地址) 放在
+XOR-MAPPED-ADDRESS 属性中返回。它走 UDP，与 HTTP 回显服务
+经过的路径不同，可以发现只代理了 HTTP 的透明代理。
+
+支持 RFC 5780 的服务器有两个 IP 和两个端口，并在 OTHER-ADDRESS
+中告知备用地址；向不同地址发请求、或要求服务器换地址回复，
+就能判断 NAT 的映射与过滤行为 (nat.go)。
+
+报文格式:
+
+	 0                   1                   2                   3
+	|0 0|   消息类型 (14)           |         长度 (16)             |
+	|                    Magic Cookie 0x2112A442                    |
+	|                     Transaction ID (96)                       |
+	|                          属性 (TLV) ...                        |
+*/
+package stun
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// 消息类型与属性
+const (
+	magicCookie = 0x2112A442
+	headerSize  = 20
+
+	typeBindingRequest   = 0x0001
+	typeBindingSuccess   = 0x0101
+	typeBindingError     = 0x0111
+	attrMappedAddress    = 0x0001
+	attrChangeRequest    = 0x0003 // RFC 5780
+	attrSourceAddress    = 0x0004 // RFC 3489，RESPONSE-ORIGIN 的前身
+	attrChangedAddress   = 0x0005 // RFC 3489，OTHER-ADDRESS 的前身
+	attrXorMappedAddress = 0x0020
+	attrResponseOrigin   = 0x802B // RFC 5780
+	attrOtherAddress     = 0x802C // RFC 5780
+)
+
+// CHANGE-REQUEST 标志: 要求服务器从另一个 IP 或端口回复
+const (
+	changeIP   = 0x04
+	changePort = 0x02
+)
+
+// 重传: RFC 5389 建议 RTO 从 500ms 开始翻倍
+const (
+	initialRTO = 500 * time.Millisecond
+	maxRetries = 4
+)
+
+var (
+	// ErrNoMappedAddress 响应中没有映射地址
+	ErrNoMappedAddress = errors.New("no mapped address in STUN response")
+
+	// ErrTimeout 没有收到响应
+	ErrTimeout = errors.New("timeout")
+)
+
+// Response Binding 响应中的地址
+type Response struct {
+	Mapped *net.UDPAddr // 服务器看到的地址 (XOR-MAPPED-ADDRESS 或 MAPPED-ADDRESS)
+	Other  *net.UDPAddr // 服务器的备用地址 (OTHER-ADDRESS)，不支持 RFC 5780 时为 nil
+	Origin *net.UDPAddr // 服务器声明的发送地址 (RESPONSE-ORIGIN)
+	From   *net.UDPAddr // 实际收到响应的来源地址
+}
+
+// Binding 向 server 发送 Binding 请求，返回服务器看到的地址
+//
+// network 为 "udp4" 或 "udp6"，决定使用哪个地址族
+func Binding(ctx context.Context, server, network string) (*net.UDPAddr, error) {
+	addr, err := resolve(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	resp, err := roundTrip(ctx, conn, addr, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Mapped, nil
+}
+
+// resolve 解析服务器地址，只取指定地址族
+func resolve(ctx context.Context, network, server string) (*net.UDPAddr, error) {
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		return nil, err
+	}
+	p, err := net.DefaultResolver.LookupPort(ctx, "udp", port)
+	if err != nil {
+		return nil, err
+	}
+
+	family := "ip4"
+	if network == "udp6" {
+		family = "ip6"
+	}
+	ips, err := net.DefaultResolver.LookupIP(ctx, family, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no %s address for %s", family, host)
+	}
+	return &net.UDPAddr{IP: ips[0], Port: p}, nil
+}
+
+// listen 打开一个未连接的 UDP 套接字，绑定到通往 server 的出口地址
+//
+// 不使用 connect: 行为探测需要接收来自服务器其他 IP/端口的响应。
+// 绑定具体地址而不是 0.0.0.0，才能拿本地地址与映射地址比较
+func listen(network string, server *net.UDPAddr) (*net.UDPConn, error) {
+	probe, err := net.DialUDP(network, nil, server)
+	if err != nil {
+		return nil, err
+	}
+	local := probe.LocalAddr().(*net.UDPAddr).IP
+	probe.Close()
+
+	return net.ListenUDP(network, &net.UDPAddr{IP: local})
+}
+
+// request 生成 Binding 请求，change 为 CHANGE-REQUEST 标志 (0 表示不带该属性)
+func request(txID []byte, change uint32) []byte {
+	length := 0
+	if change != 0 {
+		length = 8
+	}
+	req := make([]byte, headerSize+length)
+	binary.BigEndian.PutUint16(req[0:2], typeBindingRequest)
+	binary.BigEndian.PutUint16(req[2:4], uint16(length))
+	binary.BigEndian.PutUint32(req[4:8], magicCookie)
+	copy(req[8:20], txID)
+	if change != 0 {
+		binary.BigEndian.PutUint16(req[20:22], attrChangeRequest)
+		binary.BigEndian.PutUint16(req[22:24], 4)
+		binary.BigEndian.PutUint32(req[24:28], change)
+	}
+	return req
+}
+
+// newTxID 随机的 96 位事务 ID
+func newTxID() ([]byte, error) {
+	txID := make([]byte, 12)
+	_, err := rand.Read(txID)
+	return txID, err
+}
+
+// roundTrip 在 conn 上向 server 发送请求并等待对应的响应
+//
+// 按 RTO 翻倍重传，直到收到响应、重传用尽或 ctx 结束
+func roundTrip(ctx context.Context, conn *net.UDPConn, server *net.UDPAddr, change uint32) (*Response, error) {
+	// 取消时立即中断阻塞的读取
+	stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	txID, err := newTxID()
+	if err != nil {
+		return nil, err
+	}
+	req := request(txID, change)
+
+	buf := make([]byte, 1500)
+	rto := initialRTO
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if _, err := conn.WriteToUDP(req, server); err != nil {
+			return nil, err
+		}
+
+		deadline := time.Now().Add(rto)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		conn.SetReadDeadline(deadline)
+
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				var ne net.Error
+				if errors.As(err, &ne) && ne.Timeout() {
+					break // 重传
+				}
+				return nil, err
+			}
+			resp, err := parseResponse(buf[:n], txID)
+			if errors.Is(err, errMismatch) {
+				continue // 不是本次请求的响应
+			}
+			if err != nil {
+				return nil, err
+			}
+			resp.From = from
+			return resp, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, ErrTimeout
+		}
+		rto *= 2
+	}
+	return nil, ErrTimeout
+}
+
+// errMismatch 事务 ID 不匹配
+var errMismatch = errors.New("transaction mismatch")
+
+// parseResponse 解析 Binding 响应
+func parseResponse(b, txID []byte) (*Response, error) {
+	if len(b) < headerSize || binary.BigEndian.Uint32(b[4:8]) != magicCookie {
+		return nil, errMismatch
+	}
+	if string(b[8:20]) != string(txID) {
+		return nil, errMismatch
+	}
+
+	switch binary.BigEndian.Uint16(b[0:2]) {
+	case typeBindingSuccess:
+	case typeBindingError:
+		return nil, errors.New("STUN server returned an error")
+	default:
+		return nil, errMismatch
+	}
+
+	length := int(binary.BigEndian.Uint16(b[2:4]))
+	if headerSize+length > len(b) {
+		return nil, errors.New("truncated STUN response")
+	}
+
+	resp := &Response{}
+	var mapped *net.UDPAddr
+	attrs := b[headerSize : headerSize+length]
+	for len(attrs) >= 4 {
+		typ := binary.BigEndian.Uint16(attrs[0:2])
+		l := int(binary.BigEndian.Uint16(attrs[2:4]))
+		if 4+l > len(attrs) {
+			break
+		}
+		value := attrs[4 : 4+l]
+
+		// 新旧属性同时出现时以 RFC 5389/5780 的为准
+		switch typ {
+		case attrXorMappedAddress:
+			if addr, err := parseAddress(value, b[4:20]); err == nil {
+				resp.Mapped = addr
+			}
+		case attrMappedAddress:
+			if addr, err := parseAddress(value, nil); err == nil {
+				mapped = addr
+			}
+		case attrOtherAddress:
+			if addr, err := parseAddress(value, nil); err == nil {
+				resp.Other = addr
+			}
+		case attrChangedAddress:
+			if addr, err := parseAddress(value, nil); err == nil && resp.Other == nil {
+				resp.Other = addr
+			}
+		case attrResponseOrigin:
+			if addr, err := parseAddress(value, nil); err == nil {
+				resp.Origin = addr
+			}
+		case attrSourceAddress:
+			if addr, err := parseAddress(value, nil); err == nil && resp.Origin == nil {
+				resp.Origin = addr
+			}
+		}
+
+		// 属性按 4 字节对齐
+		next := 4 + (l+3)&^3
+		if next > len(attrs) {
+			break
+		}
+		attrs = attrs[next:]
+	}
+
+	if resp.Mapped == nil {
+		resp.Mapped = mapped
+	}
+	if resp.Mapped == nil {
+		return nil, ErrNoMappedAddress
+	}
+	return resp, nil
+}
+
+// parseAddress 解析 (XOR-)MAPPED-ADDRESS
+//
+// xor 为 magic cookie + transaction ID，nil 表示不做异或
+//
+//	| 0x00 | family | port (16) | address (32 或 128) |
+func parseAddress(v, xor []byte) (*net.UDPAddr, error) {
+	if len(v) < 4 {
+		return nil, errors.New("short address")
+	}
+
+	var size int
+	switch v[1] {
+	case 0x01:
+		size = net.IPv4len
+	case 0x02:
+		size = net.IPv6len
+	default:
+		return nil, errors.New("unknown address family")
+	}
+	if len(v) < 4+size {
+		return nil, errors.New("short address")
+	}
+
+	port := binary.BigEndian.Uint16(v[2:4])
+	addr := make(net.IP, size)
+	copy(addr, v[4:4+size])
+
+	if xor != nil {
+		port ^= uint16(magicCookie >> 16)
+		for i := range addr {
+			addr[i] ^= xor[i]
+		}
+	}
+	return &net.UDPAddr{IP: addr, Port: int(port)}, nil
+}
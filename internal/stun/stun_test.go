@@ -0,0 +1,150 @@
+package stun
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+)
+
+// RFC 5769 2.2 的 IPv4 响应: XOR-MAPPED-ADDRESS 为 192.0.2.1:32853
+//
+// MESSAGE-INTEGRITY 和 FINGERPRINT 不校验，其值用零代替
+var rfc5769Response = []byte{
+	0x01, 0x01, 0x00, 0x3c,
+	0x21, 0x12, 0xa4, 0x42,
+	0xb7, 0xe7, 0xa7, 0x01, 0xbc, 0x34, 0xd6, 0x86, 0xfa, 0x87, 0xdf, 0xae,
+	0x80, 0x22, 0x00, 0x0b, // SOFTWARE
+	0x74, 0x65, 0x73, 0x74, 0x20, 0x76, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x20,
+	0x00, 0x20, 0x00, 0x08, // XOR-MAPPED-ADDRESS
+	0x00, 0x01, 0xa1, 0x47, 0xe1, 0x12, 0xa6, 0x43,
+	0x00, 0x08, 0x00, 0x14, // MESSAGE-INTEGRITY
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0x80, 0x28, 0x00, 0x04, // FINGERPRINT
+	0, 0, 0, 0,
+}
+
+func TestParseResponseRFC5769(t *testing.T) {
+	resp, err := parseResponse(rfc5769Response, rfc5769Response[8:20])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 32853}); !sameAddr(resp.Mapped, want) {
+		t.Errorf("mapped = %s, want %s", resp.Mapped, want)
+	}
+}
+
+// attr 编码一个属性 (按 4 字节对齐)
+func attr(typ uint16, value []byte) []byte {
+	b := binary.BigEndian.AppendUint16(nil, typ)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(value)))
+	b = append(b, value...)
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// addrValue 编码 (XOR-)MAPPED-ADDRESS 的值，txID 不为 nil 时做异或
+func addrValue(addr *net.UDPAddr, txID []byte) []byte {
+	ip, family := addr.IP.To4(), byte(0x01)
+	if ip == nil {
+		ip, family = addr.IP.To16(), 0x02
+	}
+	port := uint16(addr.Port)
+	ip = append(net.IP(nil), ip...)
+	if txID != nil {
+		key := binary.BigEndian.AppendUint32(nil, magicCookie)
+		key = append(key, txID...)
+		port ^= magicCookie >> 16
+		for i := range ip {
+			ip[i] ^= key[i]
+		}
+	}
+	v := []byte{0, family}
+	v = binary.BigEndian.AppendUint16(v, port)
+	return append(v, ip...)
+}
+
+// message 编码 STUN 消息
+func message(typ uint16, txID []byte, attrs ...[]byte) []byte {
+	body := bytes.Join(attrs, nil)
+	b := binary.BigEndian.AppendUint16(nil, typ)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(body)))
+	b = binary.BigEndian.AppendUint32(b, magicCookie)
+	b = append(b, txID...)
+	return append(b, body...)
+}
+
+func TestParseResponse(t *testing.T) {
+	txID := bytes.Repeat([]byte{0xab}, 12)
+	v4 := &net.UDPAddr{IP: net.IPv4(203, 0, 113, 7).To4(), Port: 40000}
+	v6 := &net.UDPAddr{IP: net.ParseIP("2001:db8:1234:5678:11:2233:4455:6677"), Port: 32853}
+	other := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 2).To4(), Port: 3479}
+
+	tests := []struct {
+		name    string
+		msg     []byte
+		mapped  *net.UDPAddr
+		other   *net.UDPAddr
+		wantErr error
+	}{
+		{"xor IPv4", message(typeBindingSuccess, txID, attr(attrXorMappedAddress, addrValue(v4, txID))), v4, nil, nil},
+		{"xor IPv6", message(typeBindingSuccess, txID, attr(attrXorMappedAddress, addrValue(v6, txID))), v6, nil, nil},
+		{"mapped fallback", message(typeBindingSuccess, txID, attr(attrMappedAddress, addrValue(v4, nil))), v4, nil, nil},
+		{"xor preferred", message(typeBindingSuccess, txID,
+			attr(attrMappedAddress, addrValue(other, nil)),
+			attr(attrXorMappedAddress, addrValue(v4, txID))), v4, nil, nil},
+		{"other address", message(typeBindingSuccess, txID,
+			attr(attrXorMappedAddress, addrValue(v4, txID)),
+			attr(attrOtherAddress, addrValue(other, nil))), v4, other, nil},
+		{"changed address (RFC 3489)", message(typeBindingSuccess, txID,
+			attr(attrXorMappedAddress, addrValue(v4, txID)),
+			attr(attrChangedAddress, addrValue(other, nil))), v4, other, nil},
+		{"no address", message(typeBindingSuccess, txID), nil, nil, ErrNoMappedAddress},
+		{"other transaction", message(typeBindingSuccess, bytes.Repeat([]byte{1}, 12),
+			attr(attrXorMappedAddress, addrValue(v4, txID))), nil, nil, errMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := parseResponse(tt.msg, txID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sameAddr(resp.Mapped, tt.mapped) {
+				t.Errorf("mapped = %s, want %s", resp.Mapped, tt.mapped)
+			}
+			if (resp.Other == nil) != (tt.other == nil) || (tt.other != nil && !sameAddr(resp.Other, tt.other)) {
+				t.Errorf("other = %v, want %v", resp.Other, tt.other)
+			}
+		})
+	}
+}
+
+func TestParseResponseError(t *testing.T) {
+	txID := bytes.Repeat([]byte{0xab}, 12)
+	if _, err := parseResponse(message(typeBindingError, txID), txID); err == nil || errors.Is(err, errMismatch) {
+		t.Errorf("error response: err = %v", err)
+	}
+}
+
+func TestRequestChangeRequest(t *testing.T) {
+	txID := bytes.Repeat([]byte{0xcd}, 12)
+	if req := request(txID, 0); len(req) != headerSize || binary.BigEndian.Uint16(req[2:4]) != 0 {
+		t.Errorf("plain request has attributes: %x", req)
+	}
+	req := request(txID, changeIP|changePort)
+	if got := binary.BigEndian.Uint16(req[20:22]); got != attrChangeRequest {
+		t.Fatalf("attribute type = %#x, want CHANGE-REQUEST", got)
+	}
+	if got := binary.BigEndian.Uint32(req[24:28]); got != changeIP|changePort {
+		t.Errorf("CHANGE-REQUEST flags = %#x, want 0x06", got)
+	}
+}
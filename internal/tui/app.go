@@ -1,7 +1,7 @@
 /*
 Package tui 提供交互式终端界面
 
-依赖: internal/network, internal/output
+依赖: internal/ip, internal/network, internal/output, internal/history, internal/local
 
 基于 Bubble Tea 框架实现，遵循 Elm 架构:
 - Model: 应用状态 (App 结构体)
@@ -17,16 +17,24 @@ CLI Guidelines 原则 - 交互式设计:
 - q/Ctrl+C: 退出
 - r: 刷新
 - d: 详情
+- l: 本机地址面板 (查询本机时默认显示)
 - 4/6: 复制 IPv4/IPv6
+- / 或 :: 输入新目标 (见 prompt.go)
+
+批量模式使用列表视图 (List)，见 list.go
 */
 package tui
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strings"
 	"time"
 
+	"github/shawn/ip-tool/internal/history"
+	ipkg "github/shawn/ip-tool/internal/ip"
+	"github/shawn/ip-tool/internal/local"
 	"github/shawn/ip-tool/internal/network"
 	"github/shawn/ip-tool/internal/output"
 
@@ -40,38 +48,113 @@ type App struct {
 	target         string           // 查询目标
 	ipv4           string           // IPv4 结果
 	ipv6           string           // IPv6 结果
+	errs           [2]error         // IPv4、IPv6 未检测到的原因 (决定退出码)
 	geoInfo        *network.GeoInfo // 地理位置信息
 	message        string           // 临时消息 (如 "Copied!")
 	loading        bool             // 是否加载中
 	showDetail     bool             // 是否显示详情
 	fetchingDetail bool             // 是否正在获取详情
 	spinner        spinner.Model    // 加载动画
+	prompt         *prompt          // 目标输入框
+	seq            int              // 查询序号，用于丢弃旧目标的迟到结果
+	source         string           // 目标来源 (写入查询历史)
+	input          string           // 原始输入 (写入查询历史)
+	recorded       bool             // 本次查询是否已写入历史
+	showLocal      bool             // 是否显示本机地址面板
+	local          *local.Inventory // 本机接口清单
+	localErr       string           // 读取本机接口失败的原因
+
+	ctx    context.Context    // 程序的 context，收到信号时取消
+	query  context.Context    // 当前查询，切换目标或退出时取消
+	cancel context.CancelFunc // 取消当前查询
 }
 
 // NewApp 创建新应用实例
-func NewApp(target string, showDetail bool) *App {
+func NewApp(ctx context.Context, target string, showDetail bool) *App {
 	s := spinner.New()
 	s.Spinner = spinner.Dot
+	p := newPrompt()
+	p.remember(target)
 	return &App{
 		target:     target,
 		loading:    true,
 		showDetail: showDetail,
 		spinner:    s,
+		prompt:     p,
+		source:     history.SourceTUI,
+		showLocal:  target == "",
+		ctx:        ctx,
 	}
 }
 
+// SetSource 设置初始目标的来源，用于查询历史
+//
+// source 为空时初始目标不写入历史 (已由调用方记录，如列表视图打开的详情)
+func (a *App) SetSource(source, input string) {
+	a.source = source
+	a.input = input
+}
+
 // 消息类型 (Bubble Tea 消息传递模式)
+//
+// 查询结果携带 seq，切换目标后旧查询的结果会被忽略
 type (
-	ipv4Msg   string           // IPv4 查询结果
-	ipv6Msg   string           // IPv6 查询结果
-	geoMsg    *network.GeoInfo // 地理位置结果
-	geoErrMsg string           // 地理位置错误
-	clearMsg  struct{}         // 清除临时消息
+	ipv4Msg struct {
+		seq int
+		ip  string
+		err error
+	} // IPv4 查询结果
+	ipv6Msg struct {
+		seq int
+		ip  string
+		err error
+	} // IPv6 查询结果
+	geoMsg struct {
+		seq  int
+		info *network.GeoInfo
+	} // 地理位置结果
+	geoErrMsg struct {
+		seq int
+		err string
+	} // 地理位置错误
+	clearMsg    struct{}         // 清除临时消息
+	localMsg    *local.Inventory // 本机接口清单
+	localErrMsg string           // 读取本机接口失败
 )
 
 // Init 初始化应用
 func (a *App) Init() tea.Cmd {
-	cmds := []tea.Cmd{a.spinner.Tick}
+	cmds := []tea.Cmd{a.spinner.Tick, a.start()}
+	if a.showLocal {
+		cmds = append(cmds, fetchLocal)
+	}
+	return tea.Batch(cmds...)
+}
+
+// fetchLocal 读取本机接口清单
+func fetchLocal() tea.Msg {
+	inv, err := local.Collect()
+	if err != nil {
+		return localErrMsg(err.Error())
+	}
+	return localMsg(inv)
+}
+
+// start 开始查询当前目标
+//
+// 重置结果并返回查询命令，Init、刷新和输入新目标共用
+// 上一次查询中仍在进行的请求会被取消
+func (a *App) start() tea.Cmd {
+	a.stop()
+	a.query, a.cancel = context.WithCancel(a.ctx)
+	a.seq++
+	a.ipv4 = ""
+	a.ipv6 = ""
+	a.errs = [2]error{}
+	a.geoInfo = nil
+	a.loading = true
+	a.fetchingDetail = false
+	a.recorded = false
 
 	// 如果目标是 IP 地址，直接使用
 	if ip := net.ParseIP(a.target); ip != nil {
@@ -82,29 +165,58 @@ func (a *App) Init() tea.Cmd {
 			a.ipv6 = a.target
 			a.ipv4 = "Not Applicable"
 		}
+		a.updateLoading()
 		if a.showDetail {
-			cmds = append(cmds, a.fetchGeo(a.target))
+			a.fetchingDetail = true
+			return a.fetchGeo(a.target)
 		}
-		a.updateLoading()
-		return tea.Batch(cmds...)
+		return nil
 	}
 
 	// 域名或空，需要解析
-	cmds = append(cmds,
-		func() tea.Msg { return ipv4Msg(network.ResolveIPv4(a.target)) },
-		func() tea.Msg { return ipv6Msg(network.ResolveIPv6(a.target)) },
+	seq, target, ctx := a.seq, a.target, a.query
+	return tea.Batch(
+		func() tea.Msg {
+			ip, err := network.ResolveIPv4(ctx, target)
+			return ipv4Msg{seq, ip, err}
+		},
+		func() tea.Msg {
+			ip, err := network.ResolveIPv6(ctx, target)
+			return ipv6Msg{seq, ip, err}
+		},
 	)
-	return tea.Batch(cmds...)
+}
+
+// Err 退出时当前目标的查询结果: 两个地址族都没有检测到时返回 *output.LookupError
+//
+// 查询未完成就退出不算失败
+func (a *App) Err() error {
+	if a.ipv4 == "" || a.ipv6 == "" || a.getValidIP() != "" {
+		return nil
+	}
+	target := a.target
+	if target == "" {
+		target = "(localhost)"
+	}
+	return output.NewLookupError(target, "Could not detect IP address", a.errs[:]...)
+}
+
+// stop 取消当前查询 (退出或关闭详情视图时)
+func (a *App) stop() {
+	if a.cancel != nil {
+		a.cancel()
+	}
 }
 
 // fetchGeo 创建获取地理位置的命令
 func (a *App) fetchGeo(ip string) tea.Cmd {
+	seq, ctx := a.seq, a.query
 	return func() tea.Msg {
-		info, err := network.FetchGeoInfo(ip)
+		info, err := network.FetchGeoInfo(ctx, ip)
 		if err != nil {
-			return geoErrMsg(err.Error())
+			return geoErrMsg{seq, err.Error()}
 		}
-		return geoMsg(info)
+		return geoMsg{seq, info}
 	}
 }
 
@@ -112,19 +224,45 @@ func (a *App) fetchGeo(ip string) tea.Cmd {
 func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		// 输入框打开时，按键交给输入框 (Ctrl+C 除外)
+		if a.prompt.active && msg.String() != "ctrl+c" {
+			target, cmd := a.prompt.update(msg)
+			if a.prompt.active || target == "" {
+				return a, cmd
+			}
+			a.target = target
+			a.source = history.SourceTUI
+			a.input = a.prompt.input.Value()
+			return a, a.start()
+		}
+
 		switch msg.String() {
 		case "q", "ctrl+c":
+			a.stop()
 			return a, tea.Quit
 
+		case "/", ":":
+			return a, a.prompt.open()
+
 		case "r":
+			if a.showLocal {
+				return a, tea.Batch(a.refresh(), fetchLocal)
+			}
 			return a, a.refresh()
 
+		case "l":
+			a.showLocal = !a.showLocal
+			if a.showLocal {
+				return a, fetchLocal
+			}
+
 		case "d":
 			if !a.showDetail {
 				a.showDetail = true
 				targetIP := a.getValidIP()
 				if targetIP != "" {
 					a.loading = true
+					a.fetchingDetail = true
 					return a, a.fetchGeo(targetIP)
 				}
 				a.geoInfo = &network.GeoInfo{Status: "fail", Message: "No valid IP"}
@@ -148,13 +286,23 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case clearMsg:
 		a.message = ""
 
+	case localMsg:
+		a.local = msg
+		a.localErr = ""
+
+	case localErrMsg:
+		a.localErr = string(msg)
+
 	case spinner.TickMsg:
 		var cmd tea.Cmd
 		a.spinner, cmd = a.spinner.Update(msg)
 		return a, cmd
 
 	case ipv4Msg:
-		a.ipv4 = string(msg)
+		if msg.seq != a.seq {
+			return a, nil
+		}
+		a.ipv4, a.errs[0] = msg.ip, msg.err
 		a.updateLoading()
 		if a.showDetail && !a.fetchingDetail && a.geoInfo == nil && a.ipv4 != "Not Detected" {
 			a.fetchingDetail = true
@@ -162,7 +310,10 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case ipv6Msg:
-		a.ipv6 = string(msg)
+		if msg.seq != a.seq {
+			return a, nil
+		}
+		a.ipv6, a.errs[1] = msg.ip, msg.err
 		a.updateLoading()
 		if a.showDetail && !a.fetchingDetail && a.geoInfo == nil && a.ipv6 != "Not Detected" {
 			a.fetchingDetail = true
@@ -170,12 +321,18 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case geoMsg:
-		a.geoInfo = msg
+		if msg.seq != a.seq {
+			return a, nil
+		}
+		a.geoInfo = msg.info
 		a.fetchingDetail = false
 		a.updateLoading()
 
 	case geoErrMsg:
-		a.geoInfo = &network.GeoInfo{Status: "fail", Message: string(msg)}
+		if msg.seq != a.seq {
+			return a, nil
+		}
+		a.geoInfo = &network.GeoInfo{Status: "fail", Message: msg.err}
 		a.fetchingDetail = false
 		a.updateLoading()
 	}
@@ -185,35 +342,9 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 // refresh 刷新查询
 func (a *App) refresh() tea.Cmd {
-	a.ipv4 = ""
-	a.ipv6 = ""
-	a.geoInfo = nil
-	a.loading = true
-	a.fetchingDetail = false
 	a.message = "Refreshing..."
-
 	clearCmd := tea.Tick(500*time.Millisecond, func(time.Time) tea.Msg { return clearMsg{} })
-
-	if ip := net.ParseIP(a.target); ip != nil {
-		if ip.To4() != nil {
-			a.ipv4 = a.target
-			a.ipv6 = "Not Applicable"
-		} else {
-			a.ipv6 = a.target
-			a.ipv4 = "Not Applicable"
-		}
-		if a.showDetail {
-			return tea.Batch(clearCmd, a.fetchGeo(a.target))
-		}
-		a.updateLoading()
-		return clearCmd
-	}
-
-	return tea.Batch(
-		clearCmd,
-		func() tea.Msg { return ipv4Msg(network.ResolveIPv4(a.target)) },
-		func() tea.Msg { return ipv6Msg(network.ResolveIPv6(a.target)) },
-	)
+	return tea.Batch(clearCmd, a.start())
 }
 
 // View 渲染界面
@@ -268,15 +399,33 @@ func (a *App) View() string {
 		b.WriteString("\n")
 	}
 
-	// 底部帮助
-	if a.message != "" {
+	// 本机地址
+	if a.showLocal {
+		a.viewLocal(&b)
+	}
+
+	// 底部: 输入框 / 临时消息 / 帮助
+	if a.prompt.active {
+		b.WriteString("\n  " + a.prompt.input.View() + "\n")
+		if errMsg := a.prompt.validationError(); errMsg != "" {
+			b.WriteString(output.StyleError.Render("  ✗ " + errMsg))
+		} else if recent := a.prompt.recent(5); a.prompt.input.Value() == "" && len(recent) > 0 {
+			b.WriteString(output.StyleHint.Render("  Recent: " + strings.Join(recent, ", ")))
+		} else {
+			b.WriteString(output.StyleHint.Render("  enter to query, ↑/↓ for history, esc to cancel"))
+		}
+		b.WriteString("\n")
+	} else if a.message != "" {
 		b.WriteString(fmt.Sprintf("\n  %s\n", a.message))
 	} else {
 		var keys []string
-		keys = append(keys, "r to refresh")
+		keys = append(keys, "/ to query", "r to refresh")
 		if !a.showDetail {
 			keys = append(keys, "d for detail")
 		}
+		if !a.showLocal {
+			keys = append(keys, "l for local")
+		}
 		keys = append(keys, "4/6 to copy", "q to quit")
 		b.WriteString(fmt.Sprintf("\n (%s)\n", strings.Join(keys, ", ")))
 	}
@@ -284,27 +433,103 @@ func (a *App) View() string {
 	return b.String()
 }
 
+// viewLocal 渲染本机地址面板
+//
+// 只显示启用的非回环接口，出口源地址加标记
+func (a *App) viewLocal(b *strings.Builder) {
+	b.WriteString("  [ LOCAL ]\n")
+
+	switch {
+	case a.localErr != "":
+		b.WriteString(output.StyleError.Render("  ✗ " + a.localErr))
+		b.WriteString("\n\n")
+		return
+	case a.local == nil:
+		b.WriteString(output.StyleHint.Render("  Reading interfaces..."))
+		b.WriteString("\n\n")
+		return
+	}
+
+	shown := 0
+	for _, iface := range a.local.Interfaces {
+		if !iface.IsUp() {
+			continue
+		}
+		for _, addr := range iface.Addresses {
+			if addr.Type == string(ipkg.TypeLoopback) {
+				continue
+			}
+			line := fmt.Sprintf("  %-10s: %s [%s]", iface.Name, addr.CIDR(), addr.Type)
+			if addr.Egress {
+				line += " " + output.StyleSuccess.Render("← egress")
+			}
+			if addr.Temporary {
+				line += " " + output.StyleHint.Render("(temporary)")
+			}
+			b.WriteString(line + "\n")
+			shown++
+		}
+	}
+	if shown == 0 {
+		b.WriteString(output.StyleHint.Render("  (no local addresses)"))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+}
+
 // updateLoading 更新加载状态
 func (a *App) updateLoading() {
 	ipReady := a.ipv4 != "" && a.ipv6 != ""
 	if !a.showDetail {
 		a.loading = !ipReady
+	} else {
+		a.loading = !(ipReady && a.geoInfo != nil)
+	}
+
+	if !a.loading && !a.recorded {
+		a.recorded = true
+		a.record()
+	}
+}
+
+// record 将当前查询写入历史
+func (a *App) record() {
+	if a.source == "" {
 		return
 	}
-	a.loading = !(ipReady && a.geoInfo != nil)
+	r := &output.Result{
+		Target: a.target,
+		IPv4:   a.ipv4,
+		IPv6:   a.ipv6,
+	}
+	if r.Target == "" {
+		r.Target = "(localhost)"
+	}
+	if ip := a.getValidIP(); ip != "" {
+		r.Type = string(ipkg.Classify(ip))
+	}
+	if a.geoInfo != nil && a.geoInfo.IsSuccess() {
+		r.Detail = output.NewDetail(a.geoInfo)
+	}
+	output.Record(r, a.input, a.source)
 }
 
 // getValidIP 获取有效的 IP 地址
 func (a *App) getValidIP() string {
-	if a.ipv4 != "" && a.ipv4 != "Not Detected" && a.ipv4 != "Not Applicable" {
+	if validIP(a.ipv4) {
 		return a.ipv4
 	}
-	if a.ipv6 != "" && a.ipv6 != "Not Detected" && a.ipv6 != "Not Applicable" {
+	if validIP(a.ipv6) {
 		return a.ipv6
 	}
 	return ""
 }
 
+// validIP 检查是否为有效 IP 值 (排除加载中和占位符)
+func validIP(s string) bool {
+	return s != "" && s != "Not Detected" && s != "Not Applicable"
+}
+
 // buildLocation 构建位置字符串
 func buildLocation(g *network.GeoInfo) string {
 	var parts []string
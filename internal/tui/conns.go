@@ -0,0 +1,362 @@
+/*
+连接实时视图
+
+ipq conns 在终端中运行时使用此视图，定时重新读取套接字表。
+
+设计决策:
+- 一次刷新完成后才安排下一次，慢速的反向解析不会让刷新堆积
+- 远端信息由 Enricher 缓存，刷新时只查询新出现的 IP
+- 光标跟随连接 (本地/远端端点)，而不是行号，刷新后不会跳动
+
+键位设计:
+- ↑/↓ (k/j): 移动光标
+- /: 过滤 (匹配任意列)
+- p: 暂停/恢复刷新
+- r: 立即刷新
+- q/Ctrl+C: 退出
+*/
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github/shawn/ip-tool/internal/conns"
+	"github/shawn/ip-tool/internal/output"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// connColumns 连接表的列标题和宽度
+var connColumns = []struct {
+	title string
+	width int
+}{
+	{"Proto", 5},
+	{"Local", 22},
+	{"Remote", 28},
+	{"State", 11},
+	{"Type", 11},
+	{"Host", 26},
+	{"Country", 13},
+	{"AS", 22},
+	{"Process", 16},
+}
+
+// connsMsg 一次刷新的结果
+type connsMsg struct {
+	list []conns.Conn
+	err  error
+	at   time.Time
+}
+
+// connsTickMsg 刷新定时器
+type connsTickMsg struct{}
+
+// Conns 连接实时视图状态
+type Conns struct {
+	filter   conns.Filter
+	interval time.Duration
+	enricher *conns.Enricher // nil 表示不补充远端信息
+
+	all      []conns.Conn // 最近一次刷新的连接
+	visible  []conns.Conn // 文本过滤后的连接
+	err      error
+	updated  time.Time
+	loading  bool
+	paused   bool
+	cursor   int
+	offset   int
+	height   int
+	selected string // 光标所在连接的标识
+
+	search    textinput.Model
+	searching bool
+
+	ctx    context.Context // 远端信息查询共用，退出时取消
+	cancel context.CancelFunc
+}
+
+// NewConns 创建连接视图
+//
+// resolve 为 false 时不做反向解析和地理位置查询
+func NewConns(ctx context.Context, filter conns.Filter, interval time.Duration, resolve bool) *Conns {
+	ti := textinput.New()
+	ti.Prompt = "/"
+	ti.Placeholder = "filter"
+
+	c := &Conns{filter: filter, interval: interval, height: 24, search: ti}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	if resolve {
+		c.enricher = conns.NewEnricher()
+	}
+	return c
+}
+
+// Init 首次读取
+func (c *Conns) Init() tea.Cmd {
+	return c.refresh()
+}
+
+// refresh 读取套接字表并补充远端信息
+func (c *Conns) refresh() tea.Cmd {
+	if c.loading {
+		return nil
+	}
+	c.loading = true
+
+	ctx, filter, enricher := c.ctx, c.filter, c.enricher
+	return func() tea.Msg {
+		list, err := conns.List()
+		if err != nil {
+			return connsMsg{err: err, at: time.Now()}
+		}
+		list = filter.Apply(list)
+		if enricher != nil {
+			enricher.Enrich(ctx, list)
+		}
+		return connsMsg{list: list, at: time.Now()}
+	}
+}
+
+// tick 安排下一次刷新
+func (c *Conns) tick() tea.Cmd {
+	return tea.Tick(c.interval, func(time.Time) tea.Msg { return connsTickMsg{} })
+}
+
+// Update 处理消息，更新状态
+func (c *Conns) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		c.height = msg.Height
+		c.scroll()
+
+	case connsMsg:
+		c.loading = false
+		c.err = msg.err
+		c.updated = msg.at
+		if msg.err == nil {
+			c.all = msg.list
+			c.rebuild()
+		}
+		return c, c.tick()
+
+	case connsTickMsg:
+		if c.paused {
+			return c, c.tick()
+		}
+		return c, c.refresh()
+
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			c.cancel()
+			return c, tea.Quit
+		}
+		if c.searching {
+			return c.updateSearch(msg)
+		}
+		return c.updateKeys(msg)
+	}
+	return c, nil
+}
+
+// updateSearch 编辑过滤条件时的按键处理
+func (c *Conns) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		c.searching = false
+		c.search.Blur()
+		return c, nil
+	case "esc":
+		c.searching = false
+		c.search.Blur()
+		c.search.SetValue("")
+		c.rebuild()
+		return c, nil
+	}
+
+	var cmd tea.Cmd
+	c.search, cmd = c.search.Update(msg)
+	c.rebuild()
+	return c, cmd
+}
+
+// updateKeys 表格中的按键处理
+func (c *Conns) updateKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q":
+		c.cancel()
+		return c, tea.Quit
+	case "up", "k":
+		c.move(-1)
+	case "down", "j":
+		c.move(1)
+	case "pgup":
+		c.move(-c.pageSize())
+	case "pgdown":
+		c.move(c.pageSize())
+	case "home", "g":
+		c.move(-len(c.visible))
+	case "end", "G":
+		c.move(len(c.visible))
+	case "/":
+		c.searching = true
+		return c, c.search.Focus()
+	case "esc":
+		if c.search.Value() != "" {
+			c.search.SetValue("")
+			c.rebuild()
+		}
+	case "p":
+		c.paused = !c.paused
+	case "r":
+		return c, c.refresh()
+	}
+	return c, nil
+}
+
+// move 移动光标
+func (c *Conns) move(delta int) {
+	c.cursor += delta
+	if c.cursor >= len(c.visible) {
+		c.cursor = len(c.visible) - 1
+	}
+	if c.cursor < 0 {
+		c.cursor = 0
+	}
+	if c.cursor < len(c.visible) {
+		c.selected = connKey(c.visible[c.cursor])
+	}
+	c.scroll()
+}
+
+// scroll 调整滚动偏移
+func (c *Conns) scroll() {
+	page := c.pageSize()
+	if c.cursor < c.offset {
+		c.offset = c.cursor
+	}
+	if c.cursor >= c.offset+page {
+		c.offset = c.cursor - page + 1
+	}
+}
+
+// pageSize 可显示的行数 (扣除状态栏、表头和底部帮助)
+func (c *Conns) pageSize() int {
+	if n := c.height - 7; n > 1 {
+		return n
+	}
+	return 1
+}
+
+// rebuild 应用文本过滤，并让光标停留在同一连接上
+func (c *Conns) rebuild() {
+	query := strings.ToLower(strings.TrimSpace(c.search.Value()))
+	c.visible = c.visible[:0]
+	for _, conn := range c.all {
+		if query == "" || connMatches(conn, query) {
+			c.visible = append(c.visible, conn)
+		}
+	}
+
+	c.cursor = 0
+	for i, conn := range c.visible {
+		if connKey(conn) == c.selected {
+			c.cursor = i
+			break
+		}
+	}
+	c.scroll()
+}
+
+// connKey 连接的标识
+func connKey(c conns.Conn) string {
+	return c.Proto + " " + c.Local() + " " + c.Remote()
+}
+
+// connCells 连接各列的显示值
+func connCells(c conns.Conn) []string {
+	cells := []string{c.Proto, c.Local(), c.Remote(), c.State, "", "", "", "", ""}
+	if !c.IsListener() {
+		cells[4] = c.RemoteType
+	}
+	if p := c.Peer; p != nil {
+		cells[5], cells[6], cells[7] = p.Hostname, p.Country, p.AS
+	}
+	if c.Process != "" {
+		cells[8] = c.Process + "/" + strconv.Itoa(c.PID)
+	}
+	return cells
+}
+
+// connMatches 任意列是否包含查询字符串
+func connMatches(c conns.Conn, query string) bool {
+	for _, cell := range connCells(c) {
+		if strings.Contains(strings.ToLower(cell), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// View 渲染界面
+func (c *Conns) View() string {
+	var b strings.Builder
+
+	// 状态栏
+	b.WriteString(fmt.Sprintf("\n %d connections", len(c.all)))
+	if len(c.visible) != len(c.all) {
+		b.WriteString(fmt.Sprintf("  Showing: %d", len(c.visible)))
+	}
+	switch {
+	case c.paused:
+		b.WriteString("  " + output.StyleWarning.Render("paused"))
+	case c.loading:
+		b.WriteString("  " + output.StyleHint.Render("refreshing…"))
+	case !c.updated.IsZero():
+		b.WriteString("  " + output.StyleHint.Render("updated "+c.updated.Format("15:04:05")))
+	}
+	if c.err != nil {
+		b.WriteString("  " + output.StyleError.Render(c.err.Error()))
+	}
+	b.WriteString("\n\n")
+
+	// 表头
+	header := " "
+	for _, col := range connColumns {
+		header += pad(col.title, col.width) + " "
+	}
+	b.WriteString(output.StyleHint.Render(strings.TrimRight(header, " ")))
+	b.WriteString("\n")
+
+	// 数据行
+	end := min(c.offset+c.pageSize(), len(c.visible))
+	for i := c.offset; i < end; i++ {
+		cells := connCells(c.visible[i])
+		line := " "
+		for j, col := range connColumns {
+			line += pad(cells[j], col.width) + " "
+		}
+		line = strings.TrimRight(line, " ")
+		if i == c.cursor {
+			line = styleSelected.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	if len(c.visible) == 0 && !c.loading {
+		b.WriteString(output.StyleHint.Render("   (no matching connections)") + "\n")
+	}
+
+	// 底部: 过滤输入框或帮助
+	b.WriteString("\n")
+	if c.searching || c.search.Value() != "" {
+		b.WriteString(" " + c.search.View() + "\n")
+	}
+	b.WriteString(" (↑/↓ to move, / to filter, p to pause, r to refresh, q to quit)\n")
+
+	return b.String()
+}
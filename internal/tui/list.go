@@ -0,0 +1,558 @@
+/*
+多目标列表视图
+
+批量模式在终端中运行时使用此视图，而不是逐条打印文本。
+
+设计决策:
+- 每行一个独立的 spinner，结果异步填充
+- 限制并发查询数，避免触发 ip-api.com 的频率限制
+- 行有稳定的身份 (指针)，排序/过滤只改变显示顺序
+- Enter 进入单目标详情视图 (复用 App)，q/Esc 返回列表
+
+键位设计:
+- ↑/↓ (k/j): 移动光标
+- /: 过滤
+- s/S: 切换排序列 / 反转排序方向
+- Enter: 查看详情
+- r/R: 刷新当前行 / 刷新全部
+- q/Ctrl+C: 退出
+*/
+package tui
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github/shawn/ip-tool/internal/history"
+	"github/shawn/ip-tool/internal/output"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxConcurrent 同时进行的查询数上限
+const maxConcurrent = 4
+
+// column 列表列
+type column int
+
+const (
+	colTarget column = iota
+	colIPv4
+	colIPv6
+	colType
+	colCountry
+	colISP
+	columnCount
+)
+
+// columnDefs 列标题和宽度
+var columnDefs = [columnCount]struct {
+	title string
+	width int
+}{
+	colTarget:  {"Target", 22},
+	colIPv4:    {"IPv4", 15},
+	colIPv6:    {"IPv6", 24},
+	colType:    {"Type", 11},
+	colCountry: {"Country", 14},
+	colISP:     {"ISP", 20},
+}
+
+// styleSelected 当前行样式
+var styleSelected = lipgloss.NewStyle().Reverse(true)
+
+// row 列表中的一行
+type row struct {
+	target  string
+	result  *output.Result // 查询结果 (nil 表示未完成)
+	loading bool           // 是否在查询中或排队中
+	gen     int            // 刷新代数，用于丢弃过期结果
+	spinner spinner.Model  // 本行的加载动画
+}
+
+// cell 返回指定列的显示值
+func (r *row) cell(c column) string {
+	if c == colTarget {
+		return r.target
+	}
+	if r.result == nil {
+		return ""
+	}
+
+	switch c {
+	case colIPv4:
+		return displayIP(r.result.IPv4)
+	case colIPv6:
+		return displayIP(r.result.IPv6)
+	case colType:
+		return r.result.Type
+	case colCountry:
+		if r.result.Detail != nil {
+			return r.result.Detail.Country
+		}
+	case colISP:
+		if r.result.Detail != nil {
+			return r.result.Detail.ISP
+		}
+	}
+	return ""
+}
+
+// rowMsg 单行查询结果
+type rowMsg struct {
+	row    *row
+	gen    int
+	result *output.Result
+}
+
+// List 多目标列表视图状态
+type List struct {
+	rows     []*row // 全部行 (输入顺序)
+	visible  []*row // 过滤、排序后的行
+	cursor   int    // 当前行在 visible 中的位置
+	offset   int    // 滚动偏移
+	height   int    // 终端高度
+	pending  []*row // 等待查询的行
+	inFlight int    // 正在进行的查询数
+
+	sortBy   column // 排序列
+	sortDesc bool   // 是否降序
+	sorted   bool   // 是否启用排序
+
+	filter    textinput.Model // 过滤输入框
+	filtering bool            // 是否正在编辑过滤条件
+
+	detail *App // 详情视图 (nil 表示显示列表)
+
+	ctx    context.Context // 所有行的查询共用，退出时取消
+	cancel context.CancelFunc
+}
+
+// NewList 创建列表视图
+func NewList(ctx context.Context, targets []string) *List {
+	ti := textinput.New()
+	ti.Prompt = "/"
+	ti.Placeholder = "filter"
+
+	l := &List{filter: ti, height: 24}
+	l.ctx, l.cancel = context.WithCancel(ctx)
+	for _, t := range targets {
+		s := spinner.New()
+		s.Spinner = spinner.Dot
+		l.rows = append(l.rows, &row{target: t, spinner: s})
+	}
+	l.rebuild()
+	return l
+}
+
+// Err 退出时已完成的行中有失败时返回 *output.BatchError (决定退出码)
+//
+// 未完成的行不计入
+func (l *List) Err() error {
+	failed := &output.BatchError{}
+	for _, r := range l.rows {
+		if r.result == nil {
+			continue
+		}
+		failed.Total++
+		if err := r.result.Err(); err != nil {
+			failed.Errs = append(failed.Errs, err)
+		}
+	}
+	if failed.Failed = len(failed.Errs); failed.Failed == 0 {
+		return nil
+	}
+	return failed
+}
+
+// Init 初始化: 所有行入队
+func (l *List) Init() tea.Cmd {
+	return tea.Batch(l.enqueue(l.rows...)...)
+}
+
+// enqueue 将行加入查询队列并启动空闲的查询槽
+func (l *List) enqueue(rows ...*row) []tea.Cmd {
+	var cmds []tea.Cmd
+	for _, r := range rows {
+		r.gen++
+		r.result = nil
+		if !r.loading {
+			r.loading = true
+			cmds = append(cmds, r.spinner.Tick)
+		}
+		l.pending = append(l.pending, r)
+	}
+	return append(cmds, l.dispatch()...)
+}
+
+// dispatch 在并发上限内启动排队的查询
+func (l *List) dispatch() []tea.Cmd {
+	var cmds []tea.Cmd
+	for l.inFlight < maxConcurrent && len(l.pending) > 0 {
+		r := l.pending[0]
+		l.pending = l.pending[1:]
+		l.inFlight++
+		cmds = append(cmds, fetchRow(l.ctx, r, r.gen))
+	}
+	return cmds
+}
+
+// fetchRow 创建单行查询命令
+func fetchRow(ctx context.Context, r *row, gen int) tea.Cmd {
+	target := r.target
+	return func() tea.Msg {
+		return rowMsg{row: r, gen: gen, result: output.FetchResult(ctx, target, true)}
+	}
+}
+
+// Update 处理消息，更新状态
+func (l *List) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		l.height = msg.Height
+
+	case rowMsg:
+		l.inFlight--
+		cmds := l.dispatch()
+		if msg.gen == msg.row.gen {
+			msg.row.result = msg.result
+			msg.row.loading = false
+			output.Record(msg.result, "", history.SourceBatch)
+			l.rebuild()
+		}
+		return l, tea.Batch(cmds...)
+
+	case spinner.TickMsg:
+		var cmds []tea.Cmd
+		for _, r := range l.rows {
+			if r.loading && r.spinner.ID() == msg.ID {
+				var cmd tea.Cmd
+				r.spinner, cmd = r.spinner.Update(msg)
+				cmds = append(cmds, cmd)
+			}
+		}
+		if l.detail != nil {
+			_, cmd := l.detail.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+		return l, tea.Batch(cmds...)
+
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			l.cancel()
+			return l, tea.Quit
+		}
+		if l.detail != nil {
+			return l.updateDetail(msg)
+		}
+		if l.filtering {
+			return l.updateFilter(msg)
+		}
+		return l.updateKeys(msg)
+	}
+
+	// 其余消息 (ipv4Msg、geoMsg 等) 属于详情视图
+	if l.detail != nil {
+		_, cmd := l.detail.Update(msg)
+		return l, cmd
+	}
+	return l, nil
+}
+
+// updateDetail 详情视图中的按键处理
+func (l *List) updateDetail(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// 详情视图的输入框打开时，q/Esc 属于输入框
+	if !l.detail.prompt.active {
+		switch msg.String() {
+		case "q", "esc":
+			l.detail.stop()
+			l.detail = nil
+			return l, nil
+		}
+	}
+	_, cmd := l.detail.Update(msg)
+	return l, cmd
+}
+
+// updateFilter 编辑过滤条件时的按键处理
+func (l *List) updateFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		l.filtering = false
+		l.filter.Blur()
+		return l, nil
+	case "esc":
+		l.filtering = false
+		l.filter.Blur()
+		l.filter.SetValue("")
+		l.rebuild()
+		return l, nil
+	}
+
+	var cmd tea.Cmd
+	l.filter, cmd = l.filter.Update(msg)
+	l.rebuild()
+	return l, cmd
+}
+
+// updateKeys 列表视图中的按键处理
+func (l *List) updateKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q":
+		l.cancel()
+		return l, tea.Quit
+
+	case "up", "k":
+		l.move(-1)
+	case "down", "j":
+		l.move(1)
+	case "pgup":
+		l.move(-l.pageSize())
+	case "pgdown":
+		l.move(l.pageSize())
+	case "home", "g":
+		l.move(-len(l.visible))
+	case "end", "G":
+		l.move(len(l.visible))
+
+	case "/":
+		l.filtering = true
+		return l, l.filter.Focus()
+
+	case "esc":
+		if l.filter.Value() != "" {
+			l.filter.SetValue("")
+			l.rebuild()
+		}
+
+	case "s":
+		// 循环: 不排序 → Target → IPv4 → ... → ISP → 不排序
+		if !l.sorted {
+			l.sorted, l.sortBy = true, colTarget
+		} else if l.sortBy+1 < columnCount {
+			l.sortBy++
+		} else {
+			l.sorted = false
+		}
+		l.rebuild()
+
+	case "S":
+		l.sortDesc = !l.sortDesc
+		l.rebuild()
+
+	case "enter":
+		if r := l.selected(); r != nil {
+			// 该行的查询已在 rowMsg 中写入历史，详情不再重复记录
+			l.detail = NewApp(l.ctx, r.target, true)
+			l.detail.SetSource("", "")
+			return l, l.detail.Init()
+		}
+
+	case "r":
+		if r := l.selected(); r != nil && !r.loading {
+			return l, tea.Batch(l.enqueue(r)...)
+		}
+
+	case "R":
+		var idle []*row
+		for _, r := range l.rows {
+			if !r.loading {
+				idle = append(idle, r)
+			}
+		}
+		return l, tea.Batch(l.enqueue(idle...)...)
+	}
+
+	return l, nil
+}
+
+// move 移动光标并保持其在可见窗口内
+func (l *List) move(delta int) {
+	l.cursor += delta
+	if l.cursor >= len(l.visible) {
+		l.cursor = len(l.visible) - 1
+	}
+	if l.cursor < 0 {
+		l.cursor = 0
+	}
+	l.scroll()
+}
+
+// scroll 调整滚动偏移
+func (l *List) scroll() {
+	page := l.pageSize()
+	if l.cursor < l.offset {
+		l.offset = l.cursor
+	}
+	if l.cursor >= l.offset+page {
+		l.offset = l.cursor - page + 1
+	}
+}
+
+// pageSize 可显示的行数 (扣除标题、表头和底部帮助)
+func (l *List) pageSize() int {
+	if n := l.height - 7; n > 1 {
+		return n
+	}
+	return 1
+}
+
+// selected 返回当前选中的行
+func (l *List) selected() *row {
+	if l.cursor < 0 || l.cursor >= len(l.visible) {
+		return nil
+	}
+	return l.visible[l.cursor]
+}
+
+// rebuild 根据过滤和排序条件重建可见行
+func (l *List) rebuild() {
+	current := l.selected()
+
+	query := strings.ToLower(strings.TrimSpace(l.filter.Value()))
+	l.visible = l.visible[:0]
+	for _, r := range l.rows {
+		if query == "" || r.matches(query) {
+			l.visible = append(l.visible, r)
+		}
+	}
+
+	if l.sorted {
+		sort.SliceStable(l.visible, func(i, j int) bool {
+			a := strings.ToLower(l.visible[i].cell(l.sortBy))
+			b := strings.ToLower(l.visible[j].cell(l.sortBy))
+			if l.sortDesc {
+				return a > b
+			}
+			return a < b
+		})
+	}
+
+	// 尽量保持光标停留在同一行
+	l.cursor = 0
+	for i, r := range l.visible {
+		if r == current {
+			l.cursor = i
+			break
+		}
+	}
+	l.scroll()
+}
+
+// matches 检查任意列是否包含查询字符串
+func (r *row) matches(query string) bool {
+	for c := colTarget; c < columnCount; c++ {
+		if strings.Contains(strings.ToLower(r.cell(c)), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// View 渲染界面
+func (l *List) View() string {
+	if l.detail != nil {
+		return l.detail.View() + output.StyleHint.Render(" (esc to return to list)") + "\n"
+	}
+
+	var b strings.Builder
+
+	// 状态栏
+	done := 0
+	for _, r := range l.rows {
+		if !r.loading {
+			done++
+		}
+	}
+	b.WriteString(fmt.Sprintf("\n %d/%d done", done, len(l.rows)))
+	if l.sorted {
+		dir := "↑"
+		if l.sortDesc {
+			dir = "↓"
+		}
+		b.WriteString(fmt.Sprintf("  Sort: %s %s", columnDefs[l.sortBy].title, dir))
+	}
+	if len(l.visible) != len(l.rows) {
+		b.WriteString(fmt.Sprintf("  Showing: %d", len(l.visible)))
+	}
+	b.WriteString("\n\n")
+
+	// 表头
+	header := "   "
+	for c := colTarget; c < columnCount; c++ {
+		header += pad(columnDefs[c].title, columnDefs[c].width) + " "
+	}
+	b.WriteString(output.StyleHint.Render(strings.TrimRight(header, " ")))
+	b.WriteString("\n")
+
+	// 数据行
+	end := l.offset + l.pageSize()
+	if end > len(l.visible) {
+		end = len(l.visible)
+	}
+	for i := l.offset; i < end; i++ {
+		line := l.renderRow(l.visible[i])
+		if i == l.cursor {
+			line = styleSelected.Render(line)
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	if len(l.visible) == 0 {
+		b.WriteString(output.StyleHint.Render("   (no matching targets)"))
+		b.WriteString("\n")
+	}
+
+	// 底部: 过滤输入框或帮助
+	b.WriteString("\n")
+	if l.filtering || l.filter.Value() != "" {
+		b.WriteString(" " + l.filter.View() + "\n")
+	}
+	b.WriteString(" (↑/↓ to move, / to filter, s/S to sort, enter for detail, r/R to refresh, q to quit)\n")
+
+	return b.String()
+}
+
+// renderRow 渲染单行
+func (l *List) renderRow(r *row) string {
+	status := " "
+	switch {
+	case r.loading:
+		status = strings.TrimSpace(r.spinner.View())
+	case r.result != nil && r.result.Success:
+		status = "✓"
+	case r.result != nil:
+		status = "✗"
+	}
+
+	cells := []string{" " + status}
+	for c := colTarget; c < columnCount; c++ {
+		cells = append(cells, pad(r.cell(c), columnDefs[c].width))
+	}
+	return strings.TrimRight(strings.Join(cells, " "), " ")
+}
+
+// pad 截断或填充到指定显示宽度
+func pad(s string, width int) string {
+	if lipgloss.Width(s) > width {
+		runes := []rune(s)
+		for lipgloss.Width(string(runes)) > width-1 {
+			runes = runes[:len(runes)-1]
+		}
+		s = string(runes) + "…"
+	}
+	return s + strings.Repeat(" ", width-lipgloss.Width(s))
+}
+
+// displayIP 表格中的 IP 显示值
+func displayIP(s string) string {
+	if !validIP(s) {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,147 @@
+/*
+目标输入框
+
+让 TUI 成为持续使用的查询控制台: 无需退出即可查询新目标。
+
+设计决策:
+- 输入时实时验证 (与命令行参数、剪贴板使用同一套规则)
+- 支持直接粘贴 URL，提交时提取域名或 IP
+- 历史记录，↑/↓ 浏览: 启动时载入持久化的最近目标，会话内追加
+*/
+package tui
+
+import (
+	"strings"
+
+	"github/shawn/ip-tool/internal/history"
+	"github/shawn/ip-tool/internal/ip"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// recentLimit 从持久化历史载入的目标数
+const recentLimit = 20
+
+// prompt 目标输入框状态
+type prompt struct {
+	input   textinput.Model
+	active  bool     // 是否正在输入
+	history []string // 已提交的目标 (旧 → 新)
+	index   int      // 浏览历史时的位置 (len(history) 表示新输入)
+	draft   string   // 开始浏览历史前的输入内容
+}
+
+// newPrompt 创建输入框
+func newPrompt() *prompt {
+	ti := textinput.New()
+	ti.Prompt = "> "
+	ti.Placeholder = "IP, domain or URL"
+	ti.CharLimit = 2048
+
+	p := &prompt{input: ti}
+	recent := history.Default().Recent(recentLimit)
+	for i := len(recent) - 1; i >= 0; i-- {
+		p.history = append(p.history, recent[i])
+	}
+	return p
+}
+
+// recent 返回最近的 n 个目标 (新 → 旧)
+func (p *prompt) recent(n int) []string {
+	var targets []string
+	for i := len(p.history) - 1; i >= 0 && len(targets) < n; i-- {
+		targets = append(targets, p.history[i])
+	}
+	return targets
+}
+
+// open 打开输入框
+func (p *prompt) open() tea.Cmd {
+	p.active = true
+	p.index = len(p.history)
+	p.draft = ""
+	p.input.SetValue("")
+	return p.input.Focus()
+}
+
+// close 关闭输入框
+func (p *prompt) close() {
+	p.active = false
+	p.input.Blur()
+}
+
+// remember 将目标加入历史 (连续重复只保留一次)
+func (p *prompt) remember(target string) {
+	if target == "" {
+		return
+	}
+	if n := len(p.history); n > 0 && p.history[n-1] == target {
+		return
+	}
+	p.history = append(p.history, target)
+}
+
+// target 返回当前输入提取出的目标
+func (p *prompt) target() string {
+	return ip.ExtractFromURL(p.input.Value())
+}
+
+// validationError 返回实时验证错误 (空输入不提示)
+func (p *prompt) validationError() string {
+	value := strings.TrimSpace(p.input.Value())
+	if value == "" {
+		return ""
+	}
+	if !ip.IsValidTarget(p.target()) {
+		return "Not a valid IP address or domain"
+	}
+	return ""
+}
+
+// update 处理输入框中的按键
+//
+// 返回值 submitted 非空表示用户提交了一个有效目标
+func (p *prompt) update(msg tea.KeyMsg) (submitted string, cmd tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		p.close()
+		return "", nil
+
+	case "enter":
+		// 无效输入保持输入框打开，错误已内联显示
+		if strings.TrimSpace(p.input.Value()) == "" || p.validationError() != "" {
+			return "", nil
+		}
+		target := p.target()
+		p.remember(target)
+		p.close()
+		return target, nil
+
+	case "up":
+		if p.index > 0 {
+			if p.index == len(p.history) {
+				p.draft = p.input.Value()
+			}
+			p.index--
+			p.input.SetValue(p.history[p.index])
+			p.input.CursorEnd()
+		}
+		return "", nil
+
+	case "down":
+		if p.index < len(p.history) {
+			p.index++
+			if p.index == len(p.history) {
+				p.input.SetValue(p.draft)
+			} else {
+				p.input.SetValue(p.history[p.index])
+			}
+			p.input.CursorEnd()
+		}
+		return "", nil
+	}
+
+	p.input, cmd = p.input.Update(msg)
+	return "", cmd
+}
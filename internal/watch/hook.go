@@ -0,0 +1,62 @@
+/*
+Shell 钩子
+
+IP 变化时执行用户配置的命令，新旧 IP 通过环境变量传入:
+
+	IPQ_OLD_IPV4, IPQ_NEW_IPV4
+	IPQ_OLD_IPV6, IPQ_NEW_IPV6
+	IPQ_CHANGED     变化的地址族，逗号分隔 (如 "ipv4,ipv6")
+	IPQ_CHANGED_AT  RFC 3339 时间戳
+
+示例:
+
+	ipq watch --hook 'ufw allow from "$IPQ_NEW_IPV4" to any port 22'
+*/
+package watch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// hookTimeout 钩子最长执行时间 (变量便于测试时缩短)
+var hookTimeout = 30 * time.Second
+
+// runHook 通过系统 shell 执行钩子命令
+//
+// 钩子的输出直接转发到 ipq 的 stdout/stderr
+func runHook(command string, c Change) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+
+	cmd.Env = append(os.Environ(),
+		"IPQ_OLD_IPV4="+c.Old.IPv4,
+		"IPQ_NEW_IPV4="+c.New.IPv4,
+		"IPQ_OLD_IPV6="+c.Old.IPv6,
+		"IPQ_NEW_IPV6="+c.New.IPv6,
+		"IPQ_CHANGED="+strings.Join(c.Changed, ","),
+		"IPQ_CHANGED_AT="+c.Time.Format(time.RFC3339),
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timeout (>%s)", hookTimeout)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunHookEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook test uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "env")
+	c := Change{
+		Time:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Old:     Snapshot{IPv4: "203.0.113.1", IPv6: "2001:db8::1"},
+		New:     Snapshot{IPv4: "203.0.113.2", IPv6: "2001:db8::2"},
+		Changed: []string{"ipv4", "ipv6"},
+	}
+	err := runHook(`env | grep '^IPQ_' | sort > "`+out+`"`, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"IPQ_CHANGED=ipv4,ipv6",
+		"IPQ_CHANGED_AT=2024-05-01T12:00:00Z",
+		"IPQ_NEW_IPV4=203.0.113.2",
+		"IPQ_NEW_IPV6=2001:db8::2",
+		"IPQ_OLD_IPV4=203.0.113.1",
+		"IPQ_OLD_IPV6=2001:db8::1",
+	}, "\n") + "\n"
+	if string(data) != want {
+		t.Errorf("hook environment:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestRunHookTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook test uses sh")
+	}
+	orig := hookTimeout
+	hookTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { hookTimeout = orig })
+
+	start := time.Now()
+	err := runHook("exec sleep 10", Change{})
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("err = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("hook ran for %v past the timeout", elapsed)
+	}
+}
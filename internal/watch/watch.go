@@ -0,0 +1,215 @@
+/*
+Package watch 监视本机公网 IP 的变化
+
+依赖: internal/network
+
+使用场景:
+- 家庭实验室、分支机构的动态公网 IP
+- IP 变化后自动更新防火墙白名单、DNS 记录等
+
+工作方式:
+ 1. 按间隔 (加随机抖动) 轮询公网 IPv4/IPv6
+ 2. 与上一次成功的结果比较
+ 3. 发生变化时: 执行 shell 钩子、POST webhook、追加变更日志
+
+设计决策:
+- 第一次成功查询只建立基线，不算变化
+- 查询失败 (超时、无 IPv6) 不算变化，保留上一次的值，避免网络抖动误报
+- 钩子和 webhook 失败只报告错误，不中断监视
+- 抖动避免大量主机在同一时刻请求回显服务
+*/
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github/shawn/ip-tool/internal/network"
+)
+
+// Snapshot 某一时刻的公网 IP
+type Snapshot struct {
+	IPv4 string `json:"ipv4,omitempty"`
+	IPv6 string `json:"ipv6,omitempty"`
+}
+
+// Change IP 变化事件 (也是 webhook 和变更日志的 JSON 格式)
+type Change struct {
+	Event    string    `json:"event"` // 固定为 "ip_changed"
+	Time     time.Time `json:"time"`
+	Hostname string    `json:"hostname,omitempty"`
+	Old      Snapshot  `json:"old"`
+	New      Snapshot  `json:"new"`
+	Changed  []string  `json:"changed"` // "ipv4" 和/或 "ipv6"
+}
+
+// Watcher 公网 IP 监视器
+type Watcher struct {
+	Interval time.Duration // 轮询间隔
+	Jitter   time.Duration // 每次额外等待 [0, Jitter) 的随机时间
+	Hook     string        // 变化时执行的 shell 命令 (可选)
+	Webhook  string        // 变化时 POST 的 URL (可选)
+	LogPath  string        // 变更日志路径 (可选)
+
+	// 查询函数，默认使用 network 包，测试时可替换
+	FetchIPv4 func(ctx context.Context) (string, error)
+	FetchIPv6 func(ctx context.Context) (string, error)
+
+	// 事件回调 (可选): 变化、错误、每次轮询完成
+	OnChange func(Change)
+	OnError  func(error)
+	OnPoll   func(Snapshot)
+
+	current Snapshot
+}
+
+// New 创建使用默认查询函数的监视器
+func New(interval, jitter time.Duration) *Watcher {
+	return &Watcher{
+		Interval:  interval,
+		Jitter:    jitter,
+		FetchIPv4: network.FetchPublicIPv4,
+		FetchIPv6: network.FetchPublicIPv6,
+	}
+}
+
+// Current 返回当前已知的公网 IP
+func (w *Watcher) Current() Snapshot {
+	return w.current
+}
+
+// Run 持续监视，直到 ctx 取消
+func (w *Watcher) Run(ctx context.Context) error {
+	for {
+		w.Poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(w.nextDelay()):
+		}
+	}
+}
+
+// nextDelay 计算下一次轮询前的等待时间
+func (w *Watcher) nextDelay() time.Duration {
+	if w.Jitter <= 0 {
+		return w.Interval
+	}
+	return w.Interval + rand.N(w.Jitter)
+}
+
+// Poll 查询一次并处理变化
+//
+// 返回检测到的变化 (nil 表示无变化)
+func (w *Watcher) Poll(ctx context.Context) *Change {
+	next := w.current
+	if ip, err := w.FetchIPv4(ctx); err == nil && ip != "" {
+		next.IPv4 = ip
+	}
+	if ip, err := w.FetchIPv6(ctx); err == nil && ip != "" {
+		next.IPv6 = ip
+	}
+
+	if w.OnPoll != nil {
+		w.OnPoll(next)
+	}
+
+	var changed []string
+	if w.current.IPv4 != "" && next.IPv4 != w.current.IPv4 {
+		changed = append(changed, "ipv4")
+	}
+	if w.current.IPv6 != "" && next.IPv6 != w.current.IPv6 {
+		changed = append(changed, "ipv6")
+	}
+
+	old := w.current
+	w.current = next
+	if len(changed) == 0 {
+		return nil
+	}
+
+	hostname, _ := os.Hostname()
+	c := Change{
+		Event:    "ip_changed",
+		Time:     time.Now(),
+		Hostname: hostname,
+		Old:      old,
+		New:      next,
+		Changed:  changed,
+	}
+	w.dispatch(ctx, c)
+	return &c
+}
+
+// dispatch 通知所有配置的目标
+func (w *Watcher) dispatch(ctx context.Context, c Change) {
+	if w.OnChange != nil {
+		w.OnChange(c)
+	}
+
+	if w.LogPath != "" {
+		if err := appendLog(w.LogPath, c); err != nil {
+			w.report(fmt.Errorf("change log: %w", err))
+		}
+	}
+
+	if w.Hook != "" {
+		if err := runHook(w.Hook, c); err != nil {
+			w.report(fmt.Errorf("hook: %w", err))
+		}
+	}
+
+	if w.Webhook != "" {
+		if err := network.PostJSON(ctx, w.Webhook, c); err != nil {
+			w.report(fmt.Errorf("webhook: %w", err))
+		}
+	}
+}
+
+// report 报告非致命错误
+func (w *Watcher) report(err error) {
+	if w.OnError != nil {
+		w.OnError(err)
+	}
+}
+
+// DefaultLogPath 返回默认的变更日志路径
+//
+// 与查询历史一样属于 "状态"，放在 $XDG_STATE_HOME/ipq 下
+func DefaultLogPath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "ipq", "changes.jsonl")
+}
+
+// appendLog 以 JSON Lines 格式追加一条变更记录
+func appendLog(path string, c Change) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
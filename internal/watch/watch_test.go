@@ -0,0 +1,180 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// publicIP 本地的公网 IP 回显服务替身
+//
+// 每个地址族返回设置的 IP，为空时返回 503 (查询失败)
+type publicIP struct {
+	mu   sync.Mutex
+	ipv4 string
+	ipv6 string
+}
+
+func (p *publicIP) set(ipv4, ipv6 string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ipv4, p.ipv6 = ipv4, ipv6
+}
+
+// watcher 创建查询 p 的监视器
+func (p *publicIP) watcher(t *testing.T) *Watcher {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		ip := p.ipv4
+		if r.URL.Path == "/v6" {
+			ip = p.ipv6
+		}
+		p.mu.Unlock()
+		if ip == "" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, ip)
+	}))
+	t.Cleanup(srv.Close)
+
+	fetch := func(path string) func(ctx context.Context) (string, error) {
+		return func(ctx context.Context) (string, error) {
+			req, err := http.NewRequestWithContext(ctx, "GET", srv.URL+path, nil)
+			if err != nil {
+				return "", err
+			}
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				return "", err
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusOK {
+				return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+			}
+			return string(body), nil
+		}
+	}
+	return &Watcher{FetchIPv4: fetch("/v4"), FetchIPv6: fetch("/v6")}
+}
+
+func TestPoll(t *testing.T) {
+	p := &publicIP{}
+	w := p.watcher(t)
+	var changes []Change
+	w.OnChange = func(c Change) { changes = append(changes, c) }
+	ctx := context.Background()
+
+	// 第一次成功查询只建立基线
+	p.set("203.0.113.1", "2001:db8::1")
+	if c := w.Poll(ctx); c != nil {
+		t.Fatalf("baseline reported as change: %+v", c)
+	}
+	if got := w.Current(); got != (Snapshot{IPv4: "203.0.113.1", IPv6: "2001:db8::1"}) {
+		t.Fatalf("current = %+v", got)
+	}
+
+	// 相同的结果不算变化
+	if c := w.Poll(ctx); c != nil {
+		t.Fatalf("unchanged IP reported as change: %+v", c)
+	}
+
+	// 查询失败保留上一次的值
+	p.set("", "")
+	if c := w.Poll(ctx); c != nil {
+		t.Fatalf("failed lookup reported as change: %+v", c)
+	}
+	if got := w.Current(); got.IPv4 != "203.0.113.1" || got.IPv6 != "2001:db8::1" {
+		t.Fatalf("failed lookup dropped the old value: %+v", got)
+	}
+
+	// IPv4 变化，IPv6 查询失败
+	p.set("203.0.113.2", "")
+	c := w.Poll(ctx)
+	if c == nil {
+		t.Fatal("IPv4 change not detected")
+	}
+	if strings.Join(c.Changed, ",") != "ipv4" || c.Event != "ip_changed" {
+		t.Errorf("change = %+v", c)
+	}
+	if c.Old.IPv4 != "203.0.113.1" || c.New.IPv4 != "203.0.113.2" || c.New.IPv6 != "2001:db8::1" {
+		t.Errorf("old %+v new %+v", c.Old, c.New)
+	}
+
+	// 两个地址族都变化
+	p.set("203.0.113.3", "2001:db8::2")
+	if c := w.Poll(ctx); c == nil || strings.Join(c.Changed, ",") != "ipv4,ipv6" {
+		t.Errorf("change = %+v, want ipv4,ipv6", c)
+	}
+	if len(changes) != 2 {
+		t.Errorf("OnChange called %d times, want 2", len(changes))
+	}
+}
+
+// 地址族一开始就查询失败时，之后第一次成功只建立该地址族的基线
+func TestPollLateBaseline(t *testing.T) {
+	p := &publicIP{}
+	w := p.watcher(t)
+	ctx := context.Background()
+
+	p.set("203.0.113.1", "")
+	w.Poll(ctx)
+	p.set("203.0.113.1", "2001:db8::1")
+	if c := w.Poll(ctx); c != nil {
+		t.Errorf("first IPv6 answer reported as change: %+v", c)
+	}
+}
+
+func TestPollWritesLog(t *testing.T) {
+	p := &publicIP{}
+	w := p.watcher(t)
+	w.LogPath = filepath.Join(t.TempDir(), "state", "changes.jsonl")
+	ctx := context.Background()
+
+	p.set("203.0.113.1", "")
+	w.Poll(ctx)
+	p.set("203.0.113.2", "")
+	w.Poll(ctx)
+
+	data, err := os.ReadFile(w.LogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 1 ||
+		!strings.Contains(lines[0], `"new":{"ipv4":"203.0.113.2"}`) {
+		t.Errorf("change log = %s", data)
+	}
+}
+
+// 钩子失败只报告错误，不影响变化的检测
+func TestPollHookError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook test uses sh")
+	}
+	p := &publicIP{}
+	w := p.watcher(t)
+	w.Hook = "exit 3"
+	var errs []error
+	w.OnError = func(err error) { errs = append(errs, err) }
+	ctx := context.Background()
+
+	p.set("203.0.113.1", "")
+	w.Poll(ctx)
+	p.set("203.0.113.2", "")
+	if c := w.Poll(ctx); c == nil {
+		t.Fatal("change not detected")
+	}
+	if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), "hook: ") {
+		t.Errorf("errors = %v, want one hook error", errs)
+	}
+}